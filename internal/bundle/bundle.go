@@ -0,0 +1,205 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/ansoncodes/workshot/internal/storage"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+const (
+	// Version is the bundle format version written by Write.
+	Version = 1
+
+	// Extension is the file extension of bundles.
+	Extension = ".wsbundle"
+
+	manifestFile = "manifest.json"
+	snapshotsDir = "snapshots"
+)
+
+// manifest describes the content of a bundle
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Snapshots []Entry   `json:"snapshots"`
+}
+
+// entry is one snapshot of a bundle and the files that belong to it
+type Entry struct {
+	Name  string `json:"name"`
+	Files []File `json:"files"`
+}
+
+// file is a file of a bundle with its checksum
+// the first file of an entry is the snapshot json
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// write packs snapshots into a gzipped tar with a manifest
+func Write(w io.Writer, snaps []*types.Snapshot) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	manifest := Manifest{Version: Version, CreatedAt: time.Now()}
+
+	for _, snap := range snaps {
+		data, err := json.MarshalIndent(snap, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal snapshot '%s': %w", snap.Name, err)
+		}
+
+		name := path.Join(snapshotsDir, snap.Name+".json")
+		if err := writeFile(tw, name, data); err != nil {
+			return err
+		}
+		manifest.Snapshots = append(manifest.Snapshots, Entry{
+			Name:  snap.Name,
+			Files: []File{{Path: name, Size: int64(len(data)), SHA256: checksum(data)}},
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := writeFile(tw, manifestFile, data); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return gz.Close()
+}
+
+// read unpacks and validates a bundle
+// every file must be in the manifest with a matching checksum
+func Read(r io.Reader) (*Manifest, []*types.Snapshot, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a workshot bundle: %w", err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, nil, fmt.Errorf("unexpected entry %s in bundle", header.Name)
+		}
+		if _, ok := files[header.Name]; ok {
+			return nil, nil, fmt.Errorf("duplicate entry %s in bundle", header.Name)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s from bundle: %w", header.Name, err)
+		}
+		files[header.Name] = data
+	}
+
+	data, ok := files[manifestFile]
+	if !ok {
+		return nil, nil, fmt.Errorf("bundle has no %s", manifestFile)
+	}
+	delete(files, manifestFile)
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	if manifest.Version < 1 || manifest.Version > Version {
+		return nil, nil, fmt.Errorf("unsupported bundle version %d (expected %d)", manifest.Version, Version)
+	}
+
+	var snaps []*types.Snapshot
+	names := make(map[string]bool)
+	for _, entry := range manifest.Snapshots {
+		if err := checkName(entry.Name); err != nil {
+			return nil, nil, err
+		}
+		if names[entry.Name] {
+			return nil, nil, fmt.Errorf("snapshot '%s' is in the bundle twice", entry.Name)
+		}
+		names[entry.Name] = true
+
+		if len(entry.Files) == 0 {
+			return nil, nil, fmt.Errorf("snapshot '%s' has no files in the bundle", entry.Name)
+		}
+		for _, file := range entry.Files {
+			data, ok := files[file.Path]
+			if !ok {
+				return nil, nil, fmt.Errorf("%s is missing from the bundle", file.Path)
+			}
+			if int64(len(data)) != file.Size || checksum(data) != file.SHA256 {
+				return nil, nil, fmt.Errorf("%s is corrupted (checksum mismatch)", file.Path)
+			}
+		}
+
+		var snap types.Snapshot
+		if err := json.Unmarshal(files[entry.Files[0].Path], &snap); err != nil {
+			return nil, nil, fmt.Errorf("invalid snapshot '%s' in bundle: %w", entry.Name, err)
+		}
+		snap.Name = entry.Name
+		snaps = append(snaps, &snap)
+
+		for _, file := range entry.Files {
+			delete(files, file.Path)
+		}
+	}
+
+	for name := range files {
+		return nil, nil, fmt.Errorf("%s is in the bundle but not in its manifest", name)
+	}
+
+	return &manifest, snaps, nil
+}
+
+// snapshot names become file names on import
+func checkName(name string) error {
+	if err := storage.CheckName(name); err != nil {
+		return fmt.Errorf("%w in bundle", err)
+	}
+	return nil
+}
+
+func writeFile(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s to bundle: %w", name, err)
+	}
+	if _, err := io.Copy(tw, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to write %s to bundle: %w", name, err)
+	}
+	return nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,111 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+func TestWriteRead(t *testing.T) {
+	api := types.NewSnapshot("api")
+	api.WorkingDir = "/home/me/code/api"
+	api.PluginData["git"] = map[string]interface{}{"branch": "main"}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, []*types.Snapshot{api, types.NewSnapshot("web")}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	manifest, snaps, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if manifest.Version != Version || len(manifest.Snapshots) != 2 || len(manifest.Snapshots[0].Files[0].SHA256) != 64 {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+	if len(snaps) != 2 || snaps[0].WorkingDir != api.WorkingDir || snaps[1].Name != "web" {
+		t.Fatalf("Unexpected snapshots: %+v", snaps)
+	}
+	if git := snaps[0].PluginData["git"].(map[string]interface{}); git["branch"] != "main" {
+		t.Errorf("Plugin data not preserved: %v", snaps[0].PluginData)
+	}
+}
+
+// pack writes a bundle with the given files as they are
+func pack(t *testing.T, files map[string]string) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(data))
+	}
+	tw.Close()
+	gz.Close()
+	return &buf
+}
+
+func manifestFor(t *testing.T, version int, name, path, data string) string {
+	t.Helper()
+
+	manifest := Manifest{Version: version, Snapshots: []Entry{{
+		Name:  name,
+		Files: []File{{Path: path, Size: int64(len(data)), SHA256: checksum([]byte(data))}},
+	}}}
+	encoded, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(encoded)
+}
+
+func TestReadInvalid(t *testing.T) {
+	snap := `{"schema_version": 1, "name": "api"}`
+	path := "snapshots/api.json"
+
+	tests := map[string]struct {
+		files map[string]string
+		err   string
+	}{
+		"no manifest": {
+			files: map[string]string{path: snap},
+			err:   "no manifest.json",
+		},
+		"tampered": {
+			files: map[string]string{manifestFile: manifestFor(t, 1, "api", path, snap), path: snap + " "},
+			err:   "checksum mismatch",
+		},
+		"missing file": {
+			files: map[string]string{manifestFile: manifestFor(t, 1, "api", path, snap)},
+			err:   "missing from the bundle",
+		},
+		"unlisted file": {
+			files: map[string]string{manifestFile: manifestFor(t, 1, "api", path, snap), path: snap, "extra.sh": "rm -rf ~"},
+			err:   "not in its manifest",
+		},
+		"newer version": {
+			files: map[string]string{manifestFile: manifestFor(t, Version+1, "api", path, snap), path: snap},
+			err:   "unsupported bundle version",
+		},
+		"path in name": {
+			files: map[string]string{manifestFile: manifestFor(t, 1, "../api", path, snap), path: snap},
+			err:   "invalid snapshot name",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := Read(pack(t, tt.files))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
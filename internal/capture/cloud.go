@@ -0,0 +1,349 @@
+package capture
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// cloudcapturer captures kubernetes context and cloud cli profiles
+type CloudCapturer struct{}
+
+// clouddata is the saved kube context and cloud cli profiles
+type CloudData struct {
+	Kubeconfig    string `json:"kubeconfig,omitempty"`
+	KubeContext   string `json:"kube_context,omitempty"`
+	KubeNamespace string `json:"kube_namespace,omitempty"`
+	GcloudConfig  string `json:"gcloud_config,omitempty"`
+	GcloudProject string `json:"gcloud_project,omitempty"`
+	AWSProfile    string `json:"aws_profile,omitempty"`
+	AWSRegion     string `json:"aws_region,omitempty"`
+}
+
+// newcloudcapturer creates a cloud capturer
+func NewCloudCapturer() types.Capturer {
+	return plugin.Typed[CloudData](&CloudCapturer{})
+}
+
+func (c *CloudCapturer) Name() string {
+	return "cloud"
+}
+
+func (c *CloudCapturer) Priority() int {
+	return 15
+}
+
+func (c *CloudCapturer) CaptureData(ctx context.Context) (*CloudData, error) {
+	var data CloudData
+
+	// kubectl context and namespace
+	data.Kubeconfig = os.Getenv("KUBECONFIG")
+	data.KubeContext, data.KubeNamespace = currentKubeContext()
+
+	// gcloud active configuration
+	data.GcloudConfig, data.GcloudProject = currentGcloudConfig()
+
+	// aws profile and region
+	data.AWSProfile = firstEnv("AWS_PROFILE", "AWS_DEFAULT_PROFILE")
+	data.AWSRegion = currentAWSRegion()
+
+	if data == (CloudData{}) {
+		return nil, nil
+	}
+	return &data, nil
+}
+
+func (c *CloudCapturer) RestoreData(ctx context.Context, data *CloudData) error {
+	return nil // contexts are switched by the emitted shell commands
+}
+
+func (c *CloudCapturer) CanRestoreData(data *CloudData) bool {
+	return false
+}
+
+// mappathsdata rewrites the paths in the data, used to store them relative to a repository
+func (c *CloudCapturer) MapPathsData(data *CloudData, f func(path string) string) {
+	data.Kubeconfig = f(data.Kubeconfig)
+}
+
+// cloud context is important so a wrong cluster is hard to miss
+func (c *CloudCapturer) RenderData(data *CloudData) *types.Section {
+	section := &types.Section{Title: "Cloud Context", Important: true}
+
+	fields := []types.Field{
+		{Label: "Kube Context", Value: data.KubeContext},
+		{Label: "Namespace", Value: data.KubeNamespace},
+		{Label: "GCloud Config", Value: data.GcloudConfig},
+		{Label: "GCloud Project", Value: data.GcloudProject},
+		{Label: "AWS Profile", Value: data.AWSProfile},
+		{Label: "AWS Region", Value: data.AWSRegion},
+	}
+	for _, f := range fields {
+		if f.Value != "" {
+			section.Fields = append(section.Fields, f)
+		}
+	}
+
+	return section
+}
+
+// switch back to the saved contexts
+func (c *CloudCapturer) RestorePlanData(data *CloudData) []types.Action {
+	var plan []types.Action
+
+	if data.Kubeconfig != "" {
+		plan = append(plan, types.Export("KUBECONFIG", data.Kubeconfig))
+	}
+	if data.KubeContext != "" {
+		plan = append(plan, types.Run("kubectl", "config", "use-context", data.KubeContext))
+	}
+	if data.KubeNamespace != "" {
+		plan = append(plan, types.Run("kubectl", "config", "set-context", "--current", "--namespace="+data.KubeNamespace))
+	}
+	if data.GcloudConfig != "" {
+		plan = append(plan, types.Run("gcloud", "config", "configurations", "activate", data.GcloudConfig))
+	}
+	if data.AWSProfile != "" {
+		plan = append(plan, types.Export("AWS_PROFILE", data.AWSProfile))
+	}
+	if data.AWSRegion != "" {
+		plan = append(plan, types.Export("AWS_REGION", data.AWSRegion))
+	}
+
+	return plan
+}
+
+// helper functions
+
+// get current kubectl context and its namespace
+func currentKubeContext() (string, string) {
+	for _, path := range kubeconfigPaths() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		context, namespaces := parseKubeconfig(content)
+		if context != "" {
+			return context, namespaces[context]
+		}
+	}
+	return "", ""
+}
+
+// list kubeconfig files in lookup order
+func kubeconfigPaths() []string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		return filepath.SplitList(env)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(home, ".kube", "config")}
+}
+
+// read current-context and per-context namespaces from a kubeconfig
+// only the few keys needed are parsed, so no yaml library is required
+func parseKubeconfig(content []byte) (string, map[string]string) {
+	namespaces := make(map[string]string)
+
+	// kubeconfig may also be plain json
+	trimmed := strings.TrimSpace(string(content))
+	if strings.HasPrefix(trimmed, "{") {
+		var config struct {
+			CurrentContext string `json:"current-context"`
+			Contexts       []struct {
+				Name    string `json:"name"`
+				Context struct {
+					Namespace string `json:"namespace"`
+				} `json:"context"`
+			} `json:"contexts"`
+		}
+		if json.Unmarshal([]byte(trimmed), &config) != nil {
+			return "", namespaces
+		}
+		for _, c := range config.Contexts {
+			namespaces[c.Name] = c.Context.Namespace
+		}
+		return config.CurrentContext, namespaces
+	}
+
+	current := ""
+	inContexts := false
+	name, namespace := "", ""
+
+	flush := func() {
+		if name != "" {
+			namespaces[name] = namespace
+		}
+		name, namespace = "", ""
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmedLine := strings.TrimSpace(line)
+		if trimmedLine == "" || strings.HasPrefix(trimmedLine, "#") {
+			continue
+		}
+
+		// a new top level key ends the contexts list
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "-") {
+			if inContexts {
+				flush()
+			}
+			key, value := yamlKeyValue(trimmedLine)
+			inContexts = key == "contexts"
+			if key == "current-context" {
+				current = value
+			}
+			continue
+		}
+
+		if !inContexts {
+			continue
+		}
+
+		if strings.HasPrefix(trimmedLine, "- ") {
+			flush()
+			trimmedLine = strings.TrimSpace(trimmedLine[2:])
+		}
+
+		switch key, value := yamlKeyValue(trimmedLine); key {
+		case "name":
+			name = value
+		case "namespace":
+			namespace = value
+		}
+	}
+	if inContexts {
+		flush()
+	}
+
+	return current, namespaces
+}
+
+// split a simple `key: value` yaml line
+func yamlKeyValue(line string) (string, string) {
+	key, value, found := strings.Cut(line, ":")
+	if !found {
+		return "", ""
+	}
+	value = strings.TrimSpace(value)
+	value = strings.Trim(value, `"'`)
+	return strings.TrimSpace(key), value
+}
+
+// get active gcloud configuration and its project
+func currentGcloudConfig() (string, string) {
+	configDir := os.Getenv("CLOUDSDK_CONFIG")
+	if configDir == "" {
+		if runtime.GOOS == "windows" {
+			configDir = filepath.Join(os.Getenv("APPDATA"), "gcloud")
+		} else {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", ""
+			}
+			configDir = filepath.Join(home, ".config", "gcloud")
+		}
+	}
+
+	name := os.Getenv("CLOUDSDK_ACTIVE_CONFIG_NAME")
+	if name == "" {
+		content, err := os.ReadFile(filepath.Join(configDir, "active_config"))
+		if err != nil {
+			return "", ""
+		}
+		name = strings.TrimSpace(string(content))
+	}
+	if name == "" {
+		return "", ""
+	}
+
+	project := os.Getenv("CLOUDSDK_CORE_PROJECT")
+	if project == "" {
+		project = readINIValue(filepath.Join(configDir, "configurations", "config_"+name), "core", "project")
+	}
+
+	return name, project
+}
+
+// get aws region from env or the profile's config
+func currentAWSRegion() string {
+	if region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"); region != "" {
+		return region
+	}
+
+	profile := firstEnv("AWS_PROFILE", "AWS_DEFAULT_PROFILE")
+	if profile == "" {
+		return ""
+	}
+
+	configFile := os.Getenv("AWS_CONFIG_FILE")
+	if configFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configFile = filepath.Join(home, ".aws", "config")
+	}
+
+	section := "profile " + profile
+	if profile == "default" {
+		section = "default"
+	}
+	return readINIValue(configFile, section, "region")
+}
+
+// read one key from a section of an ini file
+func readINIValue(path, section, key string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	current := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		if current != section {
+			continue
+		}
+
+		if k, v, found := strings.Cut(line, "="); found && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v)
+		}
+	}
+
+	return ""
+}
+
+// return the first non-empty environment variable
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,153 @@
+package capture
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/internal/shell"
+)
+
+const testKubeconfig = `apiVersion: v1
+clusters:
+- cluster:
+    server: https://prod.example.com
+  name: prod
+contexts:
+- context:
+    cluster: prod
+    namespace: payments
+    user: admin
+  name: arn:aws:eks:us-east-1:123456789012:cluster/prod
+- context:
+    cluster: dev
+    user: admin
+  name: "dev"
+current-context: arn:aws:eks:us-east-1:123456789012:cluster/prod
+kind: Config
+users:
+- name: admin
+  user:
+    token: redacted
+`
+
+func TestCloudCapturerName(t *testing.T) {
+	cc := NewCloudCapturer()
+
+	if cc.Name() != "cloud" {
+		t.Errorf("Expected name 'cloud', got %s", cc.Name())
+	}
+}
+
+func TestParseKubeconfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		context   string
+		namespace string
+	}{
+		{
+			"yaml",
+			testKubeconfig,
+			"arn:aws:eks:us-east-1:123456789012:cluster/prod",
+			"payments",
+		},
+		{
+			"json",
+			`{"current-context":"dev","contexts":[{"name":"dev","context":{"namespace":"sandbox"}}]}`,
+			"dev",
+			"sandbox",
+		},
+		{
+			"no current context",
+			"apiVersion: v1\ncontexts: []\n",
+			"",
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			context, namespaces := parseKubeconfig([]byte(tt.content))
+			if context != tt.context {
+				t.Errorf("context = %q, want %q", context, tt.context)
+			}
+			if namespaces[context] != tt.namespace {
+				t.Errorf("namespace = %q, want %q", namespaces[context], tt.namespace)
+			}
+		})
+	}
+}
+
+func TestCloudCapture(t *testing.T) {
+	dir := t.TempDir()
+
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+	if err := os.WriteFile(kubeconfig, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	gcloudDir := filepath.Join(dir, "gcloud")
+	if err := os.MkdirAll(filepath.Join(gcloudDir, "configurations"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(gcloudDir, "active_config"), []byte("work\n"), 0644)
+	os.WriteFile(filepath.Join(gcloudDir, "configurations", "config_work"),
+		[]byte("[core]\naccount = me@example.com\nproject = billing-prod\n"), 0644)
+
+	awsConfig := filepath.Join(dir, "aws-config")
+	os.WriteFile(awsConfig, []byte("[profile ops]\nregion = eu-west-1\n"), 0644)
+
+	t.Setenv("KUBECONFIG", kubeconfig)
+	t.Setenv("CLOUDSDK_CONFIG", gcloudDir)
+	t.Setenv("CLOUDSDK_ACTIVE_CONFIG_NAME", "")
+	t.Setenv("CLOUDSDK_CORE_PROJECT", "")
+	t.Setenv("AWS_PROFILE", "ops")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_CONFIG_FILE", awsConfig)
+
+	data, err := NewCloudCapturer().Capture(t.Context())
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"kube_namespace": "payments",
+		"gcloud_config":  "work",
+		"gcloud_project": "billing-prod",
+		"aws_profile":    "ops",
+		"aws_region":     "eu-west-1",
+	}
+	for key, want := range expected {
+		if data[key] != want {
+			t.Errorf("%s = %v, want %s", key, data[key], want)
+		}
+	}
+
+	typed, err := plugin.Decode[CloudData](data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	cc := &CloudCapturer{}
+	commands := strings.Join(shell.Serialize(shell.Posix, cc.RestorePlanData(typed)), "\n")
+	for _, want := range []string{
+		"kubectl config use-context arn:aws:eks:us-east-1:123456789012:cluster/prod",
+		"kubectl config set-context --current --namespace=payments",
+		"gcloud config configurations activate work",
+		"export AWS_PROFILE=ops",
+		"export AWS_REGION=eu-west-1",
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("Missing command %q in:\n%s", want, commands)
+		}
+	}
+
+	section := cc.RenderData(typed)
+	if !section.Important || len(section.Fields) != 6 {
+		t.Errorf("Unexpected cloud section: %+v", section)
+	}
+}
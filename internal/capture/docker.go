@@ -0,0 +1,314 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// compose file names looked up in the working directory
+var composeFiles = []string{
+	"compose.yaml",
+	"compose.yml",
+	"docker-compose.yaml",
+	"docker-compose.yml",
+}
+
+// dockercapturer captures running docker compose services
+type DockerCapturer struct {
+	startServices bool // restore brings services back up only when set
+}
+
+// dockerdata is a compose project and its running services
+type DockerData struct {
+	Project     string           `json:"project"`
+	ConfigFiles []string         `json:"config_files,omitempty"`
+	Services    []ComposeService `json:"services"`
+}
+
+// composeservice is one running compose service
+type ComposeService struct {
+	Service string   `json:"service"`
+	Image   string   `json:"image"`
+	Ports   []string `json:"ports,omitempty"`
+	State   string   `json:"state,omitempty"`
+}
+
+// composecontainer is one entry of `docker compose ps --format json`
+type composeContainer struct {
+	Name       string `json:"Name"`
+	Project    string `json:"Project"`
+	Service    string `json:"Service"`
+	Image      string `json:"Image"`
+	State      string `json:"State"`
+	Publishers []struct {
+		URL           string `json:"URL"`
+		TargetPort    int    `json:"TargetPort"`
+		PublishedPort int    `json:"PublishedPort"`
+		Protocol      string `json:"Protocol"`
+	} `json:"Publishers"`
+}
+
+// newdockercapturer creates a docker capturer
+// startServices allows restore to run `docker compose up`
+func NewDockerCapturer(startServices bool) types.Capturer {
+	return plugin.Typed[DockerData](&DockerCapturer{startServices: startServices})
+}
+
+func (d *DockerCapturer) Name() string {
+	return "docker"
+}
+
+func (d *DockerCapturer) Priority() int {
+	return 50
+}
+
+func (d *DockerCapturer) CaptureData(ctx context.Context) (*DockerData, error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return nil, nil // docker not installed
+	}
+
+	if !hasComposeFile() {
+		return nil, nil // not a compose project
+	}
+
+	output, err := exec.CommandContext(ctx, "docker", "compose", "ps", "--format", "json").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list compose services: %w", err)
+	}
+
+	containers, err := parseComposePS(output)
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, nil // nothing running
+	}
+
+	project := containers[0].Project
+	services := make(map[string]*ComposeService)
+	for _, c := range containers {
+		svc, ok := services[c.Service]
+		if !ok {
+			svc = &ComposeService{
+				Service: c.Service,
+				Image:   c.Image,
+				State:   c.State,
+			}
+			services[c.Service] = svc
+		}
+
+		for _, p := range c.Publishers {
+			if p.PublishedPort == 0 {
+				continue
+			}
+			port := fmt.Sprintf("%d:%d/%s", p.PublishedPort, p.TargetPort, p.Protocol)
+			if !slices.Contains(svc.Ports, port) {
+				svc.Ports = append(svc.Ports, port)
+			}
+		}
+	}
+
+	list := make([]ComposeService, 0, len(services))
+	for _, svc := range services {
+		list = append(list, *svc)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Service < list[j].Service
+	})
+
+	return &DockerData{
+		Project:     project,
+		ConfigFiles: composeConfigFiles(ctx, project),
+		Services:    list,
+	}, nil
+}
+
+func (d *DockerCapturer) RestoreData(ctx context.Context, data *DockerData) error {
+	project := data.Project
+
+	args := []string{"compose", "-p", project}
+	for _, file := range data.ConfigFiles {
+		// fall back to compose file lookup if a file moved
+		if _, err := os.Stat(file); err != nil {
+			args = []string{"compose", "-p", project}
+			break
+		}
+		args = append(args, "-f", file)
+	}
+
+	args = append(args, "up", "-d")
+	for _, svc := range data.Services {
+		args = append(args, svc.Service)
+	}
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to start compose services: %s", strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+func (d *DockerCapturer) CanRestoreData(data *DockerData) bool {
+	if !d.startServices {
+		return false
+	}
+
+	if data.Project == "" || len(data.Services) == 0 {
+		return false
+	}
+
+	_, err := exec.LookPath("docker")
+	return err == nil
+}
+
+// mappathsdata rewrites the paths in the data, used to store them relative to a repository
+func (d *DockerCapturer) MapPathsData(data *DockerData, f func(path string) string) {
+	for i, file := range data.ConfigFiles {
+		data.ConfigFiles[i] = f(file)
+	}
+}
+
+// rollback stops the services the restore started
+// services that were already running before are left alone
+func (d *DockerCapturer) RollbackData(ctx context.Context, before, applied *DockerData) error {
+	running := make(map[string]bool)
+	if before != nil && before.Project == applied.Project {
+		for _, svc := range before.Services {
+			running[svc.Service] = true
+		}
+	}
+
+	args := []string{"compose", "-p", applied.Project, "stop"}
+	stop := len(args)
+	for _, svc := range applied.Services {
+		if !running[svc.Service] {
+			args = append(args, svc.Service)
+		}
+	}
+	if len(args) == stop {
+		return nil
+	}
+
+	output, err := exec.CommandContext(ctx, "docker", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to stop compose services: %s", strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// compare each saved service with the state of its container
+func (d *DockerCapturer) PreviewData(ctx context.Context, data *DockerData) []types.Step {
+	states := make(map[string]string)
+	output, err := exec.CommandContext(ctx, "docker", "compose", "-p", data.Project, "ps", "--all", "--format", "json").Output()
+	if err == nil {
+		containers, _ := parseComposePS(output)
+		for _, c := range containers {
+			states[c.Service] = c.State
+		}
+	}
+
+	steps := make([]types.Step, 0, len(data.Services))
+	for _, svc := range data.Services {
+		current := states[svc.Service]
+		if current == "" {
+			current = "not created"
+		}
+		steps = append(steps, types.Step{Description: "start service " + svc.Service, Current: current, Target: "running"})
+	}
+	return steps
+}
+
+func (d *DockerCapturer) RenderData(data *DockerData) *types.Section {
+	if len(data.Services) == 0 {
+		return nil
+	}
+
+	section := &types.Section{Title: "Docker Services"}
+	if data.Project != "" {
+		section.Fields = append(section.Fields, types.Field{Label: "Project", Value: data.Project})
+	}
+	for _, svc := range data.Services {
+		line := svc.Service + " " + svc.Image
+		if len(svc.Ports) > 0 {
+			line += " " + strings.Join(svc.Ports, ", ")
+		}
+		section.Lines = append(section.Lines, line)
+	}
+	section.Hints = []string{"Use --start-services to bring them back up"}
+
+	return section
+}
+
+// helper functions
+
+// check if the current folder has a compose file
+func hasComposeFile() bool {
+	for _, name := range composeFiles {
+		if _, err := os.Stat(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// parse compose ps output, which is either a json array or json lines
+func parseComposePS(output []byte) ([]composeContainer, error) {
+	trimmed := strings.TrimSpace(string(output))
+	if trimmed == "" {
+		return nil, nil
+	}
+
+	var containers []composeContainer
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal([]byte(trimmed), &containers); err != nil {
+			return nil, fmt.Errorf("failed to parse compose output: %w", err)
+		}
+		return containers, nil
+	}
+
+	for _, line := range splitLines(trimmed) {
+		var c composeContainer
+		if err := json.Unmarshal([]byte(line), &c); err != nil {
+			return nil, fmt.Errorf("failed to parse compose output: %w", err)
+		}
+		containers = append(containers, c)
+	}
+
+	return containers, nil
+}
+
+// get compose files used by a project
+func composeConfigFiles(ctx context.Context, project string) []string {
+	output, err := exec.CommandContext(ctx, "docker", "compose", "ls", "--all",
+		"--format", "json", "--filter", "name="+project).Output()
+	if err != nil {
+		return nil
+	}
+
+	var projects []struct {
+		Name        string `json:"Name"`
+		ConfigFiles string `json:"ConfigFiles"`
+	}
+	if err := json.Unmarshal(output, &projects); err != nil {
+		return nil
+	}
+
+	for _, p := range projects {
+		if p.Name == project && p.ConfigFiles != "" {
+			return strings.Split(p.ConfigFiles, ",")
+		}
+	}
+	return nil
+}
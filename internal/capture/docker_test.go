@@ -0,0 +1,160 @@
+package capture
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ansoncodes/workshot/internal/plugin"
+)
+
+// fake docker cli that answers compose ps/ls and logs every call
+const fakeDockerScript = `#!/bin/sh
+echo "$@" >> "$FAKE_DOCKER_LOG"
+case "$*" in
+  "compose ps --format json")
+    echo '{"Name":"app-web-1","Project":"app","Service":"web","Image":"nginx:1.27","State":"running","Publishers":[{"URL":"0.0.0.0","TargetPort":80,"PublishedPort":8080,"Protocol":"tcp"},{"URL":"::","TargetPort":80,"PublishedPort":8080,"Protocol":"tcp"}]}'
+    echo '{"Name":"app-db-1","Project":"app","Service":"db","Image":"postgres:16","State":"running","Publishers":[{"URL":"","TargetPort":5432,"PublishedPort":0,"Protocol":"tcp"}]}'
+    ;;
+  "compose ls --all --format json --filter name=app")
+    echo '[{"Name":"app","Status":"running(2)","ConfigFiles":"'"$FAKE_COMPOSE_FILE"'"}]'
+    ;;
+esac
+`
+
+func setupFakeDocker(t *testing.T) (string, string) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake docker cli requires a posix shell")
+	}
+
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "docker"), []byte(fakeDockerScript), 0755); err != nil {
+		t.Fatalf("Failed to write fake docker: %v", err)
+	}
+
+	projectDir := t.TempDir()
+	composeFile := filepath.Join(projectDir, "compose.yaml")
+	if err := os.WriteFile(composeFile, []byte("services: {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+
+	logPath := filepath.Join(t.TempDir(), "docker.log")
+	t.Setenv("PATH", binDir)
+	t.Setenv("FAKE_DOCKER_LOG", logPath)
+	t.Setenv("FAKE_COMPOSE_FILE", composeFile)
+	t.Chdir(projectDir)
+
+	return logPath, composeFile
+}
+
+func TestDockerCapturerName(t *testing.T) {
+	dc := NewDockerCapturer(false)
+
+	if dc.Name() != "docker" {
+		t.Errorf("Expected name 'docker', got %s", dc.Name())
+	}
+}
+
+func TestDockerCapture(t *testing.T) {
+	setupFakeDocker(t)
+
+	data, err := NewDockerCapturer(false).Capture(t.Context())
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+
+	if data["project"] != "app" {
+		t.Errorf("Expected project 'app', got %v", data["project"])
+	}
+
+	typed, err := plugin.Decode[DockerData](data)
+	if err != nil {
+		t.Fatalf("Invalid services data: %v", err)
+	}
+
+	services := typed.Services
+	if len(services) != 2 {
+		t.Fatalf("Expected 2 services, got %d", len(services))
+	}
+	if services[0].Service != "db" || services[1].Service != "web" {
+		t.Errorf("Expected services sorted as db, web, got %s, %s",
+			services[0].Service, services[1].Service)
+	}
+	if len(services[1].Ports) != 1 || services[1].Ports[0] != "8080:80/tcp" {
+		t.Errorf("Expected web port 8080:80/tcp, got %v", services[1].Ports)
+	}
+	if len(services[0].Ports) != 0 {
+		t.Errorf("Expected no published ports for db, got %v", services[0].Ports)
+	}
+}
+
+func TestDockerCaptureWithoutComposeFile(t *testing.T) {
+	setupFakeDocker(t)
+	t.Chdir(t.TempDir())
+
+	data, err := NewDockerCapturer(false).Capture(t.Context())
+	if err != nil || data != nil {
+		t.Errorf("Expected nothing captured outside a compose project, got %v, %v", data, err)
+	}
+}
+
+func TestDockerRestoreRequiresFlag(t *testing.T) {
+	logPath, composeFile := setupFakeDocker(t)
+
+	data, err := NewDockerCapturer(false).Capture(t.Context())
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+
+	if NewDockerCapturer(false).CanRestore(data) {
+		t.Error("Docker restore should be disabled without the flag")
+	}
+
+	dc := NewDockerCapturer(true)
+	if !dc.CanRestore(data) {
+		t.Fatal("Docker restore should be enabled with the flag")
+	}
+
+	if err := dc.Restore(t.Context(), data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	log, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read docker log: %v", err)
+	}
+
+	want := "compose -p app -f " + composeFile + " up -d db web"
+	if !strings.Contains(string(log), want) {
+		t.Errorf("Expected docker call %q, got:\n%s", want, log)
+	}
+}
+
+func TestDockerRollback(t *testing.T) {
+	logPath, _ := setupFakeDocker(t)
+
+	dc := &DockerCapturer{startServices: true}
+	applied := &DockerData{Project: "app", Services: []ComposeService{{Service: "db"}, {Service: "web"}}}
+	before := &DockerData{Project: "app", Services: []ComposeService{{Service: "db"}}}
+
+	if err := dc.RollbackData(t.Context(), before, applied); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	// nothing to stop when every service was already running
+	if err := dc.RollbackData(t.Context(), applied, applied); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	log, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read docker log: %v", err)
+	}
+	if strings.TrimSpace(string(log)) != "compose -p app stop web" {
+		t.Errorf("Expected only web to be stopped, got:\n%s", log)
+	}
+}
@@ -0,0 +1,416 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+const (
+	// workspace storage keys holding open editors and their view state
+	vscodeEditorsKey   = "memento/workbench.parts.editor"
+	vscodeViewStateKey = "memento/workbench.editors.files.textFileEditor"
+)
+
+// vscode flavours whose workspace storage is searched
+var vscodeProducts = []string{"Code", "Code - Insiders", "VSCodium"}
+
+// editorcapturer captures and restores open vs code editors
+type EditorCapturer struct{}
+
+// editordata is the detected editor and its open tabs
+type EditorData struct {
+	Detected   string     `json:"detected,omitempty"`
+	Workspace  string     `json:"workspace,omitempty"`
+	OpenFiles  []OpenFile `json:"open_files,omitempty"`
+	ActiveFile string     `json:"active_file,omitempty"`
+}
+
+// openfile is one editor tab with its cursor position
+type OpenFile struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+// grid node inside the serialized editor part state
+type vscodeGridNode struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// editor group stored in a leaf of the grid
+type vscodeEditorGroup struct {
+	ID      int `json:"id"`
+	Editors []struct {
+		ID    string `json:"id"`
+		Value string `json:"value"`
+	} `json:"editors"`
+	MRU []int `json:"mru"`
+}
+
+// neweditorcapturer creates an editor capturer
+func NewEditorCapturer() types.Capturer {
+	return plugin.Typed[EditorData](&EditorCapturer{})
+}
+
+func (e *EditorCapturer) Name() string {
+	return "editor"
+}
+
+func (e *EditorCapturer) Priority() int {
+	return 20
+}
+
+func (e *EditorCapturer) CaptureData(ctx context.Context) (*EditorData, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	var data EditorData
+	if os.Getenv("TERM_PROGRAM") == "vscode" {
+		data.Detected = "vscode"
+	}
+
+	storageDir := findVSCodeWorkspace(cwd)
+	if storageDir == "" {
+		if data.Detected == "" {
+			return nil, nil
+		}
+		return &data, nil
+	}
+
+	data.Detected = "vscode"
+	data.Workspace = cwd
+
+	dbPath := filepath.Join(storageDir, "state.vscdb")
+	editorState, err := readVSCodeState(ctx, dbPath, vscodeEditorsKey)
+	if err != nil || editorState == "" {
+		return &data, nil // editor list is unavailable, keep detection only
+	}
+
+	files, active, err := parseVSCodeEditors(editorState)
+	if err != nil {
+		return nil, err
+	}
+
+	if viewState, err := readVSCodeState(ctx, dbPath, vscodeViewStateKey); err == nil && viewState != "" {
+		applyVSCodeCursors(files, viewState)
+	}
+
+	data.OpenFiles = files
+	data.ActiveFile = active
+
+	return &data, nil
+}
+
+func (e *EditorCapturer) RestoreData(ctx context.Context, data *EditorData) error {
+	// open the workspace folder first so files land in its window
+	if data.Workspace != "" {
+		if output, err := exec.CommandContext(ctx, "code", data.Workspace).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to open workspace: %s", strings.TrimSpace(string(output)))
+		}
+	}
+
+	args := []string{"-r", "--goto"}
+	args = append(args, gotoArgs(data.OpenFiles, data.ActiveFile)...)
+
+	if output, err := exec.CommandContext(ctx, "code", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to open files: %s", strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+func (e *EditorCapturer) CanRestoreData(data *EditorData) bool {
+	if len(data.OpenFiles) == 0 {
+		return false
+	}
+
+	_, err := exec.LookPath("code")
+	return err == nil
+}
+
+// mappathsdata rewrites the paths in the data, used to store them relative to a repository
+func (e *EditorCapturer) MapPathsData(data *EditorData, f func(path string) string) {
+	data.Workspace = f(data.Workspace)
+	data.ActiveFile = f(data.ActiveFile)
+	for i := range data.OpenFiles {
+		data.OpenFiles[i].Path = f(data.OpenFiles[i].Path)
+	}
+}
+
+func (e *EditorCapturer) PreviewData(ctx context.Context, data *EditorData) []types.Step {
+	var steps []types.Step
+	if data.Workspace != "" {
+		steps = append(steps, types.Step{Description: "open workspace " + data.Workspace + " in VS Code"})
+	}
+	return append(steps, types.Step{Description: fmt.Sprintf("open %d files in VS Code", len(data.OpenFiles))})
+}
+
+// list open files, marking the active one with *
+func (e *EditorCapturer) RenderData(data *EditorData) *types.Section {
+	if data.Detected == "" && len(data.OpenFiles) == 0 {
+		return nil
+	}
+
+	section := &types.Section{Title: "Editor"}
+	if data.Detected != "" {
+		section.Fields = append(section.Fields, types.Field{Label: "Detected", Value: data.Detected})
+	}
+	if data.Workspace != "" {
+		section.Fields = append(section.Fields, types.Field{Label: "Workspace", Value: data.Workspace})
+	}
+
+	for _, f := range data.OpenFiles {
+		marker := " "
+		if f.Path == data.ActiveFile {
+			marker = "*"
+		}
+
+		line := marker + " " + f.Path
+		if f.Line > 0 {
+			line += fmt.Sprintf(":%d:%d", f.Line, max(f.Column, 1))
+		}
+		section.Lines = append(section.Lines, line)
+	}
+
+	return section
+}
+
+// helper functions
+
+// find the workspace storage folder vs code uses for a directory
+func findVSCodeWorkspace(dir string) string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	for _, product := range vscodeProducts {
+		root := filepath.Join(configDir, product, "User", "workspaceStorage")
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+
+			content, err := os.ReadFile(filepath.Join(root, entry.Name(), "workspace.json"))
+			if err != nil {
+				continue
+			}
+
+			var ws struct {
+				Folder string `json:"folder"`
+			}
+			if json.Unmarshal(content, &ws) != nil || ws.Folder == "" {
+				continue
+			}
+
+			if samePath(fileURIPath(ws.Folder), dir) {
+				return filepath.Join(root, entry.Name())
+			}
+		}
+	}
+
+	return ""
+}
+
+// read one value from the workspace state database
+func readVSCodeState(ctx context.Context, dbPath, key string) (string, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return "", err
+	}
+
+	query := fmt.Sprintf("SELECT value FROM ItemTable WHERE key = '%s';", key)
+	output, err := exec.CommandContext(ctx, "sqlite3", "-readonly", dbPath, query).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// read open editors and the active file from editor part state
+func parseVSCodeEditors(state string) ([]OpenFile, string, error) {
+	var part struct {
+		State struct {
+			SerializedGrid struct {
+				Root vscodeGridNode `json:"root"`
+			} `json:"serializedGrid"`
+			ActiveGroup int `json:"activeGroup"`
+		} `json:"editorpart.state"`
+	}
+	if err := json.Unmarshal([]byte(state), &part); err != nil {
+		return nil, "", fmt.Errorf("failed to parse editor state: %w", err)
+	}
+
+	var groups []vscodeEditorGroup
+	collectEditorGroups(part.State.SerializedGrid.Root, &groups)
+
+	var files []OpenFile
+	active := ""
+	seen := make(map[string]bool)
+
+	for _, group := range groups {
+		for i, editor := range group.Editors {
+			var input struct {
+				ResourceJSON struct {
+					FsPath string `json:"fsPath"`
+					Scheme string `json:"scheme"`
+				} `json:"resourceJSON"`
+			}
+			if json.Unmarshal([]byte(editor.Value), &input) != nil {
+				continue
+			}
+
+			path := input.ResourceJSON.FsPath
+			if path == "" || input.ResourceJSON.Scheme != "file" {
+				continue
+			}
+
+			if group.ID == part.State.ActiveGroup && len(group.MRU) > 0 && group.MRU[0] == i {
+				active = path
+			}
+
+			if !seen[path] {
+				seen[path] = true
+				files = append(files, OpenFile{Path: path})
+			}
+		}
+	}
+
+	return files, active, nil
+}
+
+// walk the editor grid and collect leaf groups
+func collectEditorGroups(node vscodeGridNode, groups *[]vscodeEditorGroup) {
+	switch node.Type {
+	case "leaf":
+		var group vscodeEditorGroup
+		if json.Unmarshal(node.Data, &group) == nil {
+			*groups = append(*groups, group)
+		}
+	case "branch":
+		var children []vscodeGridNode
+		if json.Unmarshal(node.Data, &children) == nil {
+			for _, child := range children {
+				collectEditorGroups(child, groups)
+			}
+		}
+	}
+}
+
+// fill in cursor positions from text editor view state
+func applyVSCodeCursors(files []OpenFile, state string) {
+	var viewState struct {
+		Entries [][]json.RawMessage `json:"textEditorViewState"`
+	}
+	if json.Unmarshal([]byte(state), &viewState) != nil {
+		return
+	}
+
+	cursors := make(map[string][2]int)
+	for _, entry := range viewState.Entries {
+		if len(entry) != 2 {
+			continue
+		}
+
+		var uri string
+		if json.Unmarshal(entry[0], &uri) != nil {
+			continue
+		}
+
+		// view state is stored per editor group
+		var perGroup map[string]struct {
+			CursorState []struct {
+				Position struct {
+					LineNumber int `json:"lineNumber"`
+					Column     int `json:"column"`
+				} `json:"position"`
+			} `json:"cursorState"`
+		}
+		if json.Unmarshal(entry[1], &perGroup) != nil {
+			continue
+		}
+
+		for _, group := range perGroup {
+			if len(group.CursorState) > 0 {
+				pos := group.CursorState[0].Position
+				cursors[fileURIPath(uri)] = [2]int{pos.LineNumber, pos.Column}
+				break
+			}
+		}
+	}
+
+	for i := range files {
+		for path, pos := range cursors {
+			if samePath(path, files[i].Path) {
+				files[i].Line = pos[0]
+				files[i].Column = pos[1]
+				break
+			}
+		}
+	}
+}
+
+// build `code --goto` arguments with the active file last
+func gotoArgs(files []OpenFile, active string) []string {
+	var args []string
+	last := ""
+	for _, f := range files {
+		arg := f.Path
+		if f.Line > 0 {
+			arg = fmt.Sprintf("%s:%d:%d", f.Path, f.Line, max(f.Column, 1))
+		}
+
+		if f.Path == active {
+			last = arg
+			continue
+		}
+		args = append(args, arg)
+	}
+
+	if last != "" {
+		args = append(args, last)
+	}
+	return args
+}
+
+// convert a file:// uri to a local path
+func fileURIPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		// file:///c:/Users -> c:/Users
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path)
+}
+
+// compare paths, ignoring case on windows
+func samePath(a, b string) bool {
+	a = filepath.Clean(a)
+	b = filepath.Clean(b)
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
@@ -0,0 +1,129 @@
+package capture
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ansoncodes/workshot/internal/plugin"
+)
+
+// editor part state with a split: two groups, the second one active
+const testEditorState = `{"editorpart.state":{"serializedGrid":{"root":{"type":"branch","data":[
+{"type":"leaf","data":{"id":0,"editors":[
+  {"id":"workbench.editors.files.fileEditorInput","value":"{\"resourceJSON\":{\"fsPath\":\"/src/app/main.go\",\"path\":\"/src/app/main.go\",\"scheme\":\"file\"}}"},
+  {"id":"workbench.editors.files.fileEditorInput","value":"{\"resourceJSON\":{\"fsPath\":\"/src/app/go.mod\",\"path\":\"/src/app/go.mod\",\"scheme\":\"file\"}}"}
+],"mru":[0,1]}},
+{"type":"leaf","data":{"id":1,"editors":[
+  {"id":"workbench.editors.files.fileEditorInput","value":"{\"resourceJSON\":{\"fsPath\":\"/src/app/README.md\",\"path\":\"/src/app/README.md\",\"scheme\":\"file\"}}"},
+  {"id":"workbench.editors.untitledEditorInput","value":"{\"resourceJSON\":{\"path\":\"Untitled-1\",\"scheme\":\"untitled\"}}"}
+],"mru":[0,1]}}
+]}},"activeGroup":1}}`
+
+const testViewState = `{"textEditorViewState":[
+["file:///src/app/main.go",{"0":{"cursorState":[{"inSelectionMode":false,"position":{"lineNumber":42,"column":7}}]}}]
+]}`
+
+func TestEditorCapturerName(t *testing.T) {
+	ec := NewEditorCapturer()
+
+	if ec.Name() != "editor" {
+		t.Errorf("Expected name 'editor', got %s", ec.Name())
+	}
+}
+
+func TestParseVSCodeEditors(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture uses posix paths")
+	}
+
+	files, active, err := parseVSCodeEditors(testEditorState)
+	if err != nil {
+		t.Fatalf("parseVSCodeEditors failed: %v", err)
+	}
+
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 file editors, got %d: %v", len(files), files)
+	}
+	if active != "/src/app/README.md" {
+		t.Errorf("Expected active file README.md, got %q", active)
+	}
+
+	applyVSCodeCursors(files, testViewState)
+	if files[0].Line != 42 || files[0].Column != 7 {
+		t.Errorf("Expected main.go cursor at 42:7, got %d:%d", files[0].Line, files[0].Column)
+	}
+	if files[1].Line != 0 {
+		t.Errorf("Expected no cursor for go.mod, got line %d", files[1].Line)
+	}
+
+	args := gotoArgs(files, active)
+	want := []string{"/src/app/main.go:42:7", "/src/app/go.mod", "/src/app/README.md"}
+	if strings.Join(args, " ") != strings.Join(want, " ") {
+		t.Errorf("gotoArgs = %v, want %v", args, want)
+	}
+}
+
+func TestEditorCaptureFromWorkspaceStorage(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("workspace storage location is only overridable on linux")
+	}
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+
+	configDir := t.TempDir()
+	projectDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("TERM_PROGRAM", "")
+	t.Chdir(projectDir)
+
+	storageDir := filepath.Join(configDir, "Code", "User", "workspaceStorage", "0123abcd")
+	if err := os.MkdirAll(storageDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	workspace, _ := json.Marshal(map[string]string{"folder": "file://" + projectDir})
+	if err := os.WriteFile(filepath.Join(storageDir, "workspace.json"), workspace, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sql := "CREATE TABLE ItemTable (key TEXT UNIQUE ON CONFLICT REPLACE, value BLOB);" +
+		"INSERT INTO ItemTable VALUES ('" + vscodeEditorsKey + "', '" + strings.ReplaceAll(testEditorState, "\n", "") + "');" +
+		"INSERT INTO ItemTable VALUES ('" + vscodeViewStateKey + "', '" + strings.ReplaceAll(testViewState, "\n", "") + "');"
+	cmd := exec.Command("sqlite3", filepath.Join(storageDir, "state.vscdb"), sql)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to create state database: %v: %s", err, output)
+	}
+
+	data, err := NewEditorCapturer().Capture(t.Context())
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+
+	if data["detected"] != "vscode" {
+		t.Errorf("Expected detected 'vscode', got %v", data["detected"])
+	}
+	if data["active_file"] != "/src/app/README.md" {
+		t.Errorf("Expected active file README.md, got %v", data["active_file"])
+	}
+
+	typed, err := plugin.Decode[EditorData](data)
+	if err != nil || len(typed.OpenFiles) != 3 {
+		t.Fatalf("Expected 3 open files, got %v (%v)", typed, err)
+	}
+}
+
+func TestEditorCaptureOutsideVSCode(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("TERM_PROGRAM", "")
+
+	data, err := NewEditorCapturer().Capture(t.Context())
+	if err != nil || data != nil {
+		t.Errorf("Expected nothing captured, got %v, %v", data, err)
+	}
+}
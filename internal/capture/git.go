@@ -1,19 +1,33 @@
 package capture
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/ansoncodes/workshot/internal/plugin"
 	"github.com/ansoncodes/workshot/pkg/types"
 )
 
 // gitcapturer captures and restores git state
 type GitCapturer struct{}
 
+// gitdata is the saved git state
+type GitData struct {
+	Branch     string `json:"branch,omitempty"`
+	Remote     string `json:"remote,omitempty"`
+	Dirty      bool   `json:"dirty"`
+	Commit     string `json:"commit,omitempty"`
+	StashCount int    `json:"stash_count,omitempty"`
+	Root       string `json:"root,omitempty"` // top level of the repository
+}
+
 // newgitcapturer creates a git capturer
 func NewGitCapturer() types.Capturer {
-	return &GitCapturer{}
+	return plugin.Typed[GitData](&GitCapturer{})
 }
 
 func (g *GitCapturer) Name() string {
@@ -24,78 +38,194 @@ func (g *GitCapturer) Priority() int {
 	return 10 // git runs early because it is important
 }
 
-func (g *GitCapturer) Capture() (map[string]interface{}, error) {
+func (g *GitCapturer) CaptureData(ctx context.Context) (*GitData, error) {
 	// check if current folder is a git repo
-	if !isGitRepo() {
+	if !isGitRepo(ctx) {
 		return nil, nil // nothing to capture if not a git repo
 	}
 
-	data := make(map[string]interface{})
+	return &GitData{
+		Branch:     getGitBranch(ctx),
+		Remote:     getGitRemote(ctx),
+		Dirty:      isGitDirty(ctx),
+		Commit:     getGitCommit(ctx),
+		StashCount: getGitStashCount(ctx),
+		Root:       getGitRoot(ctx),
+	}, nil
+}
+
+func (g *GitCapturer) RestoreData(ctx context.Context, data *GitData) error {
+	branch := data.Branch
+	if branch == "" {
+		return nil
+	}
+
+	// check if already on the same branch
+	currentBranch := getGitBranch(ctx)
+	if currentBranch == branch {
+		return nil // already correct
+	}
 
-	// save current branch
-	if branch := getGitBranch(); branch != "" {
-		data["branch"] = branch
+	// switch to saved branch
+	cmd := exec.CommandContext(ctx, "git", "checkout", branch)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to checkout branch '%s': %s", branch, string(output))
 	}
 
-	// save remote url
-	if remote := getGitRemote(); remote != "" {
-		data["remote"] = remote
+	return nil
+}
+
+func (g *GitCapturer) CanRestoreData(data *GitData) bool {
+	return data.Branch != "" && isGitRepo(context.Background())
+}
+
+// mappathsdata rewrites the paths in the data, used to store them relative to a repository
+func (g *GitCapturer) MapPathsData(data *GitData, f func(path string) string) {
+	data.Root = f(data.Root)
+}
+
+func (g *GitCapturer) RenderData(data *GitData) *types.Section {
+	if data.Branch == "" && data.Remote == "" {
+		return nil
 	}
 
-	// check if repo has uncommitted changes
-	data["dirty"] = isGitDirty()
+	section := &types.Section{Title: "Git State"}
+	if data.Branch != "" {
+		section.Fields = append(section.Fields, types.Field{Label: "Branch", Value: data.Branch})
+	}
 
-	// save current commit hash
-	if commit := getGitCommit(); commit != "" {
-		data["commit"] = commit
+	status := "Clean"
+	if data.Dirty {
+		status = "Dirty (uncommitted changes)"
 	}
+	section.Fields = append(section.Fields, types.Field{Label: "Status", Value: status})
 
-	// save stash count if any
-	if stashCount := getGitStashCount(); stashCount > 0 {
-		data["stash_count"] = stashCount
+	if data.Remote != "" {
+		section.Fields = append(section.Fields, types.Field{Label: "Remote", Value: data.Remote})
+	}
+	if data.Commit != "" {
+		section.Fields = append(section.Fields, types.Field{Label: "Commit", Value: data.Commit})
+	}
+	if data.StashCount > 0 {
+		section.Fields = append(section.Fields, types.Field{Label: "Stashes", Value: strconv.Itoa(data.StashCount)})
 	}
 
-	return data, nil
+	return section
 }
 
-func (g *GitCapturer) Restore(data map[string]interface{}) error {
-	branch, ok := data["branch"].(string)
-	if !ok || branch == "" {
+func (g *GitCapturer) RestorePlanData(data *GitData) []types.Action {
+	if data.Branch == "" {
 		return nil
 	}
+	return []types.Action{types.Run("git", "checkout", data.Branch)}
+}
 
-	// check if already on the same branch
-	currentBranch := getGitBranch()
-	if currentBranch == branch {
-		return nil // already correct
+// rollback checks out the branch from before the restore, or the commit
+// if the head was detached
+func (g *GitCapturer) RollbackData(ctx context.Context, before, applied *GitData) error {
+	if before == nil {
+		return nil
+	}
+	if before.Branch != "HEAD" {
+		return g.RestoreData(ctx, before)
+	}
+	if before.Commit == "" {
+		return fmt.Errorf("no commit saved to go back to the detached head")
 	}
 
-	// switch to saved branch
-	cmd := exec.Command("git", "checkout", branch)
-	output, err := cmd.CombinedOutput()
+	output, err := exec.CommandContext(ctx, "git", "checkout", "--quiet", "--detach", before.Commit).CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("failed to checkout branch '%s': %s", branch, string(output))
+		return fmt.Errorf("failed to checkout commit '%s': %s", before.Commit, strings.TrimSpace(string(output)))
 	}
-
 	return nil
 }
 
-func (g *GitCapturer) CanRestore(data map[string]interface{}) bool {
-	_, hasBranch := data["branch"]
-	return hasBranch && isGitRepo()
+func (g *GitCapturer) PreviewData(ctx context.Context, data *GitData) []types.Step {
+	if data.Branch == "" {
+		return nil
+	}
+	return []types.Step{{Description: "check out branch", Current: getGitBranch(ctx), Target: data.Branch}}
+}
+
+// clonerepo clones the saved remote into dir and checks out the saved
+// branch, or the saved commit if the branch never reached the remote
+func CloneRepo(ctx context.Context, data *GitData, dir string) error {
+	// snapshots can come from others, so the remote must not pass for an option
+	if strings.HasPrefix(data.Remote, "-") {
+		return fmt.Errorf("refusing to clone %q, it is not a remote", data.Remote)
+	}
+	if output, err := exec.CommandContext(ctx, "git", "clone", "--quiet", "--", data.Remote, dir).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to clone %s: %s", data.Remote, strings.TrimSpace(string(output)))
+	}
+
+	// a detached head has no branch to check out
+	var refs []string
+	if data.Branch != "" && data.Branch != "HEAD" && !strings.HasPrefix(data.Branch, "-") {
+		refs = append(refs, data.Branch)
+	}
+	if data.Commit != "" && !strings.HasPrefix(data.Commit, "-") {
+		refs = append(refs, data.Commit)
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	for _, ref := range refs {
+		if exec.CommandContext(ctx, "git", "-C", dir, "checkout", "--quiet", ref).Run() == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("cloned %s, but %s is not on the remote", data.Remote, strings.Join(refs, " or "))
+}
+
+// reporemote returns the origin remote of the repository in dir
+func RepoRemote(ctx context.Context, dir string) string {
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "config", "--get", "remote.origin.url")
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// sameremote reports whether two remotes point at the same repository
+// ssh, https and scp style urls of one repository are the same
+func SameRemote(a, b string) bool {
+	a, b = normalizeRemote(a), normalizeRemote(b)
+	return a != "" && a == b
+}
+
+// reduce a remote url to host/path
+func normalizeRemote(remote string) string {
+	remote = strings.TrimSpace(remote)
+	if i := strings.Index(remote, "://"); i >= 0 {
+		remote = remote[i+3:]
+	} else if i := strings.Index(remote, ":"); i > 0 && !strings.Contains(remote[:i], "/") {
+		// scp style, e.g. git@github.com:me/api.git
+		remote = remote[:i] + "/" + remote[i+1:]
+	}
+
+	// drop the user, a local path has none
+	if at := strings.Index(remote, "@"); at >= 0 && !strings.Contains(remote[:at], "/") {
+		remote = remote[at+1:]
+	}
+
+	remote = strings.TrimSuffix(strings.TrimRight(remote, "/"), ".git")
+	return strings.ToLower(remote)
 }
 
 // helper functions
 
 // check if current folder is a git repo
-func isGitRepo() bool {
-	cmd := exec.Command("git", "rev-parse", "--git-dir")
+func isGitRepo(ctx context.Context) bool {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--git-dir")
 	return cmd.Run() == nil
 }
 
 // get current git branch name
-func getGitBranch() string {
-	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
+func getGitBranch(ctx context.Context) string {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--abbrev-ref", "HEAD")
 	output, err := cmd.Output()
 	if err != nil {
 		return ""
@@ -104,8 +234,8 @@ func getGitBranch() string {
 }
 
 // get origin remote url
-func getGitRemote() string {
-	cmd := exec.Command("git", "config", "--get", "remote.origin.url")
+func getGitRemote(ctx context.Context) string {
+	cmd := exec.CommandContext(ctx, "git", "config", "--get", "remote.origin.url")
 	output, err := cmd.Output()
 	if err != nil {
 		return ""
@@ -114,8 +244,8 @@ func getGitRemote() string {
 }
 
 // check if repo has uncommitted changes
-func isGitDirty() bool {
-	cmd := exec.Command("git", "status", "--porcelain")
+func isGitDirty(ctx context.Context) bool {
+	cmd := exec.CommandContext(ctx, "git", "status", "--porcelain")
 	output, err := cmd.Output()
 	if err != nil {
 		return false
@@ -124,8 +254,8 @@ func isGitDirty() bool {
 }
 
 // get current commit hash
-func getGitCommit() string {
-	cmd := exec.Command("git", "rev-parse", "HEAD")
+func getGitCommit(ctx context.Context) string {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "HEAD")
 	output, err := cmd.Output()
 	if err != nil {
 		return ""
@@ -137,9 +267,19 @@ func getGitCommit() string {
 	return commit
 }
 
+// get the top level directory of the repository
+func getGitRoot(ctx context.Context) string {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--show-toplevel")
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return filepath.Clean(strings.TrimSpace(string(output)))
+}
+
 // get number of stashed changes
-func getGitStashCount() int {
-	cmd := exec.Command("git", "stash", "list")
+func getGitStashCount(ctx context.Context) int {
+	cmd := exec.CommandContext(ctx, "git", "stash", "list")
 	output, err := cmd.Output()
 	if err != nil {
 		return 0
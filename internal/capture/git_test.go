@@ -1,6 +1,10 @@
 package capture
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -53,4 +57,169 @@ func TestGitCanRestore(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestGitRender(t *testing.T) {
+	gc := &GitCapturer{}
+
+	section := gc.RenderData(&GitData{Branch: "feature/login", Dirty: true, Commit: "abc1234", StashCount: 2})
+	if section == nil || section.Title != "Git State" {
+		t.Fatalf("Expected git section, got %+v", section)
+	}
+
+	fields := make(map[string]string)
+	for _, f := range section.Fields {
+		fields[f.Label] = f.Value
+	}
+	if fields["Branch"] != "feature/login" || fields["Stashes"] != "2" || fields["Status"] != "Dirty (uncommitted changes)" {
+		t.Errorf("Unexpected fields: %v", fields)
+	}
+
+	plan := gc.RestorePlanData(&GitData{Branch: "feature/login"})
+	if len(plan) != 1 || strings.Join(plan[0].Args, " ") != "git checkout feature/login" {
+		t.Errorf("Unexpected restore plan: %v", plan)
+	}
+
+	if gc.RenderData(&GitData{}) != nil {
+		t.Error("Expected no section without a branch or remote")
+	}
+}
+
+func TestGitRollback(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	t.Chdir(t.TempDir())
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+		{"branch", "feature/x"},
+	} {
+		if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %s", args, output)
+		}
+	}
+
+	gc := &GitCapturer{}
+	before, err := gc.CaptureData(t.Context())
+	if err != nil || before == nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+
+	applied := &GitData{Branch: "feature/x"}
+	steps := gc.PreviewData(t.Context(), applied)
+	if len(steps) != 1 || steps[0].Current != "main" || steps[0].Target != "feature/x" {
+		t.Errorf("Unexpected preview: %+v", steps)
+	}
+
+	if err := gc.RestoreData(t.Context(), applied); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if err := gc.RollbackData(t.Context(), before, applied); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if branch := getGitBranch(t.Context()); branch != "main" {
+		t.Errorf("Expected rollback to main, got %s", branch)
+	}
+
+	// a detached head goes back to its commit
+	output, err := exec.Command("git", "checkout", "-q", "--detach", "HEAD").CombinedOutput()
+	if err != nil {
+		t.Fatalf("git checkout failed: %s", output)
+	}
+	if before, err = gc.CaptureData(t.Context()); err != nil || before.Branch != "HEAD" {
+		t.Fatalf("Expected a detached head, got %+v (%v)", before, err)
+	}
+	if err := gc.RestoreData(t.Context(), applied); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if err := gc.RollbackData(t.Context(), before, applied); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if branch, commit := getGitBranch(t.Context()), getGitCommit(t.Context()); branch != "HEAD" || commit != before.Commit {
+		t.Errorf("Expected rollback to the detached %s, got %s at %s", before.Commit, branch, commit)
+	}
+}
+
+func TestCloneRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	git := func(dir string, args ...string) string {
+		args = append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		output, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %s", args, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	// a bare repository stands in for the remote
+	base := t.TempDir()
+	remote := filepath.Join(base, "remote.git")
+	work := filepath.Join(base, "work")
+	git(base, "init", "-q", "--bare", "-b", "main", remote)
+	git(base, "init", "-q", "-b", "main", work)
+	git(work, "commit", "-q", "--allow-empty", "-m", "init")
+	git(work, "checkout", "-q", "-b", "feature/x")
+	git(work, "commit", "-q", "--allow-empty", "-m", "feature")
+	pushed := git(work, "rev-parse", "HEAD")
+	git(work, "push", "-q", remote, "main", "feature/x")
+
+	// the saved branch is checked out when the remote has it
+	dir := filepath.Join(base, "branch")
+	if err := CloneRepo(t.Context(), &GitData{Remote: remote, Branch: "feature/x", Commit: pushed}, dir); err != nil {
+		t.Fatalf("CloneRepo failed: %v", err)
+	}
+	if branch := git(dir, "rev-parse", "--abbrev-ref", "HEAD"); branch != "feature/x" {
+		t.Errorf("Expected feature/x to be checked out, got %s", branch)
+	}
+
+	// a branch that was never pushed falls back to the commit
+	dir = filepath.Join(base, "commit")
+	if err := CloneRepo(t.Context(), &GitData{Remote: remote, Branch: "local-only", Commit: pushed}, dir); err != nil {
+		t.Fatalf("CloneRepo failed: %v", err)
+	}
+	if commit := git(dir, "rev-parse", "HEAD"); commit != pushed {
+		t.Errorf("Expected commit %s to be checked out, got %s", pushed, commit)
+	}
+
+	// the clone is kept when neither is on the remote
+	dir = filepath.Join(base, "missing")
+	err := CloneRepo(t.Context(), &GitData{Remote: remote, Branch: "local-only"}, dir)
+	if err == nil || !strings.Contains(err.Error(), "local-only is not on the remote") {
+		t.Errorf("Expected missing branch error, got %v", err)
+	}
+
+	// a remote from someone else's snapshot is never taken as an option
+	marker := filepath.Join(base, "pwned")
+	dir = filepath.Join(base, "option")
+	err = CloneRepo(t.Context(), &GitData{Remote: "--upload-pack=touch " + marker}, dir)
+	if err == nil {
+		t.Error("Expected a remote that looks like an option to be refused")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expected the remote not to run as an option")
+	}
+}
+
+func TestSameRemote(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"git@github.com:me/api.git", "https://github.com/me/api", true},
+		{"ssh://git@github.com/me/api.git", "https://github.com/Me/API.git/", true},
+		{"/srv/git/api.git", "/srv/git/api", true},
+		{"git@github.com:me/api.git", "git@github.com:me/web.git", false},
+		{"git@gitlab.com:me/api.git", "git@github.com:me/api.git", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := SameRemote(tt.a, tt.b); got != tt.same {
+			t.Errorf("SameRemote(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.same)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// minimal msgpack encoding used to talk to neovim over rpc
+// only the types neovim sends and receives are supported
+
+// encode a value as msgpack
+func msgpackEncode(w io.Writer, v interface{}) error {
+	buf, err := msgpackAppend(nil, v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+func msgpackAppend(buf []byte, v interface{}) ([]byte, error) {
+	switch val := v.(type) {
+	case nil:
+		return append(buf, 0xc0), nil
+	case bool:
+		if val {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case int:
+		return msgpackAppendInt(buf, int64(val)), nil
+	case int64:
+		return msgpackAppendInt(buf, val), nil
+	case uint64:
+		if val > math.MaxInt64 {
+			buf = append(buf, 0xcf)
+			return binary.BigEndian.AppendUint64(buf, val), nil
+		}
+		return msgpackAppendInt(buf, int64(val)), nil
+	case float64:
+		buf = append(buf, 0xcb)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(val)), nil
+	case string:
+		n := len(val)
+		switch {
+		case n < 32:
+			buf = append(buf, 0xa0|byte(n))
+		case n <= math.MaxUint8:
+			buf = append(buf, 0xd9, byte(n))
+		case n <= math.MaxUint16:
+			buf = append(buf, 0xda)
+			buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+		default:
+			buf = append(buf, 0xdb)
+			buf = binary.BigEndian.AppendUint32(buf, uint32(n))
+		}
+		return append(buf, val...), nil
+	case []byte:
+		n := len(val)
+		switch {
+		case n <= math.MaxUint8:
+			buf = append(buf, 0xc4, byte(n))
+		case n <= math.MaxUint16:
+			buf = append(buf, 0xc5)
+			buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+		default:
+			buf = append(buf, 0xc6)
+			buf = binary.BigEndian.AppendUint32(buf, uint32(n))
+		}
+		return append(buf, val...), nil
+	case []interface{}:
+		buf = msgpackAppendLen(buf, len(val), 0x90, 0xdc, 0xdd)
+		for _, item := range val {
+			var err error
+			if buf, err = msgpackAppend(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]interface{}:
+		buf = msgpackAppendLen(buf, len(val), 0x80, 0xde, 0xdf)
+
+		// sorted keys keep output deterministic
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			var err error
+			if buf, err = msgpackAppend(buf, k); err != nil {
+				return nil, err
+			}
+			if buf, err = msgpackAppend(buf, val[k]); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+
+	return nil, fmt.Errorf("msgpack: unsupported type %T", v)
+}
+
+func msgpackAppendInt(buf []byte, n int64) []byte {
+	switch {
+	case n >= 0 && n <= 0x7f:
+		return append(buf, byte(n))
+	case n < 0 && n >= -32:
+		return append(buf, byte(n))
+	default:
+		buf = append(buf, 0xd3)
+		return binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+}
+
+func msgpackAppendLen(buf []byte, n int, fix, code16, code32 byte) []byte {
+	switch {
+	case n < 16:
+		return append(buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		buf = append(buf, code16)
+		return binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, code32)
+		return binary.BigEndian.AppendUint32(buf, uint32(n))
+	}
+}
+
+// decode one msgpack value
+// integers decode as int64, maps as map[string]interface{}
+// ext values (neovim buffer/window handles) decode as their payload
+func msgpackDecode(r *bufio.Reader) (interface{}, error) {
+	code, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code&0xf0 == 0x80:
+		return msgpackDecodeMap(r, int(code&0x0f))
+	case code&0xf0 == 0x90:
+		return msgpackDecodeArray(r, int(code&0x0f))
+	case code&0xe0 == 0xa0:
+		return msgpackReadString(r, int(code&0x1f))
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := msgpackReadLen(r, code-0xc4)
+		if err != nil {
+			return nil, err
+		}
+		return msgpackReadBytes(r, n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := msgpackReadLen(r, code-0xc7)
+		if err != nil {
+			return nil, err
+		}
+		return msgpackDecodeExt(r, n)
+	case 0xca:
+		b, err := msgpackReadBytes(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 0xcb:
+		b, err := msgpackReadBytes(r, 8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		size := 1 << (code - 0xcc)
+		b, err := msgpackReadBytes(r, size)
+		if err != nil {
+			return nil, err
+		}
+		var n uint64
+		for _, x := range b {
+			n = n<<8 | uint64(x)
+		}
+		return int64(n), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (code - 0xd0)
+		b, err := msgpackReadBytes(r, size)
+		if err != nil {
+			return nil, err
+		}
+		var n uint64
+		for _, x := range b {
+			n = n<<8 | uint64(x)
+		}
+		// sign extend from the encoded width
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return msgpackDecodeExt(r, 1<<(code-0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := msgpackReadLen(r, code-0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return msgpackReadString(r, n)
+	case 0xdc, 0xdd:
+		n, err := msgpackReadLen(r, code-0xdc+1)
+		if err != nil {
+			return nil, err
+		}
+		return msgpackDecodeArray(r, n)
+	case 0xde, 0xdf:
+		n, err := msgpackReadLen(r, code-0xde+1)
+		if err != nil {
+			return nil, err
+		}
+		return msgpackDecodeMap(r, n)
+	}
+
+	return nil, fmt.Errorf("msgpack: unsupported type code 0x%x", code)
+}
+
+// read a 1, 2 or 4 byte length (width 0, 1 or 2)
+func msgpackReadLen(r *bufio.Reader, width byte) (int, error) {
+	b, err := msgpackReadBytes(r, 1<<width)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, x := range b {
+		n = n<<8 | int(x)
+	}
+	return n, nil
+}
+
+func msgpackReadBytes(r *bufio.Reader, n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func msgpackReadString(r *bufio.Reader, n int) (string, error) {
+	b, err := msgpackReadBytes(r, n)
+	return string(b), err
+}
+
+func msgpackDecodeArray(r *bufio.Reader, n int) ([]interface{}, error) {
+	list := make([]interface{}, n)
+	for i := range list {
+		v, err := msgpackDecode(r)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = v
+	}
+	return list, nil
+}
+
+func msgpackDecodeMap(r *bufio.Reader, n int) (map[string]interface{}, error) {
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := msgpackDecode(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := msgpackDecode(r)
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(k)] = v
+	}
+	return m, nil
+}
+
+func msgpackDecodeExt(r *bufio.Reader, n int) (interface{}, error) {
+	// skip the ext type byte
+	if _, err := r.ReadByte(); err != nil {
+		return nil, err
+	}
+	payload, err := msgpackReadBytes(r, n)
+	if err != nil {
+		return nil, err
+	}
+	return msgpackDecode(bufio.NewReader(bytes.NewReader(payload)))
+}
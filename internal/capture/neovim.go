@@ -0,0 +1,382 @@
+package capture
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/internal/shell"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// lua run inside neovim to describe buffers, cursors and the window layout
+const neovimStateLua = `
+local function leaf(win)
+  local buf = vim.api.nvim_win_get_buf(win)
+  local cursor = vim.api.nvim_win_get_cursor(win)
+  return {type = "leaf", file = vim.api.nvim_buf_get_name(buf), line = cursor[1], column = cursor[2] + 1}
+end
+
+local function walk(node)
+  if node[1] == "leaf" then
+    return leaf(node[2])
+  end
+  local children = {}
+  for _, child in ipairs(node[2]) do
+    table.insert(children, walk(child))
+  end
+  return {type = node[1], children = children}
+end
+
+local buffers = {}
+for _, buf in ipairs(vim.api.nvim_list_bufs()) do
+  local name = vim.api.nvim_buf_get_name(buf)
+  if name ~= "" and vim.api.nvim_buf_is_loaded(buf) and vim.bo[buf].buflisted then
+    local mark = vim.api.nvim_buf_get_mark(buf, '"')
+    table.insert(buffers, {file = name, line = mark[1], column = mark[2] + 1})
+  end
+end
+
+return {cwd = vim.fn.getcwd(), buffers = buffers, layout = walk(vim.fn.winlayout())}
+`
+
+// neovimcapturer captures buffers and layout from a running neovim
+type NeovimCapturer struct {
+	sockets    []string // sockets to probe, empty means discover them
+	sessionDir string   // where session files are written, empty means ~/.workshot/sessions
+}
+
+// neovimdata is the state of a neovim instance and its session file
+type NeovimData struct {
+	Cwd         string         `json:"cwd"`
+	Buffers     []NeovimBuffer `json:"buffers"`
+	Layout      NeovimLayout   `json:"layout"`
+	SessionFile string         `json:"session_file,omitempty"`
+}
+
+// neovimbuffer is one listed buffer with its last cursor position
+type NeovimBuffer struct {
+	File   string `json:"file"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+// neovimlayout is a node of the window layout tree
+// leaf nodes hold a window, row and col nodes hold children
+type NeovimLayout struct {
+	Type     string         `json:"type"`
+	File     string         `json:"file,omitempty"`
+	Line     int            `json:"line,omitempty"`
+	Column   int            `json:"column,omitempty"`
+	Children []NeovimLayout `json:"children,omitempty"`
+}
+
+// newneovimcapturer creates a neovim capturer
+func NewNeovimCapturer() types.Capturer {
+	return plugin.Typed[NeovimData](&NeovimCapturer{})
+}
+
+func (n *NeovimCapturer) Name() string {
+	return "neovim"
+}
+
+func (n *NeovimCapturer) Priority() int {
+	return 25
+}
+
+func (n *NeovimCapturer) CaptureData(ctx context.Context) (*NeovimData, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	sockets := n.sockets
+	if len(sockets) == 0 {
+		sockets = findNeovimSockets()
+	}
+
+	for _, socket := range sockets {
+		result, err := neovimCall(ctx, socket, "nvim_exec_lua", neovimStateLua, []interface{}{})
+		if err != nil {
+			continue // stale socket or not a neovim server
+		}
+
+		// the state script replies with cwd, buffers and layout
+		state, err := plugin.Decode[NeovimData](result)
+		if err != nil {
+			continue
+		}
+
+		if !samePath(state.Cwd, cwd) {
+			continue
+		}
+
+		if state.SessionFile, err = n.sessionPath(cwd); err != nil {
+			return nil, err
+		}
+
+		return state, nil
+	}
+
+	return nil, nil
+}
+
+func (n *NeovimCapturer) RestoreData(ctx context.Context, data *NeovimData) error {
+	sessionFile := data.SessionFile
+	if sessionFile == "" {
+		var err error
+		if sessionFile, err = n.sessionPath(data.Cwd); err != nil {
+			return err
+		}
+	}
+
+	// the session lists the open files, so it is private like the snapshots
+	if err := os.MkdirAll(filepath.Dir(sessionFile), 0700); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	if err := os.WriteFile(sessionFile, []byte(neovimSessionScript(data)), 0600); err != nil {
+		return fmt.Errorf("failed to write neovim session: %w", err)
+	}
+	if err := os.Chmod(sessionFile, 0600); err != nil {
+		return fmt.Errorf("failed to write neovim session: %w", err)
+	}
+
+	return nil
+}
+
+func (n *NeovimCapturer) CanRestoreData(data *NeovimData) bool {
+	return len(data.Buffers) > 0 || data.Layout.Type != ""
+}
+
+// mappathsdata rewrites the paths in the data, used to store them relative to a repository
+func (n *NeovimCapturer) MapPathsData(data *NeovimData, f func(path string) string) {
+	data.Cwd = f(data.Cwd)
+	data.SessionFile = f(data.SessionFile)
+	for i := range data.Buffers {
+		data.Buffers[i].File = f(data.Buffers[i].File)
+	}
+	mapLayoutPaths(&data.Layout, f)
+}
+
+// maplayoutpaths rewrites the file of every window in the layout tree
+func mapLayoutPaths(layout *NeovimLayout, f func(path string) string) {
+	layout.File = f(layout.File)
+	for i := range layout.Children {
+		mapLayoutPaths(&layout.Children[i], f)
+	}
+}
+
+// the session file is up to date when it already holds the saved layout
+func (n *NeovimCapturer) PreviewData(ctx context.Context, data *NeovimData) []types.Step {
+	sessionFile := data.SessionFile
+	if sessionFile == "" {
+		var err error
+		if sessionFile, err = n.sessionPath(data.Cwd); err != nil {
+			return []types.Step{{Description: "write neovim session file"}}
+		}
+	}
+
+	step := types.Step{Description: "write session file " + sessionFile, Current: "missing", Target: "up to date"}
+	if existing, err := os.ReadFile(sessionFile); err == nil {
+		step.Current = "outdated"
+		if string(existing) == neovimSessionScript(data) {
+			step.Current = step.Target
+		}
+	}
+	return []types.Step{step}
+}
+
+func (n *NeovimCapturer) RenderData(data *NeovimData) *types.Section {
+	if len(data.Buffers) == 0 {
+		return nil
+	}
+
+	section := &types.Section{Title: "Neovim Buffers"}
+	if data.SessionFile != "" {
+		section.Fields = append(section.Fields, types.Field{Label: "Session", Value: "nvim -S " + shell.Quote(data.SessionFile)})
+	}
+	for _, buf := range data.Buffers {
+		section.Lines = append(section.Lines, buf.File)
+	}
+
+	return section
+}
+
+// helper functions
+
+// session file path for a working directory
+func (n *NeovimCapturer) sessionPath(dir string) (string, error) {
+	sessionDir := n.sessionDir
+	if sessionDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		sessionDir = filepath.Join(home, ".workshot", "sessions")
+	}
+
+	sum := sha1.Sum([]byte(filepath.Clean(dir)))
+	return filepath.Join(sessionDir, "nvim-"+hex.EncodeToString(sum[:])[:12]+".vim"), nil
+}
+
+// list candidate neovim server sockets
+func findNeovimSockets() []string {
+	var sockets []string
+
+	// set inside :terminal buffers of a running neovim
+	for _, env := range []string{"NVIM", "NVIM_LISTEN_ADDRESS"} {
+		if addr := os.Getenv(env); addr != "" {
+			sockets = append(sockets, addr)
+		}
+	}
+
+	if runtime.GOOS == "windows" {
+		return sockets // named pipes are not supported
+	}
+
+	var patterns []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		patterns = append(patterns, filepath.Join(dir, "nvim.*.0"))
+	}
+	if u, err := user.Current(); err == nil {
+		patterns = append(patterns, filepath.Join(os.TempDir(), "nvim."+u.Username, "*", "nvim.*.0"))
+	}
+
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		sockets = append(sockets, matches...)
+	}
+
+	return sockets
+}
+
+// call a neovim api method over its msgpack-rpc socket
+func neovimCall(ctx context.Context, socket, method string, params ...interface{}) (interface{}, error) {
+	dialer := net.Dialer{Timeout: time.Second}
+	conn, err := dialer.DialContext(ctx, "unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	const msgID = 1
+	request := []interface{}{0, msgID, method, params}
+	if err := msgpackEncode(conn, request); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	for {
+		msg, err := msgpackDecode(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		// skip notifications until our response arrives
+		fields, ok := msg.([]interface{})
+		if !ok || len(fields) != 4 || fields[0] != int64(1) || fields[1] != int64(msgID) {
+			continue
+		}
+
+		if fields[2] != nil {
+			return nil, fmt.Errorf("neovim error: %v", fields[2])
+		}
+		return fields[3], nil
+	}
+}
+
+// build a vim script that reopens buffers and rebuilds the window layout
+func neovimSessionScript(state *NeovimData) string {
+	var b strings.Builder
+	b.WriteString("\" workshot neovim session\n")
+	if state.Cwd != "" {
+		fmt.Fprintf(&b, "execute 'cd ' . fnameescape(%s)\n", vimString(state.Cwd))
+	}
+
+	for _, buf := range state.Buffers {
+		fmt.Fprintf(&b, "execute 'badd +%d ' . fnameescape(%s)\n", max(buf.Line, 1), vimString(buf.File))
+	}
+
+	b.WriteString("silent! only\n")
+	counter := 0
+	writeNeovimLayout(&b, state.Layout, &counter)
+
+	return b.String()
+}
+
+// rebuild one layout node in the current window
+func writeNeovimLayout(b *strings.Builder, node NeovimLayout, counter *int) {
+	switch node.Type {
+	case "leaf":
+		if node.File == "" {
+			b.WriteString("enew\n")
+			return
+		}
+		fmt.Fprintf(b, "execute 'edit ' . fnameescape(%s)\n", vimString(node.File))
+		if node.Line > 0 {
+			fmt.Fprintf(b, "call cursor(%d, %d)\n", node.Line, max(node.Column, 1))
+		}
+	case "row", "col":
+		if len(node.Children) == 0 {
+			return
+		}
+
+		split := "vsplit"
+		if node.Type == "col" {
+			split = "split"
+		}
+
+		// split first, then fill each window
+		ids := make([]string, len(node.Children))
+		for i := range node.Children {
+			*counter++
+			ids[i] = fmt.Sprintf("s:w%d", *counter)
+			if i > 0 {
+				fmt.Fprintf(b, "rightbelow %s\n", split)
+			}
+			fmt.Fprintf(b, "let %s = win_getid()\n", ids[i])
+		}
+
+		for i, child := range node.Children {
+			fmt.Fprintf(b, "call win_gotoid(%s)\n", ids[i])
+			writeNeovimLayout(b, child, counter)
+		}
+	}
+}
+
+// vimstring quotes s as a vim string literal, for fnameescape to escape
+// it as a file name, so no name can end the line or run a command
+func vimString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '"':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,201 @@
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	value := []interface{}{
+		int64(0), int64(-5), int64(300), int64(-70000), "short", strings.Repeat("x", 40),
+		true, nil, 1.5,
+		map[string]interface{}{"k": []interface{}{int64(1), "v"}},
+	}
+
+	var buf bytes.Buffer
+	if err := msgpackEncode(&buf, value); err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	decoded, err := msgpackDecode(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	list, ok := decoded.([]interface{})
+	if !ok || len(list) != len(value) {
+		t.Fatalf("Expected %d element list, got %#v", len(value), decoded)
+	}
+	for i := 0; i < 9; i++ {
+		if list[i] != value[i] {
+			t.Errorf("element %d = %#v, want %#v", i, list[i], value[i])
+		}
+	}
+
+	// neovim sends handles as ext values wrapping an integer
+	ext, err := msgpackDecode(bufio.NewReader(bytes.NewReader([]byte{0xd4, 0x01, 0x2a})))
+	if err != nil || ext != int64(42) {
+		t.Errorf("Expected ext handle 42, got %#v (%v)", ext, err)
+	}
+}
+
+// serve one msgpack-rpc request like neovim would
+func serveFakeNeovim(t *testing.T, socket string, result map[string]interface{}) {
+	t.Helper()
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			msg, err := msgpackDecode(bufio.NewReader(conn))
+			request, ok := msg.([]interface{})
+			if err != nil || !ok || len(request) != 4 || request[2] != "nvim_exec_lua" {
+				conn.Close()
+				continue
+			}
+
+			// a notification first, then the response
+			msgpackEncode(conn, []interface{}{int64(2), "redraw", []interface{}{}})
+			msgpackEncode(conn, []interface{}{int64(1), request[1], nil, result})
+			conn.Close()
+		}
+	}()
+}
+
+func TestNeovimCaptureRestore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("neovim named pipes are not supported")
+	}
+
+	projectDir := t.TempDir()
+	t.Chdir(projectDir)
+
+	mainFile := filepath.Join(projectDir, "main.go")
+	testFile := filepath.Join(projectDir, "main_test.go")
+
+	socketDir, err := os.MkdirTemp("", "nvim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(socketDir) })
+
+	otherSocket := filepath.Join(socketDir, "other.sock")
+	serveFakeNeovim(t, otherSocket, map[string]interface{}{
+		"cwd":     "/somewhere/else",
+		"buffers": []interface{}{},
+		"layout":  map[string]interface{}{"type": "leaf", "file": ""},
+	})
+
+	socket := filepath.Join(socketDir, "nvim.sock")
+	serveFakeNeovim(t, socket, map[string]interface{}{
+		"cwd": projectDir,
+		"buffers": []interface{}{
+			map[string]interface{}{"file": mainFile, "line": int64(12), "column": int64(3)},
+			map[string]interface{}{"file": testFile, "line": int64(1), "column": int64(1)},
+		},
+		"layout": map[string]interface{}{
+			"type": "row",
+			"children": []interface{}{
+				map[string]interface{}{"type": "leaf", "file": mainFile, "line": int64(12), "column": int64(3)},
+				map[string]interface{}{"type": "leaf", "file": testFile, "line": int64(4), "column": int64(1)},
+			},
+		},
+	})
+
+	nc := plugin.Typed[NeovimData](&NeovimCapturer{
+		sockets:    []string{filepath.Join(socketDir, "missing.sock"), otherSocket, socket},
+		sessionDir: t.TempDir(),
+	})
+
+	data, err := nc.Capture(t.Context())
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+	if data == nil {
+		t.Fatal("Expected neovim data for the working directory")
+	}
+	if !nc.CanRestore(data) {
+		t.Fatal("Expected captured data to be restorable")
+	}
+
+	preview := types.PluginPreview{Steps: nc.(types.Previewer).Preview(t.Context(), data)}
+	if !preview.Changes() || preview.Steps[0].Current != "missing" {
+		t.Errorf("Expected a missing session file to be written, got %+v", preview.Steps)
+	}
+
+	if err := nc.Restore(t.Context(), data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	preview = types.PluginPreview{Steps: nc.(types.Previewer).Preview(t.Context(), data)}
+	if preview.Changes() {
+		t.Errorf("Expected session file to be up to date after restore, got %+v", preview.Steps)
+	}
+
+	sessionFile, _ := data["session_file"].(string)
+	session, err := os.ReadFile(sessionFile)
+	if err != nil {
+		t.Fatalf("Failed to read session file: %v", err)
+	}
+	if info, err := os.Stat(sessionFile); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the session file to be private, got %v (%v)", info.Mode(), err)
+	}
+
+	for _, want := range []string{
+		"execute 'badd +12 ' . fnameescape(" + vimString(mainFile) + ")",
+		"rightbelow vsplit",
+		"execute 'edit ' . fnameescape(" + vimString(testFile) + ")",
+		"call cursor(12, 3)",
+	} {
+		if !strings.Contains(string(session), want) {
+			t.Errorf("Session file missing %q:\n%s", want, session)
+		}
+	}
+}
+
+func TestNeovimCaptureWithoutServer(t *testing.T) {
+	nc := plugin.Typed[NeovimData](&NeovimCapturer{sockets: []string{filepath.Join(t.TempDir(), "none.sock")}})
+
+	data, err := nc.Capture(t.Context())
+	if err != nil || data != nil {
+		t.Errorf("Expected nothing captured, got %v, %v", data, err)
+	}
+}
+
+func TestNeovimSessionHostileNames(t *testing.T) {
+	name := "/tmp/x\n!touch /tmp/pwned\n\" $HOME *.go | echo \\ %"
+	script := neovimSessionScript(&NeovimData{
+		Cwd:     "/tmp/a\nqa!",
+		Buffers: []NeovimBuffer{{File: name}},
+		Layout:  NeovimLayout{Type: "leaf", File: name},
+	})
+
+	// every line is one the script writes itself
+	for _, line := range strings.Split(strings.TrimSpace(script), "\n") {
+		if !strings.HasPrefix(line, "\"") && !strings.HasPrefix(line, "execute ") && line != "silent! only" {
+			t.Errorf("Unexpected line %q in the session:\n%s", line, script)
+		}
+	}
+	want := `fnameescape("/tmp/x\x0a!touch /tmp/pwned\x0a\" $HOME *.go | echo \\ %")`
+	if !strings.Contains(script, want) {
+		t.Errorf("Expected the name quoted as %s, got:\n%s", want, script)
+	}
+}
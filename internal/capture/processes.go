@@ -0,0 +1,426 @@
+package capture
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/internal/shell"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// interactive shells are part of the terminal, not the project
+var shellNames = map[string]bool{
+	"bash": true, "zsh": true, "fish": true, "sh": true, "dash": true,
+	"ksh": true, "tcsh": true, "csh": true, "pwsh": true, "nu": true,
+}
+
+var socketLink = regexp.MustCompile(`^socket:\[(\d+)\]$`)
+
+// processescapturer captures processes running inside the working directory
+type ProcessesCapturer struct {
+	procRoot string                    // proc filesystem, "/proc" unless testing
+	confirm  func(command string) bool // asks before relaunching, nil disables restore
+}
+
+// processesdata is the list of captured processes
+type ProcessesData struct {
+	Processes []ProcessInfo `json:"processes"`
+}
+
+// processinfo is one captured process
+type ProcessInfo struct {
+	PID     int      `json:"pid"`
+	Command []string `json:"command"`
+	Cwd     string   `json:"cwd"`
+	Ports   []int    `json:"ports,omitempty"`
+}
+
+// newprocessescapturer creates a processes capturer
+// confirm is asked for each process on restore; nil only prints them
+func NewProcessesCapturer(confirm func(command string) bool) types.Capturer {
+	return plugin.Typed[ProcessesData](&ProcessesCapturer{
+		procRoot: "/proc",
+		confirm:  confirm,
+	})
+}
+
+func (p *ProcessesCapturer) Name() string {
+	return "processes"
+}
+
+func (p *ProcessesCapturer) Priority() int {
+	return 60
+}
+
+func (p *ProcessesCapturer) CaptureData(ctx context.Context) (*ProcessesData, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil // needs /proc
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	processes := p.scan(cwd)
+	if len(processes) == 0 {
+		return nil, nil
+	}
+
+	return &ProcessesData{Processes: processes}, nil
+}
+
+func (p *ProcessesCapturer) RestoreData(ctx context.Context, data *ProcessesData) error {
+	// skip commands that are already running again
+	running := p.running()
+
+	var failed []string
+	for _, proc := range data.Processes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(proc.Command) == 0 || running[processKey(proc)] {
+			continue
+		}
+
+		if !p.confirm(formatProcessCommand(proc)) {
+			continue
+		}
+
+		if err := relaunchProcess(proc); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", proc.Command[0], err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to relaunch %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (p *ProcessesCapturer) CanRestoreData(data *ProcessesData) bool {
+	return p.confirm != nil && len(data.Processes) > 0
+}
+
+// mappathsdata rewrites the paths in the data, used to store them relative to a repository
+func (p *ProcessesCapturer) MapPathsData(data *ProcessesData, f func(path string) string) {
+	// the command line is left alone, only the directory is a path we know
+	for i := range data.Processes {
+		data.Processes[i].Cwd = f(data.Processes[i].Cwd)
+	}
+}
+
+func (p *ProcessesCapturer) PreviewData(ctx context.Context, data *ProcessesData) []types.Step {
+	running := p.running()
+
+	var steps []types.Step
+	for _, proc := range data.Processes {
+		if len(proc.Command) == 0 {
+			continue
+		}
+
+		step := types.Step{Description: "relaunch " + formatProcessCommand(proc), Current: "stopped", Target: "running"}
+		if running[processKey(proc)] {
+			step.Current = "running"
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// processcommands returns shell commands that relaunch saved processes
+func ProcessCommands(data *ProcessesData) []string {
+	commands := make([]string, 0, len(data.Processes))
+	for _, proc := range data.Processes {
+		if len(proc.Command) > 0 {
+			commands = append(commands, formatProcessCommand(proc))
+		}
+	}
+	return commands
+}
+
+func (p *ProcessesCapturer) RenderData(data *ProcessesData) *types.Section {
+	if len(data.Processes) == 0 {
+		return nil
+	}
+
+	section := &types.Section{Title: "Processes"}
+	for _, proc := range data.Processes {
+		line := strings.Join(proc.Command, " ")
+		if len(proc.Ports) > 0 {
+			line += fmt.Sprintf(" (listening on %v)", proc.Ports)
+		}
+		section.Lines = append(section.Lines, line)
+	}
+
+	section.Hints = append([]string{"Use --relaunch to start them again, or run:"}, ProcessCommands(data)...)
+	return section
+}
+
+// helper functions
+
+// commands currently running, keyed by processkey
+func (p *ProcessesCapturer) running() map[string]bool {
+	running := make(map[string]bool)
+	for _, proc := range p.listProcesses() {
+		running[processKey(proc)] = true
+	}
+	return running
+}
+
+// a process is identified by its directory and command line
+func processKey(proc ProcessInfo) string {
+	return proc.Cwd + "\x00" + strings.Join(proc.Command, "\x00")
+}
+
+// find processes whose cwd is inside dir, with their listening ports
+func (p *ProcessesCapturer) scan(dir string) []ProcessInfo {
+	dir = filepath.Clean(dir)
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+
+	ports := p.listeningPorts()
+	skip := p.ownAncestry()
+
+	var result []ProcessInfo
+	for _, proc := range p.listProcesses() {
+		if skip[proc.PID] || !IsWithin(proc.Cwd, dir) {
+			continue
+		}
+		// other plugins run commands while the scan is in progress
+		if ppid, ok := p.parentPID(proc.PID); ok && ppid == os.Getpid() {
+			continue
+		}
+		if shellNames[strings.TrimPrefix(filepath.Base(proc.Command[0]), "-")] {
+			continue
+		}
+
+		proc.Ports = p.processPorts(proc.PID, ports)
+		result = append(result, proc)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PID < result[j].PID
+	})
+	return result
+}
+
+// list all readable processes with their command line and cwd
+func (p *ProcessesCapturer) listProcesses() []ProcessInfo {
+	entries, err := os.ReadDir(p.procRoot)
+	if err != nil {
+		return nil
+	}
+
+	var processes []ProcessInfo
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		procDir := filepath.Join(p.procRoot, entry.Name())
+		cwd, err := os.Readlink(filepath.Join(procDir, "cwd"))
+		if err != nil {
+			continue // other users' processes are not readable
+		}
+
+		cmdline, err := os.ReadFile(filepath.Join(procDir, "cmdline"))
+		if err != nil {
+			continue
+		}
+
+		command := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+		if len(command) == 0 || command[0] == "" {
+			continue // kernel threads have no command line
+		}
+
+		processes = append(processes, ProcessInfo{
+			PID:     pid,
+			Command: command,
+			Cwd:     cwd,
+		})
+	}
+
+	return processes
+}
+
+// map socket inode to port for all listening tcp sockets
+func (p *ProcessesCapturer) listeningPorts() map[string]int {
+	ports := make(map[string]int)
+	for _, name := range []string{"tcp", "tcp6"} {
+		file, err := os.Open(filepath.Join(p.procRoot, "net", name))
+		if err != nil {
+			continue
+		}
+		for inode, port := range parseListeningSockets(file) {
+			ports[inode] = port
+		}
+		file.Close()
+	}
+	return ports
+}
+
+// parse /proc/net/tcp and return listening socket inodes with their port
+func parseListeningSockets(r io.Reader) map[string]int {
+	const listenState = "0A"
+
+	ports := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // header
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != listenState {
+			continue
+		}
+
+		// local_address is hex ip:port
+		_, portHex, found := strings.Cut(fields[1], ":")
+		if !found {
+			continue
+		}
+		port, err := strconv.ParseInt(portHex, 16, 32)
+		if err != nil {
+			continue
+		}
+
+		ports[fields[9]] = int(port)
+	}
+
+	return ports
+}
+
+// get the listening ports a process holds open
+func (p *ProcessesCapturer) processPorts(pid int, ports map[string]int) []int {
+	fdDir := filepath.Join(p.procRoot, strconv.Itoa(pid), "fd")
+	entries, err := os.ReadDir(fdDir)
+	if err != nil {
+		return nil
+	}
+
+	var result []int
+	for _, entry := range entries {
+		link, err := os.Readlink(filepath.Join(fdDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		match := socketLink.FindStringSubmatch(link)
+		if match == nil {
+			continue
+		}
+
+		if port, ok := ports[match[1]]; ok && !slices.Contains(result, port) {
+			result = append(result, port)
+		}
+	}
+
+	sort.Ints(result)
+	return result
+}
+
+// pids of workshot itself and its parents, which are never captured
+func (p *ProcessesCapturer) ownAncestry() map[int]bool {
+	skip := make(map[int]bool)
+	for pid := os.Getpid(); pid > 1 && !skip[pid]; {
+		skip[pid] = true
+
+		parent, ok := p.parentPID(pid)
+		if !ok {
+			break
+		}
+		pid = parent
+	}
+	return skip
+}
+
+// read the parent pid of a process from its stat file
+func (p *ProcessesCapturer) parentPID(pid int) (int, bool) {
+	stat, err := os.ReadFile(filepath.Join(p.procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, false
+	}
+
+	// the command name may contain spaces, so parse after the last ')'
+	i := strings.LastIndexByte(string(stat), ')')
+	if i < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(string(stat)[i+1:])
+	if len(fields) < 2 {
+		return 0, false
+	}
+
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, false
+	}
+	return ppid, true
+}
+
+// start a process in the background, logging to ~/.workshot/logs
+func relaunchProcess(proc ProcessInfo) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+
+	// the output of the commands is private, like the snapshots
+	logDir := filepath.Join(home, ".workshot", "logs")
+	if err := os.MkdirAll(logDir, 0700); err != nil {
+		return err
+	}
+
+	logName := fmt.Sprintf("%s-%d.log", filepath.Base(proc.Command[0]), time.Now().Unix())
+	logFile, err := os.OpenFile(filepath.Join(logDir, logName), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(proc.Command[0], proc.Command[1:]...)
+	cmd.Dir = proc.Cwd
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	// outlive the terminal that ran restore
+	detach(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// format a process as a background shell command
+func formatProcessCommand(proc ProcessInfo) string {
+	quoted := make([]string, len(proc.Command))
+	for i, arg := range proc.Command {
+		quoted[i] = shell.Quote(arg)
+	}
+	return fmt.Sprintf("(cd %s && %s &)", shell.Quote(proc.Cwd), strings.Join(quoted, " "))
+}
+
+// iswithin reports whether path is dir or inside it
+func IsWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || filepath.IsAbs(rel) {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,275 @@
+package capture
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+const testProcNetTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0BB8 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 4242 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:D2A4 01 00000000:00000000 00:00000000 00000000  1000        0 4343 1 0000000000000000 20 4 30 10 -1
+`
+
+// build a fake /proc entry
+func fakeProcess(t *testing.T, root string, pid, cwd string, cmdline []string, sockets ...string) {
+	t.Helper()
+
+	dir := filepath.Join(root, pid)
+	if err := os.MkdirAll(filepath.Join(dir, "fd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(cwd, filepath.Join(dir, "cwd")); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "cmdline"), []byte(strings.Join(cmdline, "\x00")+"\x00"), 0644)
+
+	for i, inode := range sockets {
+		os.Symlink("socket:["+inode+"]", filepath.Join(dir, "fd", string(rune('3'+i))))
+	}
+}
+
+func TestProcessesCapturerName(t *testing.T) {
+	pc := NewProcessesCapturer(nil)
+
+	if pc.Name() != "processes" {
+		t.Errorf("Expected name 'processes', got %s", pc.Name())
+	}
+}
+
+func TestParseListeningSockets(t *testing.T) {
+	ports := parseListeningSockets(strings.NewReader(testProcNetTCP))
+
+	if len(ports) != 1 || ports["4242"] != 3000 {
+		t.Errorf("Expected only inode 4242 listening on 3000, got %v", ports)
+	}
+}
+
+func TestProcessesScan(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake proc tree uses symlinks")
+	}
+
+	procRoot := t.TempDir()
+	projectDir := t.TempDir()
+	otherDir := t.TempDir()
+
+	os.MkdirAll(filepath.Join(procRoot, "net"), 0755)
+	os.WriteFile(filepath.Join(procRoot, "net", "tcp"), []byte(testProcNetTCP), 0644)
+
+	fakeProcess(t, procRoot, "100", projectDir, []string{"npm", "run", "dev"}, "4242", "4343")
+	fakeProcess(t, procRoot, "101", filepath.Join(projectDir, "api"), []string{"go", "run", "."})
+	fakeProcess(t, procRoot, "102", projectDir, []string{"-zsh"})
+	fakeProcess(t, procRoot, "103", otherDir, []string{"vim"})
+	fakeProcess(t, procRoot, "104", projectDir+"-old", []string{"make", "watch"})
+	fakeProcess(t, procRoot, "105", projectDir, []string{"git", "status"})
+
+	// commands started by other plugins are children of workshot
+	stat := fmt.Sprintf("105 (git) S %d 105 105 0", os.Getpid())
+	os.WriteFile(filepath.Join(procRoot, "105", "stat"), []byte(stat), 0644)
+
+	pc := &ProcessesCapturer{procRoot: procRoot}
+	processes := pc.scan(projectDir)
+
+	if len(processes) != 2 {
+		t.Fatalf("Expected 2 processes, got %d: %v", len(processes), processes)
+	}
+
+	if strings.Join(processes[0].Command, " ") != "npm run dev" {
+		t.Errorf("Expected npm run dev first, got %v", processes[0].Command)
+	}
+	if len(processes[0].Ports) != 1 || processes[0].Ports[0] != 3000 {
+		t.Errorf("Expected npm listening on 3000, got %v", processes[0].Ports)
+	}
+	if processes[1].Cwd != filepath.Join(projectDir, "api") {
+		t.Errorf("Expected subdirectory process, got cwd %s", processes[1].Cwd)
+	}
+}
+
+func TestProcessesRestore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("relaunch test uses a posix shell")
+	}
+
+	t.Setenv("HOME", t.TempDir())
+	projectDir := t.TempDir()
+	marker := filepath.Join(projectDir, "started")
+
+	data := map[string]interface{}{
+		"processes": []interface{}{
+			map[string]interface{}{"pid": 1, "cwd": projectDir, "command": []interface{}{"sh", "-c", "touch started"}},
+			map[string]interface{}{"pid": 2, "cwd": projectDir, "command": []interface{}{"sh", "-c", "touch skipped"}},
+		},
+	}
+
+	if NewProcessesCapturer(nil).CanRestore(data) {
+		t.Error("Restore should be disabled without a confirm function")
+	}
+
+	var asked []string
+	pc := plugin.Typed[ProcessesData](&ProcessesCapturer{
+		procRoot: t.TempDir(),
+		confirm: func(command string) bool {
+			asked = append(asked, command)
+			return strings.Contains(command, "started")
+		},
+	})
+
+	if !pc.CanRestore(data) {
+		t.Fatal("Expected restore to be enabled")
+	}
+	if err := pc.Restore(t.Context(), data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	if len(asked) != 2 {
+		t.Errorf("Expected to be asked about 2 processes, got %v", asked)
+	}
+
+	for i := 0; i < 50; i++ {
+		if _, err := os.Stat(marker); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("Confirmed process was not relaunched")
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, "skipped")); err == nil {
+		t.Error("Declined process should not be relaunched")
+	}
+
+	typed, err := plugin.Decode[ProcessesData](data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	commands := ProcessCommands(typed)
+	if len(commands) != 2 || commands[0] != "(cd "+projectDir+" && sh -c 'touch started' &)" {
+		t.Errorf("Unexpected relaunch commands: %v", commands)
+	}
+
+	// relaunch commands are only hints, never part of a restore plan
+	section := (&ProcessesCapturer{}).RenderData(typed)
+	if len(section.Lines) != 2 || len(section.Hints) != 3 {
+		t.Errorf("Unexpected processes section: %+v", section)
+	}
+}
+
+func TestProcessesPreview(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake proc tree uses symlinks")
+	}
+
+	procRoot := t.TempDir()
+	projectDir := t.TempDir()
+	fakeProcess(t, procRoot, "100", projectDir, []string{"npm", "run", "dev"})
+
+	pc := &ProcessesCapturer{procRoot: procRoot}
+	steps := pc.PreviewData(t.Context(), &ProcessesData{Processes: []ProcessInfo{
+		{PID: 1, Cwd: projectDir, Command: []string{"npm", "run", "dev"}},
+		{PID: 2, Cwd: projectDir, Command: []string{"go", "run", "."}},
+	}})
+
+	if len(steps) != 2 {
+		t.Fatalf("Expected 2 steps, got %+v", steps)
+	}
+	if !steps[0].Noop() {
+		t.Errorf("Running process should not be relaunched: %+v", steps[0])
+	}
+	if steps[1].Noop() || steps[1].Current != "stopped" || !strings.Contains(steps[1].Description, "go run .") {
+		t.Errorf("Unexpected step for stopped process: %+v", steps[1])
+	}
+}
+
+func TestRelaunchDetaches(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads the session from /proc")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	projectDir := t.TempDir()
+
+	// the relaunched shell writes its session id
+	proc := ProcessInfo{Cwd: projectDir, Command: []string{"sh", "-c", "cut -d' ' -f6 /proc/$$/stat > session"}}
+	if err := relaunchProcess(proc); err != nil {
+		t.Fatalf("Relaunch failed: %v", err)
+	}
+
+	path := filepath.Join(projectDir, "session")
+	var session string
+	for i := 0; i < 50 && session == ""; i++ {
+		time.Sleep(20 * time.Millisecond)
+		data, _ := os.ReadFile(path)
+		session = strings.TrimSpace(string(data))
+	}
+
+	stat, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ours := strings.Fields(string(stat))[5]; session == "" || session == ours {
+		t.Errorf("Expected the process in a new session, got %q (ours %s)", session, ours)
+	}
+
+	// its output is only readable by the user
+	logDir := filepath.Join(home, ".workshot", "logs")
+	logs, _ := filepath.Glob(filepath.Join(logDir, "sh-*.log"))
+	if info, err := os.Stat(logDir); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("Expected a private log directory, got %v (%v)", info, err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("Expected one log, got %v", logs)
+	}
+	if info, err := os.Stat(logs[0]); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a private log, got %v (%v)", info, err)
+	}
+}
+
+func TestIsWithin(t *testing.T) {
+	dir := filepath.Join(string(filepath.Separator), "code", "api")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{dir, true},
+		{filepath.Join(dir, "cmd", "main.go"), true},
+		{filepath.Join(dir, "..", "api-old"), false},
+		{filepath.Join(dir, ".."), false},
+		{filepath.Join(dir, "..config"), true},
+	}
+
+	for _, tt := range tests {
+		if got := IsWithin(tt.path, dir); got != tt.want {
+			t.Errorf("IsWithin(%q, %q) = %v, want %v", tt.path, dir, got, tt.want)
+		}
+	}
+}
+
+func TestProcessesMapPaths(t *testing.T) {
+	data := map[string]interface{}{
+		"processes": []interface{}{
+			map[string]interface{}{"pid": 1, "command": []interface{}{"go", "run", "/src/api/main.go"}, "cwd": "/src/api"},
+		},
+	}
+
+	mapped := NewProcessesCapturer(nil).(types.PathMapper).MapPaths(data, func(path string) string {
+		return strings.Replace(path, "/src/api", "${repo}", 1)
+	})
+
+	process := mapped["processes"].([]interface{})[0].(map[string]interface{})
+	if process["cwd"] != "${repo}" {
+		t.Errorf("Expected the directory to be mapped, got %v", process["cwd"])
+	}
+	if command := process["command"].([]interface{}); command[2] != "/src/api/main.go" {
+		t.Errorf("Expected the command line untouched, got %v", command)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package capture
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach starts the process in a new session, away from the terminal's
+// process group and its hangup
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,14 @@
+package capture
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach starts the process without a console and in its own process
+// group, so closing the terminal does not stop it
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | 0x00000008, // DETACHED_PROCESS
+	}
+}
@@ -2,12 +2,14 @@ package capture
 
 import (
     "bufio"
+    "context"
     "os"
     "path/filepath"
     "regexp"
     "runtime"
     "strings"
 
+    "github.com/ansoncodes/workshot/internal/plugin"
     "github.com/ansoncodes/workshot/pkg/types"
 )
 
@@ -15,10 +17,15 @@ type TerminalCapturer struct {
     maxCommands int
 }
 
+// terminaldata is the saved shell history
+type TerminalData struct {
+    RecentCommands []string `json:"recent_commands"`
+}
+
 func NewTerminalCapturer() types.Capturer {
-    return &TerminalCapturer{
+    return plugin.Typed[TerminalData](&TerminalCapturer{
         maxCommands: 20,
-    }
+    })
 }
 
 func (t *TerminalCapturer) Name() string {
@@ -29,26 +36,37 @@ func (t *TerminalCapturer) Priority() int {
     return 30
 }
 
-func (t *TerminalCapturer) Capture() (map[string]interface{}, error) {
+func (t *TerminalCapturer) CaptureData(ctx context.Context) (*TerminalData, error) {
     commands := t.getRecentCommands()
     if len(commands) == 0 {
         return nil, nil
     }
 
-    data := make(map[string]interface{})
-    data["recent_commands"] = commands
-
-    return data, nil
+    return &TerminalData{RecentCommands: commands}, nil
 }
 
-func (t *TerminalCapturer) Restore(data map[string]interface{}) error {
+func (t *TerminalCapturer) RestoreData(ctx context.Context, data *TerminalData) error {
     return nil
 }
 
-func (t *TerminalCapturer) CanRestore(data map[string]interface{}) bool {
+func (t *TerminalCapturer) CanRestoreData(data *TerminalData) bool {
     return false
 }
 
+// show the last few commands, oldest first
+func (t *TerminalCapturer) RenderData(data *TerminalData) *types.Section {
+    if len(data.RecentCommands) == 0 {
+        return nil
+    }
+
+    commands := data.RecentCommands
+    if len(commands) > 10 {
+        commands = commands[len(commands)-10:]
+    }
+
+    return &types.Section{Title: "Recent Commands", Lines: commands}
+}
+
 func (t *TerminalCapturer) getRecentCommands() []string {
     home, err := os.UserHomeDir()
     if err != nil {
@@ -5,7 +5,7 @@ import (
 )
 
 func TestTerminalSensitiveFiltering(t *testing.T) {
-	tc := NewTerminalCapturer().(*TerminalCapturer)
+	tc := &TerminalCapturer{maxCommands: 20}
 
 	tests := []struct {
 		command   string
@@ -73,7 +73,7 @@ func TestTerminalCanRestore(t *testing.T) {
 }
 
 func TestTerminalCleanHistoryLine(t *testing.T) {
-	tc := NewTerminalCapturer().(*TerminalCapturer)
+	tc := &TerminalCapturer{maxCommands: 20}
 	
 	tests := []struct {
 		input    string
@@ -0,0 +1,342 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/internal/shell"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// tmuxcapturer captures and restores tmux session layouts
+type TmuxCapturer struct {
+	socket  string // tmux -L socket name, empty uses the default server
+	session string // session to capture, empty uses the current one
+}
+
+// tmuxdata is a captured session with its windows
+type TmuxData struct {
+	Session string       `json:"session"`
+	Windows []TmuxWindow `json:"windows"`
+}
+
+// tmuxwindow is one window of a captured session
+type TmuxWindow struct {
+	Index  int        `json:"index"`
+	Name   string     `json:"name"`
+	Layout string     `json:"layout"`
+	Active bool       `json:"active,omitempty"`
+	Panes  []TmuxPane `json:"panes"`
+}
+
+// tmuxpane is one pane of a captured window
+type TmuxPane struct {
+	Index   int    `json:"index"`
+	Path    string `json:"path"`
+	Command string `json:"command,omitempty"`
+	Active  bool   `json:"active,omitempty"`
+}
+
+// newtmuxcapturer creates a tmux capturer
+func NewTmuxCapturer() types.Capturer {
+	return plugin.Typed[TmuxData](&TmuxCapturer{})
+}
+
+func (t *TmuxCapturer) Name() string {
+	return "tmux"
+}
+
+func (t *TmuxCapturer) Priority() int {
+	return 40
+}
+
+func (t *TmuxCapturer) CaptureData(ctx context.Context) (*TmuxData, error) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		return nil, nil // tmux not installed
+	}
+
+	session := t.session
+	if session == "" {
+		// only capture when running inside tmux
+		if os.Getenv("TMUX") == "" {
+			return nil, nil
+		}
+
+		out, err := t.tmux(ctx, "display-message", "-p", "#{session_name}")
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect tmux session: %w", err)
+		}
+		session = out
+	}
+
+	windows, err := t.listWindows(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	if len(windows) == 0 {
+		return nil, nil
+	}
+
+	return &TmuxData{Session: session, Windows: windows}, nil
+}
+
+func (t *TmuxCapturer) RestoreData(ctx context.Context, data *TmuxData) error {
+	session := data.Session
+	windows := data.Windows
+
+	// leave a running session alone
+	if _, err := t.tmux(ctx, "has-session", "-t", exactSession(session)); err == nil {
+		return nil
+	}
+
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].Index < windows[j].Index
+	})
+
+	activeWindow := ""
+	created := false
+	for _, win := range windows {
+		if len(win.Panes) == 0 {
+			continue
+		}
+
+		// the first window with panes creates the session
+		var target string
+		if !created {
+			args := []string{"new-session", "-d", "-P", "-F", "#{window_id}",
+				"-s", session, "-n", win.Name, "-c", win.Panes[0].Path}
+			if width, height, ok := layoutSize(win.Layout); ok {
+				args = append(args, "-x", strconv.Itoa(width), "-y", strconv.Itoa(height))
+			}
+
+			out, err := t.tmux(ctx, args...)
+			if err != nil {
+				return fmt.Errorf("failed to create session '%s': %w", session, err)
+			}
+			target = out
+			created = true
+		} else {
+			out, err := t.tmux(ctx, "new-window", "-d", "-P", "-F", "#{window_id}",
+				"-t", exactSession(session)+":", "-n", win.Name, "-c", win.Panes[0].Path)
+			if err != nil {
+				return fmt.Errorf("failed to create window '%s': %w", win.Name, err)
+			}
+			target = out
+		}
+
+		// recreate the remaining panes, then apply the saved layout
+		for _, pane := range win.Panes[1:] {
+			if _, err := t.tmux(ctx, "split-window", "-d", "-t", target, "-c", pane.Path); err != nil {
+				return fmt.Errorf("failed to split window '%s': %w", win.Name, err)
+			}
+		}
+
+		if win.Layout != "" {
+			if _, err := t.tmux(ctx, "select-layout", "-t", target, win.Layout); err != nil {
+				return fmt.Errorf("failed to apply layout to window '%s': %w", win.Name, err)
+			}
+		}
+
+		if win.Active {
+			activeWindow = target
+		}
+	}
+
+	if activeWindow != "" {
+		t.tmux(ctx, "select-window", "-t", activeWindow)
+	}
+
+	return nil
+}
+
+func (t *TmuxCapturer) CanRestoreData(data *TmuxData) bool {
+	if data.Session == "" || len(data.Windows) == 0 {
+		return false
+	}
+
+	_, err := exec.LookPath("tmux")
+	return err == nil
+}
+
+// mappathsdata rewrites the paths in the data, used to store them relative to a repository
+func (t *TmuxCapturer) MapPathsData(data *TmuxData, f func(path string) string) {
+	for i := range data.Windows {
+		for j := range data.Windows[i].Panes {
+			data.Windows[i].Panes[j].Path = f(data.Windows[i].Panes[j].Path)
+		}
+	}
+}
+
+func (t *TmuxCapturer) PreviewData(ctx context.Context, data *TmuxData) []types.Step {
+	windows := fmt.Sprintf("%d windows", len(data.Windows))
+	if len(data.Windows) == 1 {
+		windows = "1 window"
+	}
+
+	step := types.Step{
+		Description: fmt.Sprintf("create session '%s' with %s", data.Session, windows),
+		Current:     "not running",
+		Target:      "running",
+	}
+	if _, err := t.tmux(ctx, "has-session", "-t", exactSession(data.Session)); err == nil {
+		step.Current = "running"
+	}
+	return []types.Step{step}
+}
+
+func (t *TmuxCapturer) RenderData(data *TmuxData) *types.Section {
+	if data.Session == "" {
+		return nil
+	}
+
+	section := &types.Section{
+		Title: "Tmux Session",
+		Fields: []types.Field{
+			{Label: "Session", Value: data.Session},
+			{Label: "Attach", Value: "tmux attach -t " + shell.Quote(data.Session)},
+		},
+	}
+	for _, win := range data.Windows {
+		marker := " "
+		if win.Active {
+			marker = "*"
+		}
+		section.Lines = append(section.Lines, fmt.Sprintf("%s %d: %s (%d panes)", marker, win.Index, win.Name, len(win.Panes)))
+	}
+
+	return section
+}
+
+// helper functions
+
+// run a tmux command and return trimmed output
+func (t *TmuxCapturer) tmux(ctx context.Context, args ...string) (string, error) {
+	if t.socket != "" {
+		args = append([]string{"-L", t.socket}, args...)
+	}
+
+	cmd := exec.CommandContext(ctx, "tmux", args...)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// exactsession targets the session with this name only, where a bare name
+// would also match another session starting with it
+func exactSession(session string) string {
+	return "=" + session
+}
+
+// list windows and their panes for a session
+func (t *TmuxCapturer) listWindows(ctx context.Context, session string) ([]TmuxWindow, error) {
+	out, err := t.tmux(ctx, "list-windows", "-t", exactSession(session),
+		"-F", "#{window_index}\t#{window_name}\t#{window_layout}\t#{window_active}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tmux windows: %w", err)
+	}
+
+	var windows []TmuxWindow
+	byIndex := make(map[int]int)
+
+	for _, line := range splitLines(out) {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			continue
+		}
+
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+
+		byIndex[index] = len(windows)
+		windows = append(windows, TmuxWindow{
+			Index:  index,
+			Name:   fields[1],
+			Layout: fields[2],
+			Active: fields[3] == "1",
+		})
+	}
+
+	out, err = t.tmux(ctx, "list-panes", "-s", "-t", exactSession(session),
+		"-F", "#{window_index}\t#{pane_index}\t#{pane_current_path}\t#{pane_current_command}\t#{pane_active}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tmux panes: %w", err)
+	}
+
+	for _, line := range splitLines(out) {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			continue
+		}
+
+		windowIndex, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		paneIndex, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+
+		i, ok := byIndex[windowIndex]
+		if !ok {
+			continue
+		}
+
+		windows[i].Panes = append(windows[i].Panes, TmuxPane{
+			Index:   paneIndex,
+			Path:    fields[2],
+			Command: fields[3],
+			Active:  fields[4] == "1",
+		})
+	}
+
+	return windows, nil
+}
+
+// read window size from a layout string like "b25d,80x24,0,0,1"
+func layoutSize(layout string) (int, int, bool) {
+	parts := strings.SplitN(layout, ",", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+
+	dims := strings.SplitN(parts[1], "x", 2)
+	if len(dims) != 2 {
+		return 0, 0, false
+	}
+
+	width, err := strconv.Atoi(dims[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	height, err := strconv.Atoi(dims[1])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return width, height, true
+}
+
+// split output into non-empty lines
+func splitLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,182 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestTmuxCapturerName(t *testing.T) {
+	tc := NewTmuxCapturer()
+
+	if tc.Name() != "tmux" {
+		t.Errorf("Expected name 'tmux', got %s", tc.Name())
+	}
+}
+
+func TestTmuxLayoutSize(t *testing.T) {
+	tests := []struct {
+		layout string
+		width  int
+		height int
+		ok     bool
+	}{
+		{"b25d,80x24,0,0,1", 80, 24, true},
+		{"5e1a,200x50,0,0{100x50,0,0,1,99x50,101,0,2}", 200, 50, true},
+		{"", 0, 0, false},
+		{"abcd,wide", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		width, height, ok := layoutSize(tt.layout)
+		if width != tt.width || height != tt.height || ok != tt.ok {
+			t.Errorf("layoutSize(%q) = %d, %d, %v, want %d, %d, %v",
+				tt.layout, width, height, ok, tt.width, tt.height, tt.ok)
+		}
+	}
+}
+
+func TestTmuxCaptureRestore(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+
+	socket := fmt.Sprintf("workshot-test-%d", os.Getpid())
+	tc := &TmuxCapturer{socket: socket, session: "work"}
+	t.Cleanup(func() {
+		tc.tmux(context.Background(), "kill-server")
+	})
+
+	dirA := t.TempDir()
+	dirB := t.TempDir()
+
+	// build a session with a split window and a second window
+	setup := [][]string{
+		{"new-session", "-d", "-s", "work", "-n", "editor", "-c", dirA, "-x", "160", "-y", "40"},
+		{"split-window", "-h", "-t", "work:editor", "-c", dirB},
+		{"new-window", "-t", "work:", "-n", "logs", "-c", dirB},
+	}
+	for _, args := range setup {
+		if _, err := tc.tmux(t.Context(), args...); err != nil {
+			t.Fatalf("tmux %v failed: %v", args, err)
+		}
+	}
+
+	data, err := tc.CaptureData(t.Context())
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+	if data == nil || data.Session != "work" {
+		t.Fatalf("Expected session 'work', got %v", data)
+	}
+	if !tc.CanRestoreData(data) {
+		t.Fatal("Expected captured data to be restorable")
+	}
+
+	if _, err := tc.tmux(t.Context(), "kill-session", "-t", "work"); err != nil {
+		t.Fatalf("Failed to kill session: %v", err)
+	}
+
+	if err := tc.RestoreData(t.Context(), data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	restored, err := tc.listWindows(t.Context(), "work")
+	if err != nil {
+		t.Fatalf("Failed to list restored windows: %v", err)
+	}
+
+	if len(restored) != 2 {
+		t.Fatalf("Expected 2 windows, got %d", len(restored))
+	}
+	if restored[0].Name != "editor" || len(restored[0].Panes) != 2 {
+		t.Errorf("Expected window 'editor' with 2 panes, got %q with %d",
+			restored[0].Name, len(restored[0].Panes))
+	}
+	if restored[1].Name != "logs" || len(restored[1].Panes) != 1 {
+		t.Errorf("Expected window 'logs' with 1 pane, got %q with %d",
+			restored[1].Name, len(restored[1].Panes))
+	}
+
+	wantA, _ := filepath.EvalSymlinks(dirA)
+	gotA, _ := filepath.EvalSymlinks(restored[0].Panes[0].Path)
+	if gotA != wantA {
+		t.Errorf("Expected first pane in %s, got %s", wantA, gotA)
+	}
+
+	// restoring again must not touch the running session
+	if err := tc.RestoreData(t.Context(), data); err != nil {
+		t.Errorf("Restore of existing session failed: %v", err)
+	}
+}
+
+func TestTmuxRestoreSkipsEmptyFirstWindow(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+
+	socket := fmt.Sprintf("workshot-test-empty-%d", os.Getpid())
+	tc := &TmuxCapturer{socket: socket}
+	t.Cleanup(func() {
+		tc.tmux(context.Background(), "kill-server")
+	})
+
+	dir := t.TempDir()
+	data := &TmuxData{
+		Session: "work",
+		Windows: []TmuxWindow{
+			{Index: 0, Name: "gone"},
+			{Index: 1, Name: "editor", Panes: []TmuxPane{{Path: dir}}},
+			{Index: 2, Name: "logs", Panes: []TmuxPane{{Path: dir}}},
+		},
+	}
+
+	if err := tc.RestoreData(t.Context(), data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	restored, err := tc.listWindows(t.Context(), "work")
+	if err != nil {
+		t.Fatalf("Failed to list restored windows: %v", err)
+	}
+	if len(restored) != 2 || restored[0].Name != "editor" || restored[1].Name != "logs" {
+		t.Errorf("Expected windows editor and logs, got %+v", restored)
+	}
+}
+
+func TestTmuxRestoreExactSession(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+
+	socket := fmt.Sprintf("workshot-test-exact-%d", os.Getpid())
+	tc := &TmuxCapturer{socket: socket}
+	t.Cleanup(func() {
+		tc.tmux(context.Background(), "kill-server")
+	})
+
+	// a running work2 is not the saved work
+	dir := t.TempDir()
+	if _, err := tc.tmux(t.Context(), "new-session", "-d", "-s", "work2", "-c", dir); err != nil {
+		t.Fatalf("Failed to start tmux: %v", err)
+	}
+
+	data := &TmuxData{
+		Session: "work",
+		Windows: []TmuxWindow{{Index: 0, Name: "editor", Panes: []TmuxPane{{Path: dir}}}},
+	}
+	if steps := tc.PreviewData(t.Context(), data); steps[0].Current != "not running" {
+		t.Errorf("Expected work not to be running, got %+v", steps)
+	}
+	if err := tc.RestoreData(t.Context(), data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	restored, err := tc.listWindows(t.Context(), "work")
+	if err != nil || len(restored) != 1 || restored[0].Name != "editor" {
+		t.Errorf("Expected work restored with its editor window, got %+v (%v)", restored, err)
+	}
+}
@@ -0,0 +1,304 @@
+package capture
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// toolchaincapturer captures active language environments and versions
+type ToolchainCapturer struct{}
+
+// toolchaindata is the saved language environments and versions
+type ToolchainData struct {
+	PythonVenv    string            `json:"python_venv,omitempty"`
+	CondaEnv      string            `json:"conda_env,omitempty"`
+	PythonVersion string            `json:"python_version,omitempty"`
+	NodeVersion   string            `json:"node_version,omitempty"`
+	Nvmrc         string            `json:"nvmrc,omitempty"`
+	ToolVersions  map[string]string `json:"tool_versions,omitempty"`
+	GoVersion     string            `json:"go_version,omitempty"`
+	Gowork        string            `json:"gowork,omitempty"`
+	RustToolchain string            `json:"rust_toolchain,omitempty"`
+}
+
+// newtoolchaincapturer creates a toolchain capturer
+func NewToolchainCapturer() types.Capturer {
+	return plugin.Typed[ToolchainData](&ToolchainCapturer{})
+}
+
+func (t *ToolchainCapturer) Name() string {
+	return "toolchain"
+}
+
+func (t *ToolchainCapturer) Priority() int {
+	return 35
+}
+
+func (t *ToolchainCapturer) CaptureData(ctx context.Context) (*ToolchainData, error) {
+	data := &ToolchainData{
+		// python virtualenv or conda env
+		PythonVenv:    os.Getenv("VIRTUAL_ENV"),
+		CondaEnv:      os.Getenv("CONDA_DEFAULT_ENV"),
+		PythonVersion: pythonVersion(ctx),
+
+		// node version and version files
+		NodeVersion:  nodeVersion(ctx),
+		Nvmrc:        readVersionFile(".nvmrc"),
+		ToolVersions: parseToolVersions(".tool-versions"),
+
+		// go version and workspace, only if set: go env reports the go.work
+		// it finds on its own, which must not be pinned for the whole shell
+		GoVersion: goVersion(ctx),
+		Gowork:    os.Getenv("GOWORK"),
+
+		// rust toolchain
+		RustToolchain: rustToolchain(ctx),
+	}
+
+	if data.empty() {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// restore cannot activate environments in the parent shell, so it only
+// reports tools whose installed version no longer matches the snapshot
+func (t *ToolchainCapturer) RestoreData(ctx context.Context, data *ToolchainData) error {
+	var problems []string
+
+	if data.PythonVenv != "" {
+		if _, err := os.Stat(data.PythonVenv); err != nil {
+			problems = append(problems, fmt.Sprintf("virtualenv %s no longer exists", data.PythonVenv))
+		}
+	}
+
+	checks := []struct {
+		saved   string
+		tool    string
+		current func(context.Context) string
+	}{
+		{data.PythonVersion, "python", pythonVersion},
+		{data.NodeVersion, "node", nodeVersion},
+		{data.GoVersion, "go", goVersion},
+		{data.RustToolchain, "rust", rustToolchain},
+	}
+
+	for _, check := range checks {
+		if check.saved == "" {
+			continue
+		}
+
+		current := check.current(ctx)
+		switch {
+		case current == "":
+			problems = append(problems, fmt.Sprintf("%s %s was used but is not installed", check.tool, check.saved))
+		case current != check.saved:
+			problems = append(problems, fmt.Sprintf("%s version mismatch: snapshot used %s, found %s", check.tool, check.saved, current))
+		}
+	}
+
+	problems = append(problems, toolVersionChanges(data.ToolVersions, parseToolVersions(".tool-versions"))...)
+
+	if len(problems) > 0 {
+		return types.Warning(errors.New(strings.Join(problems, "; ")))
+	}
+	return nil
+}
+
+// restore only checks versions, it changes nothing
+func (t *ToolchainCapturer) PreviewData(ctx context.Context, data *ToolchainData) []types.Step {
+	return nil
+}
+
+func (t *ToolchainCapturer) CanRestoreData(data *ToolchainData) bool {
+	return !data.empty()
+}
+
+// mappathsdata rewrites the paths in the data, used to store them relative to a repository
+func (t *ToolchainCapturer) MapPathsData(data *ToolchainData, f func(path string) string) {
+	data.PythonVenv = f(data.PythonVenv)
+	data.Gowork = f(data.Gowork)
+}
+
+func (t *ToolchainCapturer) RenderData(data *ToolchainData) *types.Section {
+	section := &types.Section{Title: "Toolchain"}
+
+	fields := []types.Field{
+		{Label: "Virtualenv", Value: data.PythonVenv},
+		{Label: "Conda Env", Value: data.CondaEnv},
+		{Label: "Python", Value: data.PythonVersion},
+		{Label: "Node", Value: data.NodeVersion},
+		{Label: ".nvmrc", Value: data.Nvmrc},
+		{Label: ".tool-versions", Value: formatToolVersions(data.ToolVersions)},
+		{Label: "Go", Value: data.GoVersion},
+		{Label: "GOWORK", Value: data.Gowork},
+		{Label: "Rust", Value: data.RustToolchain},
+	}
+	for _, f := range fields {
+		if f.Value != "" {
+			section.Fields = append(section.Fields, f)
+		}
+	}
+
+	return section
+}
+
+// reactivate saved environments
+func (t *ToolchainCapturer) RestorePlanData(data *ToolchainData) []types.Action {
+	var plan []types.Action
+
+	if data.PythonVenv != "" {
+		plan = append(plan, types.Activate(data.PythonVenv))
+	} else if data.CondaEnv != "" {
+		plan = append(plan, types.Run("conda", "activate", data.CondaEnv))
+	}
+
+	if data.Nvmrc != "" {
+		plan = append(plan, types.Run("nvm", "use"))
+	} else if data.NodeVersion != "" {
+		plan = append(plan, types.Run("nvm", "use", data.NodeVersion))
+	}
+
+	// asdf and mise switch versions by directory, but may need to install
+	if len(data.ToolVersions) > 0 {
+		manager := "asdf"
+		if _, err := exec.LookPath("mise"); err == nil {
+			manager = "mise"
+		}
+		plan = append(plan, types.Run(manager, "install"))
+	}
+
+	if data.Gowork != "" {
+		plan = append(plan, types.Export("GOWORK", data.Gowork))
+	}
+
+	return plan
+}
+
+// empty reports whether nothing was captured
+func (d *ToolchainData) empty() bool {
+	return d.PythonVenv == "" && d.CondaEnv == "" && d.PythonVersion == "" &&
+		d.NodeVersion == "" && d.Nvmrc == "" && len(d.ToolVersions) == 0 &&
+		d.GoVersion == "" && d.Gowork == "" && d.RustToolchain == ""
+}
+
+// helper functions
+
+// run a command and return trimmed output, empty on failure
+func commandOutput(ctx context.Context, name string, args ...string) string {
+	if _, err := exec.LookPath(name); err != nil {
+		return ""
+	}
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// get active python version, e.g. "3.12.1"
+func pythonVersion(ctx context.Context) string {
+	for _, name := range []string{"python3", "python"} {
+		if out := commandOutput(ctx, name, "--version"); out != "" {
+			return strings.TrimSpace(strings.TrimPrefix(out, "Python "))
+		}
+	}
+	return ""
+}
+
+// get active node version without the leading v
+func nodeVersion(ctx context.Context) string {
+	return strings.TrimPrefix(commandOutput(ctx, "node", "--version"), "v")
+}
+
+// get go version, e.g. "go1.22.1"
+func goVersion(ctx context.Context) string {
+	return commandOutput(ctx, "go", "env", "GOVERSION")
+}
+
+// get active rust toolchain, preferring rustup's name for it
+func rustToolchain(ctx context.Context) string {
+	if out := commandOutput(ctx, "rustup", "show", "active-toolchain"); out != "" {
+		return strings.Fields(out)[0]
+	}
+
+	// rustc 1.76.0 (07dca489a 2024-02-04)
+	if fields := strings.Fields(commandOutput(ctx, "rustc", "--version")); len(fields) >= 2 {
+		return fields[1]
+	}
+	return ""
+}
+
+// read a single-line version file like .nvmrc
+func readVersionFile(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// parse asdf/mise .tool-versions into tool -> version
+func parseToolVersions(path string) map[string]string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	tools := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) >= 2 {
+			tools[fields[0]] = fields[1]
+		}
+	}
+
+	return tools
+}
+
+// describe how the tools pinned in .tool-versions differ from the snapshot
+func toolVersionChanges(saved, current map[string]string) []string {
+	if len(saved) == 0 {
+		return nil
+	}
+	if current == nil {
+		return []string{".tool-versions no longer exists"}
+	}
+
+	var changes []string
+	for _, tool := range slices.Sorted(maps.Keys(saved)) {
+		switch version, ok := current[tool]; {
+		case !ok:
+			changes = append(changes, fmt.Sprintf(".tool-versions no longer pins %s %s", tool, saved[tool]))
+		case version != saved[tool]:
+			changes = append(changes, fmt.Sprintf("%s in .tool-versions changed: snapshot used %s, found %s", tool, saved[tool], version))
+		}
+	}
+	return changes
+}
+
+// format tool versions as "golang 1.22.1, nodejs 20.11.0"
+func formatToolVersions(tools map[string]string) string {
+	pinned := make([]string, 0, len(tools))
+	for _, tool := range slices.Sorted(maps.Keys(tools)) {
+		pinned = append(pinned, tool+" "+tools[tool])
+	}
+	return strings.Join(pinned, ", ")
+}
@@ -0,0 +1,166 @@
+package capture
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/internal/shell"
+)
+
+// install fake tool binaries that print fixed versions
+func setupFakeToolchain(t *testing.T, node, goVer string) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools require a posix shell")
+	}
+
+	binDir := t.TempDir()
+	scripts := map[string]string{
+		"node":    "echo v" + node,
+		"python3": "echo Python 3.12.1",
+		"go": `if [ "$2" = "GOVERSION" ]; then echo ` + goVer + `; fi
+if [ "$2" = "GOWORK" ]; then echo "$FAKE_GOWORK"; fi`,
+	}
+	for name, body := range scripts {
+		script := "#!/bin/sh\n" + body + "\n"
+		if err := os.WriteFile(filepath.Join(binDir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("PATH", binDir)
+}
+
+func TestToolchainCapturerName(t *testing.T) {
+	tc := NewToolchainCapturer()
+
+	if tc.Name() != "toolchain" {
+		t.Errorf("Expected name 'toolchain', got %s", tc.Name())
+	}
+}
+
+func TestParseToolVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".tool-versions")
+	content := "nodejs 20.11.0\n# pinned\npython 3.12.1 3.11.7\n\ngolang 1.22.1 # latest\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tools := parseToolVersions(path)
+	expected := map[string]string{"nodejs": "20.11.0", "python": "3.12.1", "golang": "1.22.1"}
+
+	if len(tools) != len(expected) {
+		t.Fatalf("Expected %d tools, got %v", len(expected), tools)
+	}
+	for tool, version := range expected {
+		if tools[tool] != version {
+			t.Errorf("%s = %q, want %q", tool, tools[tool], version)
+		}
+	}
+}
+
+func TestToolchainCaptureRestore(t *testing.T) {
+	projectDir := t.TempDir()
+	venv := filepath.Join(projectDir, ".venv")
+	os.MkdirAll(venv, 0755)
+	os.WriteFile(filepath.Join(projectDir, ".nvmrc"), []byte("20\n"), 0644)
+	os.WriteFile(filepath.Join(projectDir, ".tool-versions"), []byte("nodejs 20.11.0\ngolang 1.22.1\n"), 0644)
+	t.Chdir(projectDir)
+
+	t.Setenv("VIRTUAL_ENV", venv)
+	t.Setenv("CONDA_DEFAULT_ENV", "")
+	t.Setenv("FAKE_GOWORK", filepath.Join(projectDir, "found", "go.work"))
+	t.Setenv("GOWORK", filepath.Join(projectDir, "go.work"))
+	setupFakeToolchain(t, "20.11.0", "go1.22.1")
+
+	tc := NewToolchainCapturer()
+	data, err := tc.Capture(t.Context())
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"python_venv":    venv,
+		"python_version": "3.12.1",
+		"node_version":   "20.11.0",
+		"nvmrc":          "20",
+		"go_version":     "go1.22.1",
+		"gowork":         filepath.Join(projectDir, "go.work"),
+	}
+	for key, want := range expected {
+		if data[key] != want {
+			t.Errorf("%s = %v, want %s", key, data[key], want)
+		}
+	}
+
+	typed, err := plugin.Decode[ToolchainData](data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	plan := (&ToolchainCapturer{}).RestorePlanData(typed)
+	commands := strings.Join(shell.Serialize(shell.Posix, plan), "\n")
+	for _, want := range []string{"source " + filepath.Join(venv, "bin", "activate"), "nvm use", "asdf install", "export GOWORK="} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("Missing command %q in:\n%s", want, commands)
+		}
+	}
+
+	section := (&ToolchainCapturer{}).RenderData(typed)
+	rendered := false
+	for _, field := range section.Fields {
+		if field.Label == ".tool-versions" && field.Value == "golang 1.22.1, nodejs 20.11.0" {
+			rendered = true
+		}
+	}
+	if !rendered {
+		t.Errorf("Expected .tool-versions in %+v", section.Fields)
+	}
+
+	// same versions installed: nothing to warn about
+	if err := tc.Restore(t.Context(), data); err != nil {
+		t.Errorf("Expected no warnings, got: %v", err)
+	}
+
+	// a different node and a missing venv are reported
+	setupFakeToolchain(t, "18.19.0", "go1.22.1")
+	os.RemoveAll(venv)
+	os.WriteFile(filepath.Join(projectDir, ".tool-versions"), []byte("nodejs 18.19.0\n"), 0644)
+
+	err = tc.Restore(t.Context(), data)
+	if err == nil {
+		t.Fatal("Expected version mismatch warning")
+	}
+	if !strings.Contains(err.Error(), "node version mismatch: snapshot used 20.11.0, found 18.19.0") {
+		t.Errorf("Unexpected warning: %v", err)
+	}
+	if !strings.Contains(err.Error(), "no longer exists") {
+		t.Errorf("Expected missing virtualenv warning, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "nodejs in .tool-versions changed: snapshot used 20.11.0, found 18.19.0") ||
+		!strings.Contains(err.Error(), ".tool-versions no longer pins golang 1.22.1") {
+		t.Errorf("Expected .tool-versions changes, got: %v", err)
+	}
+}
+
+func TestToolchainFoundGowork(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("GOWORK", "")
+	t.Setenv("FAKE_GOWORK", "/src/api/go.work")
+	setupFakeToolchain(t, "20.11.0", "go1.22.1")
+
+	// a go.work go finds on its own is not pinned in the shell
+	data, err := (&ToolchainCapturer{}).CaptureData(t.Context())
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+	commands := strings.Join(shell.Serialize(shell.Posix, (&ToolchainCapturer{}).RestorePlanData(data)), "\n")
+	if data.Gowork != "" || strings.Contains(commands, "GOWORK") {
+		t.Errorf("Expected no GOWORK, got %q in:\n%s", data.Gowork, commands)
+	}
+}
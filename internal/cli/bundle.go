@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ansoncodes/workshot/internal/bundle"
+	"github.com/ansoncodes/workshot/internal/snapshot"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportOutput     string
+	exportPlaintext  bool
+	importOnConflict string
+)
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Bundle file to write (default: <name>"+bundle.Extension+")")
+	exportCmd.Flags().BoolVar(&exportPlaintext, "plaintext", false, "Export even though snapshots are encrypted, the bundle is plain JSON")
+	importCmd.Flags().StringVar(&importOnConflict, "on-conflict", "", "What to do with snapshots whose name is taken: rename, skip or overwrite (default: ask)")
+	rootCmd.AddCommand(exportCmd, importCmd)
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export [name...]",
+	Short: "Pack snapshots into a bundle file to move them to another machine",
+	Long: `Export writes one or more snapshots to a single .wsbundle file, a gzipped
+tar with the snapshot JSON and a manifest of SHA-256 checksums. Load it on
+another machine with 'workshot import'.
+
+Examples:
+  workshot export api-work
+  workshot export api-work web-work -o tasks.wsbundle`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output := exportOutput
+		if output == "" {
+			output = args[0] + bundle.Extension
+		}
+
+		// write next to the output and move it into place once complete, so
+		// a failed export leaves a bundle already there as it was
+		// the bundle holds what the store does, so it is private like it
+		file, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*.tmp")
+		if err != nil {
+			return fmt.Errorf("failed to create bundle: %w", err)
+		}
+
+		err = snapshot.Export(args, initPluginManager(), file, exportPlaintext)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(file.Name(), output)
+		}
+		if err != nil {
+			os.Remove(file.Name())
+			return err
+		}
+
+		green := color.New(color.FgGreen).SprintFunc()
+		cyan := color.New(color.FgCyan).SprintFunc()
+		fmt.Printf("%s Exported %d workshot(s) to %s\n", green("✓"), len(args), cyan(output))
+		return nil
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Load the snapshots of a bundle file",
+	Long: `Import checks a bundle written by 'workshot export' against its manifest
+and saves its snapshots. When a snapshot's name is taken, it asks whether to
+rename, skip or overwrite, or uses --on-conflict.
+
+Examples:
+  workshot import tasks.wsbundle
+  workshot import tasks.wsbundle --on-conflict rename`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		resolve := askConflict
+		switch conflict := snapshot.Conflict(importOnConflict); conflict {
+		case "":
+		case snapshot.ConflictRename, snapshot.ConflictSkip, snapshot.ConflictOverwrite:
+			resolve = func(string) snapshot.Conflict { return conflict }
+		default:
+			return fmt.Errorf("invalid --on-conflict %q (use rename, skip or overwrite)", importOnConflict)
+		}
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open bundle: %w", err)
+		}
+		defer file.Close()
+
+		imported, err := snapshot.Import(file, initPluginManager(), resolve)
+
+		green := color.New(color.FgGreen).SprintFunc()
+		yellow := color.New(color.FgYellow).SprintFunc()
+		cyan := color.New(color.FgCyan).SprintFunc()
+
+		for _, result := range imported {
+			switch result.As {
+			case "":
+				fmt.Printf("%s Skipped '%s', it already exists\n", yellow("-"), result.Name)
+			case result.Name:
+				fmt.Printf("%s Imported '%s'\n", green("✓"), cyan(result.Name))
+			default:
+				fmt.Printf("%s Imported '%s' as '%s'\n", green("✓"), result.Name, cyan(result.As))
+			}
+		}
+		return err
+	},
+}
+
+// ask what to do with a snapshot whose name is taken, anything else skips
+func askConflict(name string) snapshot.Conflict {
+	fmt.Fprintf(os.Stderr, "Workshot '%s' already exists. (r)ename, (s)kip or (o)verwrite? ", name)
+
+	response, err := stdin.ReadString('\n')
+	if err != nil {
+		return snapshot.ConflictSkip
+	}
+
+	switch strings.ToLower(strings.TrimSpace(response)) {
+	case "r", "rename":
+		return snapshot.ConflictRename
+	case "o", "overwrite":
+		return snapshot.ConflictOverwrite
+	}
+	return snapshot.ConflictSkip
+}
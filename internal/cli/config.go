@@ -0,0 +1,340 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ansoncodes/workshot/internal/config"
+	"github.com/ansoncodes/workshot/internal/crypt"
+	"github.com/ansoncodes/workshot/internal/storage"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configLocal     bool
+	encryptKeyfile  string
+	encryptMigrate  bool
+	encryptGenerate bool
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{configMapCmd, configUnmapCmd, configAddRootCmd, configRemoveRootCmd} {
+		cmd.Flags().BoolVar(&configLocal, "local", false, "Change the config of the current directory only")
+	}
+
+	configEncryptCmd.Flags().StringVar(&encryptKeyfile, "keyfile", "", "File with the key, instead of the WORKSHOT_PASSPHRASE passphrase")
+	configEncryptCmd.Flags().BoolVar(&encryptGenerate, "generate", false, "Create the keyfile with a random key")
+	configEncryptCmd.Flags().BoolVar(&encryptMigrate, "migrate", false, "Also encrypt the snapshots already saved")
+
+	configCmd.AddCommand(configMapCmd, configUnmapCmd, configAddRootCmd, configRemoveRootCmd, configEncryptCmd, configDecryptCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change where restore looks for snapshot directories",
+	Long: `Snapshots save absolute paths. When a snapshot's directory does not exist,
+for example on another machine or after moving your home directory, restore
+looks for it on this machine:
+
+1. Path mappings replace the start of saved paths, the longest match wins.
+2. Search roots are scanned for a clone with the snapshot's git remote.
+3. Otherwise restore offers to clone the repository.
+
+'workshot restore --to <dir>' skips the lookup and restores into <dir>.
+
+Settings live in ~/.workshot/config.json, or with --local in
+.workshot/config.json of the current directory.
+
+Examples:
+  workshot config                          # Show the settings in effect
+  workshot config map /home/alice/src ~/code
+  workshot config add-root ~/code`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, _ := os.Getwd()
+		cfg, localPath, err := config.LoadEffective(cwd)
+		if err != nil {
+			return err
+		}
+
+		bold := color.New(color.Bold).SprintFunc()
+		cyan := color.New(color.FgCyan).SprintFunc()
+		gray := color.New(color.FgHiBlack).SprintFunc()
+
+		if globalPath, err := config.GlobalPath(); err == nil {
+			fmt.Printf(" %s %s\n", bold("Global config:"), globalPath)
+		}
+		if localPath != "" {
+			fmt.Printf(" %s  %s\n", bold("Local config:"), localPath)
+		}
+
+		fmt.Printf("\n %s\n", bold("Path mappings:"))
+		if len(cfg.Paths) == 0 {
+			fmt.Printf("   %s\n", gray("none"))
+		}
+		froms := make([]string, 0, len(cfg.Paths))
+		for from := range cfg.Paths {
+			froms = append(froms, from)
+		}
+		sort.Strings(froms)
+		for _, from := range froms {
+			fmt.Printf("   %s → %s\n", from, cyan(cfg.Paths[from]))
+		}
+
+		fmt.Printf("\n %s\n", bold("Search roots:"))
+		if len(cfg.Roots) == 0 {
+			fmt.Printf("   %s\n", gray("none"))
+		}
+		for _, root := range cfg.Roots {
+			fmt.Printf("   %s\n", root)
+		}
+
+		fmt.Printf("\n %s\n", bold("Encryption:"))
+		switch enc := cfg.Encryption; {
+		case enc == nil || !enc.Enabled:
+			fmt.Printf("   %s\n", gray("off"))
+		case enc.Keyfile != "":
+			fmt.Printf("   on, keyfile %s\n", cyan(enc.Keyfile))
+		default:
+			fmt.Printf("   on, passphrase from %s\n", cyan(crypt.PassphraseEnv))
+		}
+
+		return nil
+	},
+}
+
+var configMapCmd = &cobra.Command{
+	Use:   "map [from] [to]",
+	Short: "Restore snapshots saved under one directory into another",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from := filepath.Clean(args[0])
+		if !filepath.IsAbs(from) {
+			return fmt.Errorf("saved directory must be an absolute path: %s", args[0])
+		}
+		to, err := configPath(args[1])
+		if err != nil {
+			return err
+		}
+
+		return updateConfig(func(cfg *config.Config) (string, error) {
+			cfg.SetPath(from, to)
+			return fmt.Sprintf("Mapped %s to %s", from, to), nil
+		})
+	},
+}
+
+var configUnmapCmd = &cobra.Command{
+	Use:   "unmap [from]",
+	Short: "Remove a path mapping",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateConfig(func(cfg *config.Config) (string, error) {
+			if !cfg.RemovePath(args[0]) {
+				return "", fmt.Errorf("no mapping for %s", args[0])
+			}
+			return "Removed mapping for " + filepath.Clean(args[0]), nil
+		})
+	},
+}
+
+var configAddRootCmd = &cobra.Command{
+	Use:   "add-root [dir]",
+	Short: "Search a directory for repositories of snapshots",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := configPath(args[0])
+		if err != nil {
+			return err
+		}
+
+		return updateConfig(func(cfg *config.Config) (string, error) {
+			if !cfg.AddRoot(root) {
+				return "", fmt.Errorf("%s is already a search root", root)
+			}
+			return "Added search root " + root, nil
+		})
+	},
+}
+
+var configRemoveRootCmd = &cobra.Command{
+	Use:   "remove-root [dir]",
+	Short: "Stop searching a directory for repositories",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := configPath(args[0])
+		if err != nil {
+			return err
+		}
+
+		return updateConfig(func(cfg *config.Config) (string, error) {
+			if !cfg.RemoveRoot(root) {
+				return "", fmt.Errorf("%s is not a search root", root)
+			}
+			return "Removed search root " + root, nil
+		})
+	},
+}
+
+var configEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt the snapshots saved in ~/.workshot",
+	Long: `Encrypt turns on encryption at rest for the global store. Snapshot files
+are sealed with AES-256-GCM and the index keeps only their names and times.
+
+The key comes from a keyfile, or is derived from the passphrase in
+WORKSHOT_PASSPHRASE, which must then be set whenever workshot runs.
+Snapshots saved before stay readable; --migrate encrypts them too.
+Repository stores are shared with the team and are not encrypted.
+
+Examples:
+  WORKSHOT_PASSPHRASE=... workshot config encrypt --migrate
+  workshot config encrypt --keyfile ~/.workshot/key --generate --migrate`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var keyfile string
+		if encryptKeyfile != "" {
+			var err error
+			if keyfile, err = configPath(encryptKeyfile); err != nil {
+				return err
+			}
+		} else if encryptGenerate {
+			return fmt.Errorf("--generate needs --keyfile")
+		}
+
+		if encryptGenerate {
+			if err := crypt.GenerateKeyfile(config.ExpandHome(keyfile)); err != nil {
+				return err
+			}
+		}
+
+		// refuse settings that would make every save fail
+		key, err := crypt.Load(config.ExpandHome(keyfile))
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			return crypt.ErrNoKey
+		}
+
+		configLocal = false
+		err = updateConfig(func(cfg *config.Config) (string, error) {
+			cfg.Encryption = &config.EncryptionConfig{Enabled: true, Keyfile: keyfile}
+			return "Turned on encryption", nil
+		})
+		if err != nil || !encryptMigrate {
+			return err
+		}
+
+		store, err := storage.New()
+		if err != nil {
+			return err
+		}
+		count, err := store.EncryptAll()
+		if err != nil {
+			return err
+		}
+
+		green := color.New(color.FgGreen).SprintFunc()
+		fmt.Printf("%s Encrypted %d saved workshot(s)\n", green("✓"), count)
+		return nil
+	},
+}
+
+var configDecryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Decrypt the snapshots saved in ~/.workshot",
+	Long: `Decrypt turns off encryption at rest and rewrites the encrypted snapshots
+as plain JSON, with their paths back in the index. It needs the same key
+as encrypt: the keyfile, or the passphrase in WORKSHOT_PASSPHRASE.
+
+Examples:
+  WORKSHOT_PASSPHRASE=... workshot config decrypt`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadGlobal()
+		if err != nil {
+			return err
+		}
+		if cfg.Encryption == nil || !cfg.Encryption.Enabled {
+			return fmt.Errorf("encryption is not on")
+		}
+
+		// decrypt first, so a failure leaves encryption on to run it again
+		store, err := storage.New()
+		if err != nil {
+			return err
+		}
+		count, err := store.DecryptAll()
+		if err != nil {
+			return err
+		}
+
+		configLocal = false
+		err = updateConfig(func(cfg *config.Config) (string, error) {
+			cfg.Encryption.Enabled = false
+			return "Turned off encryption", nil
+		})
+		if err != nil {
+			return err
+		}
+
+		green := color.New(color.FgGreen).SprintFunc()
+		fmt.Printf("%s Decrypted %d saved workshot(s)\n", green("✓"), count)
+		return nil
+	},
+}
+
+// load the global or local config, change it and save it
+func updateConfig(change func(cfg *config.Config) (string, error)) error {
+	path, err := config.GlobalPath()
+	if err != nil {
+		return err
+	}
+	scope := "globally"
+
+	if configLocal {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		path = config.LocalPath(cwd)
+		scope = "for " + cwd
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+
+	message, err := change(cfg)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Save(path); err != nil {
+		return err
+	}
+
+	green := color.New(color.FgGreen).SprintFunc()
+	fmt.Printf("%s %s %s\n", green("✓"), message, scope)
+	return nil
+}
+
+// make a directory argument absolute, keeping a leading ~ so the config
+// works for the same user on other machines
+func configPath(dir string) (string, error) {
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		return filepath.Clean(dir), nil
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid directory %s: %w", dir, err)
+	}
+	return abs, nil
+}
@@ -28,7 +28,8 @@ var deleteCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 
-		store, err := storage.New()
+		cwd, _ := os.Getwd()
+		store, err := storage.Open(cwd, name)
 		if err != nil {
 			return err
 		}
@@ -65,7 +66,11 @@ var deleteCmd = &cobra.Command{
 			return err
 		}
 
-		fmt.Printf("%s Deleted workshot '%s'\n", green("✓"), cyan(name))
+		if store.Scope() == storage.ScopeRepo {
+			fmt.Printf("%s Deleted workshot '%s' from the repository\n", green("✓"), cyan(name))
+		} else {
+			fmt.Printf("%s Deleted workshot '%s'\n", green("✓"), cyan(name))
+		}
 		return nil
 	},
 }
@@ -4,16 +4,23 @@ import (
 	"fmt"
 
 	"github.com/ansoncodes/workshot/internal/snapshot"
+	"github.com/ansoncodes/workshot/internal/storage"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
 var (
 	forceOverwrite bool
+	freezeRepo     bool
+	onlyPlugins    []string
+	skipPlugins    []string
 )
 
 func init() {
 	freezeCmd.Flags().BoolVarP(&forceOverwrite, "force", "f", false, "Overwrite if exists")
+	freezeCmd.Flags().StringSliceVar(&onlyPlugins, "plugins", nil, "Only run these plugins (comma separated)")
+	freezeCmd.Flags().StringSliceVar(&skipPlugins, "skip", nil, "Skip these plugins (comma separated)")
+	freezeCmd.Flags().BoolVar(&freezeRepo, "repo", false, "Save in .workshot/ of the current git repository")
 	rootCmd.AddCommand(freezeCmd)
 }
 
@@ -27,7 +34,13 @@ var freezeCmd = &cobra.Command{
   • Recent terminal commands
   • Open files (if detectable)
 
-The snapshot is saved to ~/.workshot/shots/ as human-readable JSON.`,
+The snapshot is saved to ~/.workshot/shots/ as human-readable JSON.
+With --repo it is saved to .workshot/shots/ at the root of the current
+git repository instead, with paths relative to the repository, so it can
+be committed and restored from any checkout.
+
+Use --plugins or --skip to choose plugins for this freeze only, or
+'workshot plugins disable' to turn a plugin off permanently.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
@@ -40,12 +53,27 @@ The snapshot is saved to ~/.workshot/shots/ as human-readable JSON.`,
 
 		// setup plugin manager
 		manager := initPluginManager()
+		if err := selectPlugins(manager, onlyPlugins, skipPlugins); err != nil {
+			return err
+		}
 
 		// save snapshot
-		if err := snapshot.Freeze(name, manager); err != nil {
+		scope := storage.ScopeGlobal
+		if freezeRepo {
+			scope = storage.ScopeRepo
+		}
+		snap, err := snapshot.Freeze(cmd.Context(), name, manager, scope)
+		if err != nil {
 			return err
 		}
 
+		// plugins that failed are missing from the snapshot
+		for _, report := range snap.CaptureReport {
+			if report.Failed() {
+				fmt.Printf("%s Plugin '%s' skipped: %s\n", yellow("⚠"), report.Name, report.Error)
+			}
+		}
+
 		fmt.Printf("%s Workshot '%s' saved successfully!\n", green("✓"), cyan(name))
 		fmt.Printf("   Restore it anytime with: %s\n", cyan(fmt.Sprintf("workshot restore %s", name)))
 
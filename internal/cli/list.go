@@ -2,6 +2,9 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/ansoncodes/workshot/internal/storage"
@@ -18,19 +21,28 @@ var listCmd = &cobra.Command{
 	Aliases: []string{"ls"},
 	Short:   "List all saved workshots",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		store, err := storage.New()
+		cwd, _ := os.Getwd()
+		stores, err := storage.Stores(cwd)
 		if err != nil {
 			return err
 		}
 
-		// load snapshot list
-		metadataList, err := store.List()
-		if err != nil {
-			return err
+		// load snapshot lists of the repository and global stores
+		var metadataList []storage.Metadata
+		for _, store := range stores {
+			list, err := store.List()
+			if err != nil {
+				return err
+			}
+			metadataList = append(metadataList, list...)
 		}
+		sort.SliceStable(metadataList, func(i, j int) bool {
+			return metadataList[i].CreatedAt.After(metadataList[j].CreatedAt)
+		})
 
 		cyan := color.New(color.FgCyan).SprintFunc()
 		gray := color.New(color.FgHiBlack).SprintFunc()
+		yellow := color.New(color.FgYellow).SprintFunc()
 
 		// no snapshots found
 		if len(metadataList) == 0 {
@@ -43,9 +55,17 @@ var listCmd = &cobra.Command{
 		fmt.Printf("Found %d saved workshot(s):\n\n", len(metadataList))
 
 		for _, meta := range metadataList {
-			fmt.Printf("  %s\n", cyan(meta.Name))
+			if meta.Scope == storage.ScopeRepo {
+				fmt.Printf("  %s %s\n", cyan(meta.Name), yellow("[repo]"))
+			} else {
+				fmt.Printf("  %s\n", cyan(meta.Name))
+			}
 
 			age := time.Since(meta.CreatedAt)
+			if meta.Encrypted {
+				fmt.Printf("     %s • %s\n", gray(formatAge(age)), gray("encrypted"))
+				continue
+			}
 			fmt.Printf("     %s • %s", gray(formatAge(age)), meta.WorkingDir)
 			if meta.GitBranch != "" {
 				fmt.Printf(" • %s", meta.GitBranch)
@@ -53,6 +73,10 @@ var listCmd = &cobra.Command{
 			fmt.Println()
 		}
 
+		if len(stores) > 1 {
+			fmt.Printf("\n%s snapshots are saved in %s\n", yellow("[repo]"), filepath.Join(storage.RepoRoot(cwd), ".workshot"))
+		}
+
 		fmt.Println("\nRestore any workshot with:")
 		fmt.Printf("  %s\n", cyan("workshot restore <name>"))
 
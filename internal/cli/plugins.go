@@ -1,18 +1,290 @@
 package cli
 
 import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
 	"github.com/ansoncodes/workshot/internal/capture"
+	"github.com/ansoncodes/workshot/internal/config"
 	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/pkg/types"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
 )
 
+var pluginsLocal bool
+
+func init() {
+	pluginsEnableCmd.Flags().BoolVar(&pluginsLocal, "local", false, "Change the config of the current directory only")
+	pluginsDisableCmd.Flags().BoolVar(&pluginsLocal, "local", false, "Change the config of the current directory only")
+
+	pluginsCmd.AddCommand(pluginsListCmd, pluginsEnableCmd, pluginsDisableCmd, pluginsInfoCmd)
+	rootCmd.AddCommand(pluginsCmd)
+}
+
 // create plugin manager and register plugins
 func initPluginManager() *plugin.Manager {
 	manager := plugin.NewManager()
 
+	// processes are only relaunched when asked for
+	var confirm func(string) bool
+	if relaunchProcesses {
+		confirm = confirmRelaunch
+		if assumeYes {
+			confirm = func(string) bool { return true }
+		}
+	}
+
 	// register all plugins
 	// lower priority runs first
-	manager.Register(capture.NewGitCapturer())       // priority 10
-	manager.Register(capture.NewTerminalCapturer())  // priority 30
+	manager.Register(capture.NewGitCapturer())                 // priority 10
+	manager.Register(capture.NewCloudCapturer())               // priority 15
+	manager.Register(capture.NewEditorCapturer())              // priority 20
+	manager.Register(capture.NewNeovimCapturer())              // priority 25
+	manager.Register(capture.NewTerminalCapturer())            // priority 30
+	manager.Register(capture.NewToolchainCapturer())           // priority 35
+	manager.Register(capture.NewTmuxCapturer())                // priority 40
+	manager.Register(capture.NewDockerCapturer(startServices)) // priority 50
+	manager.Register(capture.NewProcessesCapturer(confirm))    // priority 60
+
+	// apply plugin settings from global or per-directory config
+	cwd, _ := os.Getwd()
+	cfg, _, err := config.LoadEffective(cwd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else {
+		manager.Disable(cfg.Disabled()...)
+
+		timeouts, err := cfg.Timeouts()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		for name, timeout := range timeouts {
+			manager.SetTimeout(name, timeout)
+		}
+	}
+
+	// external workshot-plugin-* executables, after the config so disabled
+	// ones are not run just to learn their names
+	for _, err := range manager.LoadExternal(plugin.ExternalPluginDirs(), plugin.ExternalCachePath()) {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	// plugins with unknown dependencies or cycles are skipped
+	for _, err := range manager.CheckDependencies() {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
 
 	return manager
 }
+
+// apply --plugins and --skip selections on top of config
+func selectPlugins(manager *plugin.Manager, only, skip []string) error {
+	if err := checkPluginNames(manager, only, skip); err != nil {
+		return err
+	}
+
+	if len(only) > 0 {
+		for _, name := range manager.ListCapturers() {
+			if slices.Contains(only, name) {
+				manager.Enable(name)
+			} else {
+				manager.Disable(name)
+			}
+		}
+	}
+
+	manager.Disable(skip...)
+	return nil
+}
+
+// make sure plugin names given on the command line exist
+func checkPluginNames(manager *plugin.Manager, lists ...[]string) error {
+	for _, names := range lists {
+		for _, name := range names {
+			if _, ok := manager.Get(name); !ok {
+				return fmt.Errorf("unknown plugin '%s' (see 'workshot plugins list')", name)
+			}
+		}
+	}
+	return nil
+}
+
+var pluginsCmd = &cobra.Command{
+	Use:     "plugins",
+	Aliases: []string{"plugin"},
+	Short:   "List, enable and disable capture plugins",
+	Long: `Plugins capture and restore parts of your context.
+
+Plugins can be disabled globally (~/.workshot/config.json) or for one
+directory tree with --local (.workshot/config.json in the current directory).
+Per-directory settings override global ones.
+
+Examples:
+  workshot plugins list
+  workshot plugins disable terminal --local
+  workshot plugins info docker`,
+}
+
+var pluginsListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List all plugins and whether they are enabled",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager := initPluginManager()
+
+		cyan := color.New(color.FgCyan).SprintFunc()
+		green := color.New(color.FgGreen).SprintFunc()
+		gray := color.New(color.FgHiBlack).SprintFunc()
+
+		names := manager.ListCapturers()
+		fmt.Printf("Found %d plugin(s):\n\n", len(names))
+
+		for _, name := range names {
+			capturer, _ := manager.Get(name)
+
+			status := green("enabled ")
+			if !manager.IsEnabled(name) {
+				status = gray("disabled")
+			}
+
+			// pad before coloring, the color codes would count toward the width
+			fmt.Printf("  %s %s  %s\n", cyan(fmt.Sprintf("%-12s", name)), status,
+				gray(fmt.Sprintf("priority %d, %s", capturer.Priority(), pluginKind(capturer))))
+		}
+
+		return nil
+	},
+}
+
+var pluginsEnableCmd = &cobra.Command{
+	Use:   "enable [name]",
+	Short: "Enable a plugin",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setPluginEnabled(args[0], true)
+	},
+}
+
+var pluginsDisableCmd = &cobra.Command{
+	Use:   "disable [name]",
+	Short: "Disable a plugin",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setPluginEnabled(args[0], false)
+	},
+}
+
+var pluginsInfoCmd = &cobra.Command{
+	Use:   "info [name]",
+	Short: "Show details about a plugin",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		manager := initPluginManager()
+		capturer, ok := manager.Get(name)
+		if !ok {
+			return fmt.Errorf("unknown plugin '%s' (see 'workshot plugins list')", name)
+		}
+
+		bold := color.New(color.Bold).SprintFunc()
+		boldCyan := color.New(color.Bold, color.FgCyan).SprintFunc()
+		gray := color.New(color.FgHiBlack).SprintFunc()
+
+		status := "enabled"
+		if !manager.IsEnabled(name) {
+			status = "disabled"
+		}
+		if source := pluginConfigSource(name); source != "" {
+			status += " " + gray("("+source+")")
+		}
+
+		fmt.Printf(" %s %s\n", bold("Plugin:"), boldCyan(name))
+		fmt.Printf("   %s     %s\n", bold("Type:"), pluginKind(capturer))
+		fmt.Printf("   %s %d\n", bold("Priority:"), capturer.Priority())
+		fmt.Printf("   %s   %s\n", bold("Status:"), status)
+		fmt.Printf("   %s  %s\n", bold("Timeout:"), manager.Timeout(name))
+		if dependent, ok := capturer.(types.Dependent); ok && len(dependent.DependsOn()) > 0 {
+			fmt.Printf("   %s  %s\n", bold("Depends on:"), strings.Join(dependent.DependsOn(), ", "))
+		}
+		if external, ok := capturer.(*plugin.ExternalCapturer); ok {
+			fmt.Printf("   %s     %s\n", bold("Path:"), external.Path())
+		}
+
+		return nil
+	},
+}
+
+// write the enabled state of a plugin to global or local config
+func setPluginEnabled(name string, enabled bool) error {
+	manager := initPluginManager()
+	if _, ok := manager.Get(name); !ok {
+		return fmt.Errorf("unknown plugin '%s' (see 'workshot plugins list')", name)
+	}
+
+	path, err := config.GlobalPath()
+	if err != nil {
+		return err
+	}
+	scope := "globally"
+
+	if pluginsLocal {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		path = config.LocalPath(cwd)
+		scope = "for " + cwd
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+
+	cfg.SetEnabled(name, enabled)
+	if err := cfg.Save(path); err != nil {
+		return err
+	}
+
+	green := color.New(color.FgGreen).SprintFunc()
+	cyan := color.New(color.FgCyan).SprintFunc()
+
+	action := "Disabled"
+	if enabled {
+		action = "Enabled"
+	}
+	fmt.Printf("%s %s plugin '%s' %s\n", green("✓"), action, cyan(name), scope)
+
+	return nil
+}
+
+// describe which config file sets a plugin's state
+func pluginConfigSource(name string) string {
+	cwd, _ := os.Getwd()
+	if localPath := config.FindLocal(cwd); localPath != "" {
+		if cfg, err := config.Load(localPath); err == nil && cfg.Plugins[name].Enabled != nil {
+			return "set in " + localPath
+		}
+	}
+
+	if globalPath, err := config.GlobalPath(); err == nil {
+		if cfg, err := config.Load(globalPath); err == nil && cfg.Plugins[name].Enabled != nil {
+			return "set in " + globalPath
+		}
+	}
+
+	return ""
+}
+
+// built-in or external
+func pluginKind(c interface{}) string {
+	if _, ok := c.(*plugin.ExternalCapturer); ok {
+		return "external"
+	}
+	return "built-in"
+}
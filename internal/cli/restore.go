@@ -1,63 +1,183 @@
 package cli
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/ansoncodes/workshot/internal/config"
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/internal/shell"
 	"github.com/ansoncodes/workshot/internal/snapshot"
+	"github.com/ansoncodes/workshot/pkg/types"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
+var (
+	startServices     bool
+	relaunchProcesses bool
+	assumeYes         bool
+	restoreOnly       []string
+	restoreExcept     []string
+)
+
+// shared so piped answers to several prompts are not lost to buffering
+var stdin = bufio.NewReader(os.Stdin)
+
 func init() {
 	rootCmd.AddCommand(restoreCmd)
-	restoreCmd.Flags().BoolP("commands", "c", false, "Output only shell commands for eval")
+	restoreCmd.Flags().BoolP("commands", "c", false, "Output only shell commands for eval, without restoring anything")
+	restoreCmd.Flags().String("shell", "", "Shell to emit commands for: bash, zsh, fish or powershell (default: detected)")
+	restoreCmd.Flags().BoolVar(&startServices, "start-services", false, "Bring saved docker compose services back up")
+	restoreCmd.Flags().BoolVar(&relaunchProcesses, "relaunch", false, "Offer to relaunch saved processes in the background")
+	restoreCmd.Flags().Bool("dry-run", false, "Show what restore would change without changing anything")
+	restoreCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Restore without asking before each change")
+	restoreCmd.Flags().Bool("undo", false, "Roll back the last restore")
+	restoreCmd.Flags().StringSliceVar(&restoreOnly, "only", nil, "Only restore these plugins (comma separated)")
+	restoreCmd.Flags().StringSliceVar(&restoreExcept, "except", nil, "Restore all plugins but these (comma separated)")
+	restoreCmd.Flags().String("to", "", "Restore the snapshot's repository into this directory instead")
 }
 
 var restoreCmd = &cobra.Command{
-	Use:   "restore [name]",
+	Use:   "restore [name | --undo]",
 	Short: "Restore a saved development context",
 	Long: `Restore displays your saved working state and prints the commands to restore it.
 
 Restore WILL:
 • Display saved working directory
 • Show Git state and recent commands
-• Emit shell commands to change directory
+• Emit shell commands to change directory and check out the saved branch
+• Emit commands to switch back kube context and cloud profiles
+• Emit commands to reactivate virtualenvs and Node versions
+• Write commands for bash, zsh, fish or PowerShell (with --shell)
+• Warn when installed toolchain versions differ from the snapshot
+• Start saved docker compose services (with --start-services)
+• Relaunch saved processes in the background (with --relaunch)
+• Ask before each plugin changes anything, like checking out a branch
+  (skip the questions with --yes)
+• Show what would change without changing it (with --dry-run)
+• Leave everything to the printed commands (with -c, for eval)
+• Roll back plugins that already applied if a later one fails
+• Undo the last restore (with --undo)
+• Restore only some plugins (with --only or --except)
+• Find the repository when its saved path does not exist, using the
+  path mappings and search roots in config (see 'workshot config'),
+  or restore into another directory (with --to)
 
 Restore WON'T (due to shell limitations):
 • Change your current shell's directory
 • Run commands automatically
-• Restore running processes
+• Resume a process where it was; --relaunch starts saved processes anew
 
 Examples:
   workshot restore my-task            # Show context and commands
-  eval $(workshot restore my-task -c) # Execute restore commands
-  cd $(workshot restore my-task -c)   # Just change directory`,
-	Args: cobra.ExactArgs(1),
+  eval "$(workshot restore my-task -c)" # Only run the commands, change nothing else
+  workshot restore my-task -c --shell fish | source
+  workshot restore my-task --start-services # Also run docker compose up
+  workshot restore my-task --dry-run  # Preview changes against current state
+  workshot restore my-task --yes      # Restore without asking
+  eval "$(workshot restore --undo -c)" # Undo the last restore
+  workshot restore my-task --only git # Just the branch
+  workshot restore my-task --except terminal,processes
+  workshot restore my-task --to ~/code/api # Repository is somewhere else`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if undo, _ := cmd.Flags().GetBool("undo"); undo {
+			if cmd.Flags().Changed("to") {
+				return fmt.Errorf("--to cannot be used with --undo")
+			}
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		name := args[0]
-
 		commandsOnly, _ := cmd.Flags().GetBool("commands")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		undo, _ := cmd.Flags().GetBool("undo")
+
+		dialect := shell.Detect()
+		if name, _ := cmd.Flags().GetString("shell"); name != "" {
+			var err error
+			if dialect, err = shell.Parse(name); err != nil {
+				return err
+			}
+		}
+
+		if undo {
+			return undoRestore(cmd, dialect, commandsOnly)
+		}
+		name := args[0]
 
 		manager := initPluginManager()
+		if !assumeYes {
+			manager.SetConfirm(confirmRestore)
+		}
 
-		snap, errors := snapshot.Restore(name, manager)
+		if err := checkPluginNames(manager, restoreOnly, restoreExcept); err != nil {
+			return err
+		}
+		opts := snapshot.RestoreOptions{
+			Selection: plugin.Selection{Only: restoreOnly, Except: restoreExcept},
+			Clone:     confirmClone,
+		}
+		if to, _ := cmd.Flags().GetString("to"); to != "" {
+			opts.To = config.ExpandHome(to)
+		}
+
+		// remember where we are before the snapshot moves us
+		cwd, _ := os.Getwd()
+
+		// where saved paths are on this machine
+		if cfg, _, err := config.LoadEffective(cwd); err == nil {
+			opts.Paths, opts.Roots = cfg.PathRules(), cfg.SearchRoots()
+		}
+		sel := opts.Selection
+
+		var snap *types.Snapshot
+		var previews []types.PluginPreview
+		var reports []types.RestoreReport
+		var errors []error
+		switch {
+		case commandsOnly:
+			// the shell runs the plan, nothing is restored in process
+			snap, errors = snapshot.Plan(cmd.Context(), name, manager, opts)
+		case dryRun:
+			snap, previews, errors = snapshot.Preview(cmd.Context(), name, manager, opts)
+		default:
+			snap, reports, errors = snapshot.Restore(cmd.Context(), name, manager, opts)
+		}
 		if snap == nil {
+			if len(errors) > 0 {
+				return fmt.Errorf("failed to load snapshot '%s': %w", name, errors[0])
+			}
 			return fmt.Errorf("failed to load snapshot '%s'", name)
 		}
 
+		// only show the chosen plugins
+		sections := slices.DeleteFunc(manager.Sections(snap.PluginData), func(section types.Section) bool {
+			return !sel.Includes(section.Plugin)
+		})
+
+		// restore plan: cd first, then each plugin's actions in run order
+		plan := append([]types.Action{types.Cd(snap.WorkingDir)}, manager.Plan(snap.PluginData, sel)...)
+		commands := shell.Serialize(dialect, plan)
+
 		// COMMAND-ONLY MODE
 		if commandsOnly {
-			fmt.Printf("cd %q\n", snap.WorkingDir)
-			if snap.GitBranch != "" {
-				fmt.Printf("git checkout %s\n", snap.GitBranch)
+			for _, c := range commands {
+				fmt.Println(c)
+			}
+			for _, err := range errors {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 			}
 			return nil
 		}
 
 		// Formatters (match `show`)
 		bold := color.New(color.Bold).SprintFunc()
-		cyan := color.New(color.FgCyan).SprintFunc()
 		boldCyan := color.New(color.Bold, color.FgCyan).SprintFunc()
 		gray := color.New(color.FgHiBlack).SprintFunc()
 		yellow := color.New(color.FgYellow).SprintFunc()
@@ -67,67 +187,55 @@ Examples:
 		fmt.Printf("   %s %s\n", bold("Created:"), gray(snap.CreatedAt.Format("2006-01-02 15:04:05")))
 		fmt.Println()
 
-		// Working Directory
-		fmt.Printf(" %s\n", bold("Working Directory:"))
-		fmt.Printf("   %s\n", snap.WorkingDir)
-		fmt.Println()
-
-		// Git State
-		if snap.GitBranch != "" || snap.GitRemote != "" {
-			fmt.Printf(" %s\n", bold("Git State:"))
-
-			if snap.GitBranch != "" {
-				fmt.Printf("   %s  %s\n", bold("Branch:"), cyan(snap.GitBranch))
+		// Important sections come first so a wrong cluster is hard to miss
+		for _, section := range sections {
+			if section.Important {
+				fmt.Printf(" %s", yellow("⚠"))
+				printSection(section)
+				fmt.Println()
 			}
+		}
 
-			if snap.GitRemote != "" {
-				fmt.Printf("   %s  %s\n", bold("Remote:"), gray(snap.GitRemote))
-			}
+		// DRY RUN: the plan compared against the current state
+		if dryRun {
+			printPreview(cwd, snap, previews, sections)
+		} else {
+			// Working Directory
+			fmt.Printf(" %s\n", bold("Working Directory:"))
+			fmt.Printf("   %s\n", snap.WorkingDir)
+			fmt.Println()
+		}
 
-			if snap.GitDirty {
-				fmt.Printf("   %s  %s\n", bold("Status:"), yellow("Modified (uncommitted changes)"))
-			} else {
-				fmt.Printf("   %s  Clean\n", bold("Status:"))
-			}
+		applied := make(map[string]bool)
+		for _, report := range reports {
+			applied[report.Name] = report.Status == types.RestoreApplied
+		}
 
-			if gitData, ok := snap.PluginData["git"].(map[string]interface{}); ok {
-				if commit, ok := gitData["commit"].(string); ok && commit != "" {
-					fmt.Printf("   %s  %s\n", bold("Commit:"), gray(commit))
-				}
+		// Plugin sections, with hints for data that was not restored
+		for _, section := range sections {
+			if section.Important || dryRun {
+				continue
+			}
 
-				if stashCount, ok := gitData["stash_count"].(float64); ok && stashCount > 0 {
-					fmt.Printf("   %s  %.0f\n", bold("Stashes:"), stashCount)
+			printSection(section)
+			if !applied[section.Plugin] {
+				for _, hint := range section.Hints {
+					fmt.Printf("   %s\n", gray(hint))
 				}
 			}
-
 			fmt.Println()
 		}
 
-		// Recent Commands
-		if termData, ok := snap.PluginData["terminal"].(map[string]interface{}); ok {
-			if commandsList, ok := termData["recent_commands"].([]interface{}); ok && len(commandsList) > 0 {
-				fmt.Printf(" %s\n", bold("Recent Commands:"))
-
-				displayCount := len(commandsList)
-				if displayCount > 10 {
-					displayCount = 10
-				}
-				start := len(commandsList) - displayCount
-
-				for i := start; i < len(commandsList); i++ {
-					if cmd, ok := commandsList[i].(string); ok {
-						fmt.Printf("   %s\n", cmd)
-					}
-				}
-				fmt.Println()
-			}
+		// What each plugin did
+		if len(reports) > 0 {
+			printRestoreReport(reports)
+			fmt.Println()
 		}
 
 		// Commands to restore
 		fmt.Printf(" %s\n", bold("Commands to restore:"))
-		fmt.Printf("   cd %q\n", snap.WorkingDir)
-		if snap.GitBranch != "" {
-			fmt.Printf("   git checkout %s\n", snap.GitBranch)
+		for _, c := range commands {
+			fmt.Printf("   %s\n", c)
 		}
 
 		// Warnings
@@ -142,6 +250,202 @@ Examples:
 	},
 }
 
+// roll back the last restore and point the shell back where it was
+func undoRestore(cmd *cobra.Command, dialect string, commandsOnly bool) error {
+	manager := initPluginManager()
+
+	before, rolledBack, errors := snapshot.Undo(cmd.Context(), manager)
+	if before == nil {
+		if len(errors) > 0 {
+			return errors[0]
+		}
+		return fmt.Errorf("failed to undo the last restore")
+	}
+
+	commands := shell.Serialize(dialect, []types.Action{types.Cd(before.WorkingDir)})
+
+	// COMMAND-ONLY MODE
+	if commandsOnly {
+		for _, c := range commands {
+			fmt.Println(c)
+		}
+		for _, err := range errors {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		return nil
+	}
+
+	bold := color.New(color.Bold).SprintFunc()
+	cyan := color.New(color.FgCyan).SprintFunc()
+	green := color.New(color.FgGreen).SprintFunc()
+	gray := color.New(color.FgHiBlack).SprintFunc()
+
+	fmt.Printf("%s Undid restore of '%s'\n", green("✓"), cyan(before.Rollback.Snapshot))
+	if len(rolledBack) > 0 {
+		fmt.Printf("   %s %s\n", bold("Rolled back:"), strings.Join(rolledBack, ", "))
+	} else {
+		fmt.Printf("   %s\n", gray("No plugin had anything to roll back"))
+	}
+	fmt.Println()
+
+	fmt.Printf(" %s\n", bold("Commands to return:"))
+	for _, c := range commands {
+		fmt.Printf("   %s\n", c)
+	}
+
+	if len(errors) > 0 {
+		fmt.Println()
+		for _, err := range errors {
+			fmt.Printf("⚠ %s %v\n", bold("Warning:"), err)
+		}
+	}
+
+	return nil
+}
+
+// print what each plugin did, and why skipped ones were skipped
+func printRestoreReport(reports []types.RestoreReport) {
+	bold := color.New(color.Bold).SprintFunc()
+	green := color.New(color.FgGreen).SprintFunc()
+	yellow := color.New(color.FgYellow).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+	gray := color.New(color.FgHiBlack).SprintFunc()
+
+	fmt.Printf(" %s\n", bold("Restore Report:"))
+	for _, report := range reports {
+		switch {
+		case report.Status == types.RestoreFailed:
+			fmt.Printf("   %s %s: %v\n", red("✗"), report.Name, report.Err)
+		case report.Status == types.RestoreSkipped:
+			fmt.Printf("   %s %s\n", gray("-"), gray(report.Name+": skipped ("+report.Reason+")"))
+		case report.Err != nil:
+			fmt.Printf("   %s %s: %v\n", yellow("⚠"), report.Name, report.Err)
+		default:
+			fmt.Printf("   %s %s\n", green("✓"), report.Name)
+		}
+	}
+}
+
+// print what restore would do, compared against the current state
+func printPreview(cwd string, snap *types.Snapshot, previews []types.PluginPreview, sections []types.Section) {
+	bold := color.New(color.Bold).SprintFunc()
+	gray := color.New(color.FgHiBlack).SprintFunc()
+
+	hints := make(map[string][]string)
+	for _, section := range sections {
+		hints[section.Plugin] = section.Hints
+	}
+
+	fmt.Printf(" %s %s\n", bold("Restore plan"), gray("(dry run, nothing was changed)"))
+	fmt.Println()
+
+	fmt.Printf(" %s\n", bold("Working Directory:"))
+	fmt.Printf("   %s\n", formatStep(types.Step{Description: "cd", Current: cwd, Target: snap.WorkingDir}))
+	fmt.Println()
+
+	for _, preview := range previews {
+		if preview.Skipped != "" {
+			fmt.Printf(" %s %s\n", bold(preview.Name+":"), gray("skipped ("+preview.Skipped+")"))
+			for _, hint := range hints[preview.Name] {
+				fmt.Printf("   %s\n", gray(hint))
+			}
+			fmt.Println()
+			continue
+		}
+
+		fmt.Printf(" %s\n", bold(preview.Name+":"))
+		if len(preview.Steps) == 0 {
+			fmt.Printf("   %s\n", gray("nothing to change"))
+		}
+		for _, step := range preview.Steps {
+			fmt.Printf("   %s\n", formatStep(step))
+		}
+		fmt.Println()
+	}
+}
+
+// format a step as "~ description: current → target"
+// steps that change nothing are marked with =
+func formatStep(step types.Step) string {
+	green := color.New(color.FgGreen).SprintFunc()
+	yellow := color.New(color.FgYellow).SprintFunc()
+	gray := color.New(color.FgHiBlack).SprintFunc()
+
+	switch {
+	case step.Noop():
+		return gray("= " + step.Description + ": " + step.Current + " (unchanged)")
+	case step.Current == "" && step.Target == "":
+		return yellow("+") + " " + step.Description
+	}
+
+	current, target := step.Current, step.Target
+	if current == "" {
+		current = "none"
+	}
+	if target == "" {
+		target = "none"
+	}
+	return yellow("~") + " " + step.Description + ": " + current + " → " + green(target)
+}
+
+// ask before a plugin makes changes
+// the prompt goes to stderr so it never ends up in eval output
+func confirmRestore(name string, steps []types.Step) bool {
+	bold := color.New(color.Bold).SprintFunc()
+
+	fmt.Fprintf(os.Stderr, "%s will:\n", bold(name))
+	for _, step := range steps {
+		if !step.Noop() {
+			fmt.Fprintf(os.Stderr, "  %s\n", formatStep(step))
+		}
+	}
+	return ask(fmt.Sprintf("Restore %s? (y/N): ", bold(name)))
+}
+
+// ask before relaunching a process
+func confirmRelaunch(command string) bool {
+	cyan := color.New(color.FgCyan).SprintFunc()
+	return ask(fmt.Sprintf("Relaunch %s? (y/N): ", cyan(command)))
+}
+
+// ask before cloning a missing repository
+// answering with a path clones there instead of the saved location
+func confirmClone(remote, dir string) (string, bool) {
+	cyan := color.New(color.FgCyan).SprintFunc()
+	if assumeYes {
+		fmt.Fprintf(os.Stderr, "Cloning %s into %s\n", cyan(remote), dir)
+		return dir, true
+	}
+
+	fmt.Fprintf(os.Stderr, "%s no longer exists.\nClone %s into it? (y/N, or another path): ", dir, cyan(remote))
+	response, err := stdin.ReadString('\n')
+	if err != nil {
+		return "", false
+	}
+
+	response = strings.TrimSpace(response)
+	switch strings.ToLower(response) {
+	case "y", "yes":
+		return dir, true
+	case "", "n", "no":
+		return "", false
+	}
+	return config.ExpandHome(response), true
+}
+
+// ask a yes or no question on stderr, anything but yes is no
+func ask(prompt string) bool {
+	fmt.Fprint(os.Stderr, prompt)
+
+	response, err := stdin.ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	response = strings.ToLower(strings.TrimSpace(response))
+	return response == "y" || response == "yes"
+}
+
 func formatAge(d time.Duration) string {
 	if d < time.Minute {
 		return "just now"
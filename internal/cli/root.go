@@ -1,6 +1,10 @@
 package cli
 
 import (
+	"context"
+	"os"
+	"os/signal"
+
 	"github.com/spf13/cobra"
 
 	"github.com/ansoncodes/workshot/internal/version"
@@ -23,8 +27,12 @@ examples
 }
 
 // run the root command
+// ctrl-c cancels running plugins instead of killing workshot mid-write
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return rootCmd.ExecuteContext(ctx)
 }
 
 func init() {
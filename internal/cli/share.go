@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ansoncodes/workshot/internal/snapshot"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	shareRemote string
+	shareForce  bool
+
+	pushPlaintext bool
+)
+
+func init() {
+	pushCmd.Flags().StringVar(&shareRemote, "remote", "origin", "Git remote to push to")
+	pushCmd.Flags().BoolVarP(&shareForce, "force", "f", false, "Replace the snapshot on the remote even if someone else pushed it")
+	pushCmd.Flags().BoolVar(&pushPlaintext, "plaintext", false, "Push even though snapshots are encrypted, the shared copy is plain JSON")
+	fetchCmd.Flags().StringVar(&shareRemote, "remote", "origin", "Git remote to fetch from")
+	fetchCmd.Flags().BoolVarP(&shareForce, "force", "f", false, "Replace local snapshots with the same name")
+
+	rootCmd.AddCommand(pushCmd, fetchCmd)
+}
+
+var pushCmd = &cobra.Command{
+	Use:   "push [name]",
+	Short: "Share a snapshot through the repository's git remote",
+	Long: `Push commits a snapshot of the current repository under
+refs/workshot/<name> and pushes that ref, so teammates can fetch it with
+'workshot fetch'. Paths in the snapshot are saved relative to the
+repository root, so it restores from any checkout.
+
+Examples:
+  workshot push login-bug
+  workshot push login-bug --remote upstream`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		commit, err := snapshot.Push(cmd.Context(), name, initPluginManager(), shareRemote, shareForce, pushPlaintext)
+		if err != nil {
+			return err
+		}
+
+		green := color.New(color.FgGreen).SprintFunc()
+		cyan := color.New(color.FgCyan).SprintFunc()
+		gray := color.New(color.FgHiBlack).SprintFunc()
+
+		fmt.Printf("%s Pushed workshot '%s' to %s %s\n", green("✓"), cyan(name), shareRemote, gray(commit[:7]))
+		fmt.Printf("   Teammates can get it with: %s\n", cyan("workshot fetch "+name))
+		return nil
+	},
+}
+
+var fetchCmd = &cobra.Command{
+	Use:   "fetch [name...]",
+	Short: "Get snapshots shared through the repository's git remote",
+	Long: `Fetch downloads snapshots pushed with 'workshot push', all of them or the
+named ones, and saves them in the repository's .workshot/ store. Snapshots
+that already exist there are skipped unless --force is given.
+
+Examples:
+  workshot fetch
+  workshot fetch login-bug`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fetched, skipped, err := snapshot.Fetch(cmd.Context(), shareRemote, args, shareForce)
+
+		green := color.New(color.FgGreen).SprintFunc()
+		yellow := color.New(color.FgYellow).SprintFunc()
+		cyan := color.New(color.FgCyan).SprintFunc()
+
+		for _, name := range fetched {
+			fmt.Printf("%s Fetched workshot '%s'\n", green("✓"), cyan(name))
+		}
+		if len(skipped) > 0 {
+			fmt.Printf("%s Skipped existing %s (use --force to replace)\n", yellow("⚠"), strings.Join(skipped, ", "))
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(fetched)+len(skipped) == 0 {
+			fmt.Printf("No shared workshots on %s.\n", shareRemote)
+		}
+		return nil
+	},
+}
@@ -3,7 +3,7 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
+	"os"
 	"time"
 
 	"github.com/ansoncodes/workshot/internal/storage"
@@ -25,10 +25,14 @@ var showCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 
-		store, err := storage.New()
+		cwd, _ := os.Getwd()
+		store, err := storage.Open(cwd, name)
 		if err != nil {
 			return err
 		}
+		manager := initPluginManager()
+		store.SetMigrator(manager)
+		store.SetPaths(manager)
 
 		snap, err := store.Load(name)
 		if err != nil {
@@ -46,19 +50,17 @@ var showCmd = &cobra.Command{
 			return nil
 		}
 
-		printSnapshot(name, snap)
+		printSnapshot(name, snap, manager.Sections(snap.PluginData))
 		return nil
 	},
 }
 
-func printSnapshot(name string, snap *types.Snapshot) {
+func printSnapshot(name string, snap *types.Snapshot, sections []types.Section) {
 	// Formatters
 	bold := color.New(color.Bold).SprintFunc()
-	cyan := color.New(color.FgCyan).SprintFunc()
 	boldCyan := color.New(color.Bold, color.FgCyan).SprintFunc()
 	green := color.New(color.FgGreen).SprintFunc()
 	gray := color.New(color.FgHiBlack).SprintFunc()
-	white := color.New(color.FgWhite).SprintFunc()
 
 	// Header
 	fmt.Printf(" %s %s\n", bold("Snapshot:"), boldCyan(name))
@@ -70,69 +72,87 @@ func printSnapshot(name string, snap *types.Snapshot) {
 	fmt.Printf("   %s\n", green(snap.WorkingDir))
 	fmt.Println()
 
-	// Git State
-	if snap.GitBranch != "" {
-		fmt.Printf(" %s\n", bold("Git State:"))
-		fmt.Printf("   %s  %s\n", bold("Branch:"), cyan(snap.GitBranch))
+	// Plugin sections
+	for _, section := range sections {
+		printSection(section)
+		fmt.Println()
+	}
 
-		status := "Clean"
-		if snap.GitDirty {
-			status = "Dirty (uncommitted changes)"
-		}
-		fmt.Printf("   %s  %s\n", bold("Status:"), status)
+	// Capture Report
+	if len(snap.CaptureReport) > 0 {
+		fmt.Printf(" %s\n", bold("Capture Report:"))
+		printCaptureReport(snap.CaptureReport)
+		fmt.Println()
+	}
 
-		if snap.GitRemote != "" {
-			fmt.Printf("   %s  %s\n", bold("Remote:"), gray(snap.GitRemote))
-		}
+	// Metadata
+	fmt.Printf(" %s\n", bold("Metadata:"))
+	fmt.Printf("   %s %d\n", bold("Schema Version:"), snap.SchemaVersion)
+	fmt.Printf("   %s %s\n", bold("Age:"), formatDuration(time.Since(snap.CreatedAt)))
+	fmt.Printf("   %s %d active\n", bold("Plugins:"), len(snap.PluginData))
+}
 
-		if gitData, ok := snap.PluginData["git"].(map[string]interface{}); ok {
-			if commit, ok := gitData["commit"].(string); ok && commit != "" {
-				fmt.Printf("   %s  %s\n", bold("Commit:"), gray(commit))
-			}
-			if stash, ok := gitData["stash_count"].(float64); ok && stash > 0 {
-				fmt.Printf("   %s  %.0f\n", bold("Stashes:"), stash)
-			}
-		}
-		fmt.Println()
+// print a plugin's section, highlighting important values
+func printSection(section types.Section) {
+	bold := color.New(color.Bold).SprintFunc()
+	cyan := color.New(color.FgCyan).SprintFunc()
+	yellow := color.New(color.FgYellow).SprintFunc()
+
+	value := cyan
+	if section.Important {
+		value = yellow
 	}
 
-	// Editor
-	if editorData, ok := snap.PluginData["editor"].(map[string]interface{}); ok {
-		if detected, ok := editorData["detected"].(string); ok && detected != "" {
-			fmt.Printf(" %s\n", bold("Editor:"))
-			fmt.Printf("   %s\n\n", cyan(detected))
-		}
+	fmt.Printf(" %s\n", bold(section.Title+":"))
+	for _, f := range section.Fields {
+		fmt.Printf("   %s  %s\n", bold(f.Label+":"), value(f.Value))
+	}
+	for _, line := range section.Lines {
+		fmt.Printf("   %s\n", line)
 	}
+}
 
-	// Recent Commands
-	if terminalData, ok := snap.PluginData["terminal"].(map[string]interface{}); ok {
-		if commands, ok := terminalData["recent_commands"].([]interface{}); ok && len(commands) > 0 {
-			fmt.Printf(" %s\n", bold("Recent Commands:"))
+// print how each plugin's capture went
+func printCaptureReport(reports []types.PluginReport) {
+	green := color.New(color.FgGreen).SprintFunc()
+	yellow := color.New(color.FgYellow).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+	gray := color.New(color.FgHiBlack).SprintFunc()
 
-			max := 10
-			start := 0
-			if len(commands) > max {
-				start = len(commands) - max
-			}
+	for _, report := range reports {
+		status := fmt.Sprintf("%-7s", report.Status)
+		switch report.Status {
+		case types.PluginOK:
+			status = green(status)
+		case types.PluginTimeout:
+			status = yellow(status)
+		case types.PluginFailed:
+			status = red(status)
+		default:
+			status = gray(status)
+		}
 
-			for _, c := range commands[start:] {
-				if cmd, ok := c.(string); ok {
-					colorFn := white
-					if strings.HasPrefix(cmd, "git ") {
-						colorFn = cyan
-					}
-					fmt.Printf("   %s\n", colorFn(cmd))
-				}
-			}
-			fmt.Println()
+		details := "<1ms"
+		if report.Duration >= time.Millisecond {
+			details = report.Duration.Round(time.Millisecond).String()
+		}
+		if report.DataSize > 0 {
+			details += fmt.Sprintf(", %s", formatSize(report.DataSize))
+		}
+
+		fmt.Printf("   %-10s %s %s\n", report.Name, status, gray(details))
+		if report.Error != "" {
+			fmt.Printf("     %s\n", gray(report.Error))
 		}
 	}
+}
 
-	// Metadata
-	fmt.Printf(" %s\n", bold("Metadata:"))
-	fmt.Printf("   %s %d\n", bold("Schema Version:"), snap.SchemaVersion)
-	fmt.Printf("   %s %s\n", bold("Age:"), formatDuration(time.Since(snap.CreatedAt)))
-	fmt.Printf("   %s %d active\n", bold("Plugins:"), len(snap.PluginData))
+// format a byte count for display
+func formatSize(n int) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.1f KB", float64(n)/1024)
 }
 
 func formatDuration(d time.Duration) string {
@@ -0,0 +1,311 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	dirName  = ".workshot"
+	fileName = "config.json"
+)
+
+// pluginconfig holds user settings for one plugin
+type PluginConfig struct {
+	// Enabled is nil when the plugin is not configured at this level.
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// Timeout bounds the plugin's capture, e.g. "5s" or "1m".
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// config is the content of a workshot config file
+type Config struct {
+	Plugins map[string]PluginConfig `json:"plugins,omitempty"`
+
+	// Paths maps directories saved in snapshots to where they are on this
+	// machine, e.g. "/home/alice/src": "~/code".
+	Paths map[string]string `json:"paths,omitempty"`
+
+	// Roots are searched for a clone of a snapshot's repository when its
+	// saved directory does not exist.
+	Roots []string `json:"roots,omitempty"`
+
+	// Encryption of the snapshots in ~/.workshot, only read from the
+	// global config.
+	Encryption *EncryptionConfig `json:"encryption,omitempty"`
+}
+
+// encryptionconfig holds the encryption settings of the global store
+type EncryptionConfig struct {
+	// Enabled encrypts snapshots when they are saved. Encrypted snapshots
+	// stay readable with the key after it is turned off.
+	Enabled bool `json:"enabled"`
+
+	// Keyfile holds the key. Without it the key is derived from the
+	// passphrase in WORKSHOT_PASSPHRASE.
+	Keyfile string `json:"keyfile,omitempty"`
+}
+
+// loadglobal reads the global config
+func LoadGlobal() (*Config, error) {
+	path, err := GlobalPath()
+	if err != nil {
+		return nil, err
+	}
+	return Load(path)
+}
+
+// globalpath returns ~/.workshot/config.json
+func GlobalPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, dirName, fileName), nil
+}
+
+// localpath returns the per-directory config path for dir
+func LocalPath(dir string) string {
+	return filepath.Join(dir, dirName, fileName)
+}
+
+// findlocal looks for the nearest per-directory config from dir upwards
+// the home directory is not searched since its config is the global one
+func FindLocal(dir string) string {
+	home, _ := os.UserHomeDir()
+
+	for dir != "" {
+		if filepath.Clean(dir) == filepath.Clean(home) {
+			return ""
+		}
+
+		path := LocalPath(dir)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	return ""
+}
+
+// load reads a config file, a missing file is an empty config
+func Load(path string) (*Config, error) {
+	cfg := &Config{Plugins: make(map[string]PluginConfig)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if cfg.Plugins == nil {
+		cfg.Plugins = make(map[string]PluginConfig)
+	}
+
+	return cfg, nil
+}
+
+// save writes the config file, creating its directory
+func (c *Config) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	// it can name the encryption keyfile, so only the user may read it
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
+}
+
+// setenabled turns a plugin on or off at this config level
+func (c *Config) SetEnabled(name string, enabled bool) {
+	pc := c.Plugins[name]
+	pc.Enabled = &enabled
+	c.Plugins[name] = pc
+}
+
+// disabled returns the names of plugins turned off at this level
+func (c *Config) Disabled() []string {
+	var names []string
+	for name, pc := range c.Plugins {
+		if pc.Enabled != nil && !*pc.Enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setpath maps a saved directory to a directory on this machine
+func (c *Config) SetPath(from, to string) {
+	if c.Paths == nil {
+		c.Paths = make(map[string]string)
+	}
+	c.Paths[filepath.Clean(from)] = to
+}
+
+// removepath drops the mapping of a saved directory
+// it reports whether there was one
+func (c *Config) RemovePath(from string) bool {
+	from = filepath.Clean(from)
+	if _, ok := c.Paths[from]; !ok {
+		return false
+	}
+	delete(c.Paths, from)
+	return true
+}
+
+// addroot adds a directory to search for repositories
+// it reports false if the directory is already a root
+func (c *Config) AddRoot(dir string) bool {
+	if slices.Contains(c.Roots, dir) {
+		return false
+	}
+	c.Roots = append(c.Roots, dir)
+	return true
+}
+
+// removeroot stops searching a directory for repositories
+// it reports whether the directory was a root
+func (c *Config) RemoveRoot(dir string) bool {
+	i := slices.Index(c.Roots, dir)
+	if i < 0 {
+		return false
+	}
+	c.Roots = slices.Delete(c.Roots, i, i+1)
+	return true
+}
+
+// pathrules returns the path mappings with ~ expanded in the targets
+func (c *Config) PathRules() map[string]string {
+	rules := make(map[string]string, len(c.Paths))
+	for from, to := range c.Paths {
+		rules[filepath.Clean(from)] = ExpandHome(to)
+	}
+	return rules
+}
+
+// searchroots returns the roots with ~ expanded
+func (c *Config) SearchRoots() []string {
+	roots := make([]string, 0, len(c.Roots))
+	for _, root := range c.Roots {
+		roots = append(roots, ExpandHome(root))
+	}
+	return roots
+}
+
+// expandhome replaces a leading ~ with the home directory
+func ExpandHome(path string) string {
+	if path == "" {
+		return ""
+	}
+	rest, ok := strings.CutPrefix(path, "~")
+	if !ok || (rest != "" && rest[0] != '/' && rest[0] != filepath.Separator) {
+		return filepath.Clean(path)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(home, rest)
+}
+
+// timeouts returns the configured capture timeout of each plugin
+func (c *Config) Timeouts() (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for name, pc := range c.Plugins {
+		if pc.Timeout == "" {
+			continue
+		}
+
+		timeout, err := time.ParseDuration(pc.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q for plugin '%s'", pc.Timeout, name)
+		}
+		timeouts[name] = timeout
+	}
+	return timeouts, nil
+}
+
+// merge returns a config where settings in local override base
+func Merge(base, local *Config) *Config {
+	merged := &Config{Plugins: make(map[string]PluginConfig)}
+
+	for name, pc := range base.Plugins {
+		merged.Plugins[name] = pc
+	}
+	for name, pc := range local.Plugins {
+		current := merged.Plugins[name]
+		if pc.Enabled != nil {
+			current.Enabled = pc.Enabled
+		}
+		if pc.Timeout != "" {
+			current.Timeout = pc.Timeout
+		}
+		merged.Plugins[name] = current
+	}
+
+	// the store is global, so is its encryption
+	merged.Encryption = base.Encryption
+
+	// local mappings win, and local roots are searched first
+	if len(base.Paths)+len(local.Paths) > 0 {
+		merged.Paths = make(map[string]string)
+		maps.Copy(merged.Paths, base.Paths)
+		maps.Copy(merged.Paths, local.Paths)
+	}
+	for _, roots := range [][]string{local.Roots, base.Roots} {
+		for _, root := range roots {
+			merged.AddRoot(root)
+		}
+	}
+
+	return merged
+}
+
+// loadeffective merges the global config with the nearest local one for dir
+// it also returns the local config path, empty if there is none
+func LoadEffective(dir string) (*Config, string, error) {
+	global, err := LoadGlobal()
+	if err != nil {
+		return nil, "", err
+	}
+
+	localPath := FindLocal(dir)
+	if localPath == "" {
+		return global, "", nil
+	}
+
+	local, err := Load(localPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return Merge(global, local), localPath, nil
+}
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func setHome(t *testing.T) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	return home
+}
+
+func TestLoadMissingConfig(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "config.json"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.Plugins) != 0 {
+		t.Errorf("Expected empty config, got %v", cfg.Plugins)
+	}
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".workshot", "config.json")
+
+	cfg := &Config{Plugins: make(map[string]PluginConfig)}
+	cfg.SetEnabled("terminal", false)
+	cfg.SetEnabled("git", true)
+
+	if err := cfg.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0600) {
+		t.Errorf("Expected the config to be private, got %v (%v)", info.Mode(), err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	disabled := loaded.Disabled()
+	if len(disabled) != 1 || disabled[0] != "terminal" {
+		t.Errorf("Expected terminal disabled, got %v", disabled)
+	}
+}
+
+func TestLoadEffective(t *testing.T) {
+	home := setHome(t)
+
+	global := &Config{Plugins: make(map[string]PluginConfig)}
+	global.SetEnabled("terminal", false)
+	global.SetEnabled("docker", false)
+	if err := global.Save(filepath.Join(home, ".workshot", "config.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the project re-enables docker and turns off tmux
+	project := t.TempDir()
+	local := &Config{Plugins: make(map[string]PluginConfig)}
+	local.SetEnabled("docker", true)
+	local.SetEnabled("tmux", false)
+	if err := local.Save(LocalPath(project)); err != nil {
+		t.Fatal(err)
+	}
+
+	subdir := filepath.Join(project, "src", "api")
+	os.MkdirAll(subdir, 0755)
+
+	cfg, localPath, err := LoadEffective(subdir)
+	if err != nil {
+		t.Fatalf("LoadEffective failed: %v", err)
+	}
+
+	if localPath != LocalPath(project) {
+		t.Errorf("Expected local config %s, got %s", LocalPath(project), localPath)
+	}
+
+	disabled := cfg.Disabled()
+	if len(disabled) != 2 || disabled[0] != "terminal" || disabled[1] != "tmux" {
+		t.Errorf("Expected terminal and tmux disabled, got %v", disabled)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	base := &Config{Plugins: map[string]PluginConfig{
+		"docker": {Timeout: "10s"},
+		"git":    {Timeout: "5s"},
+	}}
+	local := &Config{Plugins: map[string]PluginConfig{
+		"docker": {Timeout: "1m"},
+	}}
+
+	timeouts, err := Merge(base, local).Timeouts()
+	if err != nil {
+		t.Fatalf("Timeouts failed: %v", err)
+	}
+	if timeouts["docker"] != time.Minute || timeouts["git"] != 5*time.Second {
+		t.Errorf("Unexpected timeouts: %v", timeouts)
+	}
+
+	invalid := &Config{Plugins: map[string]PluginConfig{"git": {Timeout: "soon"}}}
+	if _, err := invalid.Timeouts(); err == nil {
+		t.Error("Expected error for invalid timeout")
+	}
+}
+
+func TestFindLocalStopsAtHome(t *testing.T) {
+	home := setHome(t)
+
+	global := &Config{Plugins: make(map[string]PluginConfig)}
+	if err := global.Save(filepath.Join(home, ".workshot", "config.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(home, "projects", "app")
+	os.MkdirAll(dir, 0755)
+
+	if path := FindLocal(dir); path != "" {
+		t.Errorf("Global config should not be found as local config, got %s", path)
+	}
+}
+
+func TestPathsAndRoots(t *testing.T) {
+	home := setHome(t)
+
+	base := &Config{}
+	base.SetPath("/home/alice/src", "~/code")
+	base.SetPath("/work", "/srv/work")
+	base.AddRoot("~/code")
+
+	local := &Config{}
+	local.SetPath("/work/", "/mnt/work")
+	local.AddRoot("/mnt/work")
+	if local.AddRoot("/mnt/work") {
+		t.Error("Expected adding a root twice to report false")
+	}
+
+	merged := Merge(base, local)
+
+	rules := merged.PathRules()
+	if rules["/home/alice/src"] != filepath.Join(home, "code") || rules["/work"] != "/mnt/work" {
+		t.Errorf("Unexpected path rules: %v", rules)
+	}
+
+	roots := merged.SearchRoots()
+	if len(roots) != 2 || roots[0] != "/mnt/work" || roots[1] != filepath.Join(home, "code") {
+		t.Errorf("Expected local roots first, got %v", roots)
+	}
+
+	if !merged.RemovePath("/work") || merged.RemovePath("/work") {
+		t.Error("Expected the mapping to be removed once")
+	}
+	if !merged.RemoveRoot("~/code") || len(merged.Roots) != 1 {
+		t.Errorf("Expected ~/code to be removed, got %v", merged.Roots)
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	home := setHome(t)
+
+	tests := map[string]string{
+		"~":          home,
+		"~/code/api": filepath.Join(home, "code", "api"),
+		"~alice/src": "~alice/src",
+		"/srv/code/": "/srv/code",
+		"":           "",
+	}
+	for path, want := range tests {
+		if got := ExpandHome(path); got != want {
+			t.Errorf("ExpandHome(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestEncryptionIsGlobalOnly(t *testing.T) {
+	base := &Config{Encryption: &EncryptionConfig{Enabled: true, Keyfile: "~/.workshot/key"}}
+	local := &Config{Encryption: &EncryptionConfig{Enabled: false}}
+
+	// a project config cannot turn encryption off
+	merged := Merge(base, local)
+	if merged.Encryption == nil || !merged.Encryption.Enabled || merged.Encryption.Keyfile != "~/.workshot/key" {
+		t.Errorf("Expected the global encryption settings, got %+v", merged.Encryption)
+	}
+
+	if Merge(&Config{}, local).Encryption != nil {
+		t.Error("Expected local encryption settings to be ignored")
+	}
+}
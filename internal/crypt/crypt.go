@@ -0,0 +1,215 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	// PassphraseEnv holds the passphrase keys are derived from.
+	PassphraseEnv = "WORKSHOT_PASSPHRASE"
+
+	formatVersion = 1
+	iterations    = 600000
+	saltSize      = 16
+	keySize       = 32 // aes-256
+
+	kdfPassphrase = "pbkdf2-sha256"
+	kdfKeyfile    = "keyfile"
+)
+
+// ErrNoKey is returned when data needs a key that is not configured.
+var ErrNoKey = errors.New("no encryption key, set " + PassphraseEnv + " or configure a keyfile")
+
+// envelope is the on-disk form of encrypted data
+// it is json so encrypted snapshots are still recognizable files
+type envelope struct {
+	Version    int    `json:"workshot_encrypted"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations,omitempty"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// key encrypts and decrypts with aes-gcm
+// it holds a passphrase, a keyfile key or both, keyfiles are preferred
+type Key struct {
+	passphrase []byte
+	keyfile    []byte
+
+	// keys derived from the passphrase by salt, derivation is slow
+	derived map[string][]byte
+	salt    []byte
+}
+
+// load returns the key from the keyfile, if set, and the passphrase in the
+// environment, or nil if neither is available
+func Load(keyfile string) (*Key, error) {
+	key := &Key{derived: make(map[string][]byte)}
+
+	if keyfile != "" {
+		data, err := os.ReadFile(keyfile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keyfile: %w", err)
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) < keySize {
+			return nil, fmt.Errorf("keyfile %s is too short, it needs at least %d bytes", keyfile, keySize)
+		}
+		sum := sha256.Sum256(data)
+		key.keyfile = sum[:]
+	}
+
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" {
+		key.passphrase = []byte(passphrase)
+	}
+
+	if key.keyfile == nil && key.passphrase == nil {
+		return nil, nil
+	}
+	return key, nil
+}
+
+// generatekeyfile writes a new random keyfile readable only by the user
+func GenerateKeyfile(path string) error {
+	raw := make([]byte, keySize)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	encoded := fmt.Appendf(nil, "%x\n", raw)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create keyfile: %w", err)
+	}
+	if _, err := file.Write(encoded); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write keyfile: %w", err)
+	}
+	return file.Close()
+}
+
+// isencrypted reports whether data was written by encrypt
+func IsEncrypted(data []byte) bool {
+	var env struct {
+		Version int `json:"workshot_encrypted"`
+	}
+	return json.Unmarshal(data, &env) == nil && env.Version > 0
+}
+
+// encrypt seals data, aad is authenticated but not encrypted
+// it ties the data to where it belongs, e.g. the snapshot name
+func (k *Key) Encrypt(data, aad []byte) ([]byte, error) {
+	if k == nil {
+		return nil, ErrNoKey
+	}
+
+	env := envelope{Version: formatVersion, KDF: kdfKeyfile}
+	secret := k.keyfile
+	if secret == nil {
+		if k.salt == nil {
+			k.salt = make([]byte, saltSize)
+			if _, err := rand.Read(k.salt); err != nil {
+				return nil, fmt.Errorf("failed to generate salt: %w", err)
+			}
+		}
+
+		var err error
+		if secret, err = k.derive(k.salt, iterations); err != nil {
+			return nil, err
+		}
+		env.KDF, env.Iterations, env.Salt = kdfPassphrase, iterations, k.salt
+	}
+
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	env.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	env.Data = aead.Seal(nil, env.Nonce, data, aad)
+
+	return json.MarshalIndent(env, "", "  ")
+}
+
+// decrypt opens data sealed by encrypt with the same aad
+func (k *Key) Decrypt(data, aad []byte) ([]byte, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("invalid encrypted data: %w", err)
+	}
+	if env.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported encryption version %d", env.Version)
+	}
+	if k == nil {
+		return nil, ErrNoKey
+	}
+
+	var secret []byte
+	switch env.KDF {
+	case kdfKeyfile:
+		if k.keyfile == nil {
+			return nil, fmt.Errorf("encrypted with a keyfile, but none is configured")
+		}
+		secret = k.keyfile
+	case kdfPassphrase:
+		if k.passphrase == nil {
+			return nil, fmt.Errorf("encrypted with a passphrase, set %s", PassphraseEnv)
+		}
+		var err error
+		if secret, err = k.derive(env.Salt, env.Iterations); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown key derivation %q", env.KDF)
+	}
+
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid encrypted data: bad nonce")
+	}
+
+	plain, err := aead.Open(nil, env.Nonce, env.Data, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt, wrong key or tampered data")
+	}
+	return plain, nil
+}
+
+// derive the passphrase key for a salt, reusing earlier derivations
+func (k *Key) derive(salt []byte, iter int) ([]byte, error) {
+	cacheKey := fmt.Sprintf("%x/%d", salt, iter)
+	if secret, ok := k.derived[cacheKey]; ok {
+		return secret, nil
+	}
+
+	secret, err := pbkdf2.Key(sha256.New, string(k.passphrase), salt, iter, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	k.derived[cacheKey] = secret
+	return secret, nil
+}
+
+func newAEAD(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,129 @@
+package crypt
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadWithoutKey(t *testing.T) {
+	t.Setenv(PassphraseEnv, "")
+
+	key, err := Load("")
+	if err != nil || key != nil {
+		t.Fatalf("Expected no key, got %v, %v", key, err)
+	}
+
+	if _, err := key.Encrypt([]byte("data"), nil); err != ErrNoKey {
+		t.Errorf("Expected ErrNoKey, got %v", err)
+	}
+}
+
+func TestPassphraseRoundTrip(t *testing.T) {
+	t.Setenv(PassphraseEnv, "correct horse battery staple")
+
+	key, err := Load("")
+	if err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+
+	plain := []byte(`{"working_dir": "/home/alice/api"}`)
+	sealed, err := key.Encrypt(plain, []byte("api"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if !IsEncrypted(sealed) || IsEncrypted(plain) {
+		t.Error("IsEncrypted does not tell encrypted data apart")
+	}
+	if bytes.Contains(sealed, []byte("alice")) {
+		t.Errorf("Encrypted data leaks the plaintext: %s", sealed)
+	}
+
+	// a fresh key derives the same secret from the salt in the data
+	other, _ := Load("")
+	opened, err := other.Decrypt(sealed, []byte("api"))
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	if !bytes.Equal(opened, plain) {
+		t.Errorf("Round trip mismatch: got %s", opened)
+	}
+
+	t.Setenv(PassphraseEnv, "wrong")
+	wrong, _ := Load("")
+	if _, err := wrong.Decrypt(sealed, []byte("api")); err == nil {
+		t.Error("Expected decrypting with the wrong passphrase to fail")
+	}
+}
+
+func TestKeyfileRoundTrip(t *testing.T) {
+	t.Setenv(PassphraseEnv, "")
+	path := filepath.Join(t.TempDir(), "key")
+
+	if err := GenerateKeyfile(path); err != nil {
+		t.Fatalf("Failed to generate keyfile: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected keyfile with mode 0600, got %v, %v", info, err)
+	}
+	if err := GenerateKeyfile(path); err == nil {
+		t.Error("Expected GenerateKeyfile to refuse an existing file")
+	}
+
+	key, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load keyfile: %v", err)
+	}
+
+	sealed, err := key.Encrypt([]byte("secret"), []byte("api"))
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if !strings.Contains(string(sealed), `"kdf": "keyfile"`) {
+		t.Errorf("Expected a keyfile envelope, got %s", sealed)
+	}
+
+	opened, err := key.Decrypt(sealed, []byte("api"))
+	if err != nil || string(opened) != "secret" {
+		t.Fatalf("Round trip failed: %q, %v", opened, err)
+	}
+
+	// the passphrase alone cannot open keyfile data
+	t.Setenv(PassphraseEnv, "something")
+	passphrase, _ := Load("")
+	if _, err := passphrase.Decrypt(sealed, []byte("api")); err == nil {
+		t.Error("Expected decrypting without the keyfile to fail")
+	}
+}
+
+func TestShortKeyfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	os.WriteFile(path, []byte("too short"), 0600)
+
+	if _, err := Load(path); err == nil {
+		t.Error("Expected a short keyfile to be rejected")
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	GenerateKeyfile(path)
+	key, _ := Load(path)
+
+	sealed, err := key.Encrypt([]byte("secret"), []byte("api"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the aad ties the data to its name
+	if _, err := key.Decrypt(sealed, []byte("web")); err == nil {
+		t.Error("Expected decrypting under another name to fail")
+	}
+
+	tampered := bytes.Replace(sealed, []byte(`"data": "`), []byte(`"data": "AA`), 1)
+	if _, err := key.Decrypt(tampered, []byte("api")); err == nil {
+		t.Error("Expected tampered data to fail")
+	}
+}
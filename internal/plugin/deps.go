@@ -0,0 +1,119 @@
+package plugin
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// dependencies returns the plugins a capturer declares it needs
+func dependencies(c types.Capturer) []string {
+	if d, ok := c.(types.Dependent); ok {
+		return d.DependsOn()
+	}
+	return nil
+}
+
+// order sorts capturers so that dependencies come first
+// ties are broken by priority, then by registration order
+// plugins with unknown dependencies or in a cycle are returned in broken
+// and placed at the end so they can still be reported
+func (m *Manager) order() ([]types.Capturer, map[string]error) {
+	broken := make(map[string]error)
+	index := make(map[string]int, len(m.capturers))
+	for i, c := range m.capturers {
+		index[c.Name()] = i
+	}
+
+	// count unfinished dependencies of each plugin
+	pending := make([]int, len(m.capturers))
+	dependents := make([][]int, len(m.capturers))
+	for i, c := range m.capturers {
+		var missing []string
+		for _, dep := range dependencies(c) {
+			j, ok := index[dep]
+			if !ok {
+				missing = append(missing, dep)
+				continue
+			}
+			pending[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+
+		if len(missing) > 0 {
+			broken[c.Name()] = fmt.Errorf("missing dependency %s", strings.Join(missing, ", "))
+		}
+	}
+
+	ordered := make([]types.Capturer, 0, len(m.capturers))
+	done := make([]bool, len(m.capturers))
+
+	for len(ordered) < len(m.capturers) {
+		// pick the ready plugin with the lowest priority
+		next := -1
+		for i, c := range m.capturers {
+			if done[i] || pending[i] > 0 {
+				continue
+			}
+			if next < 0 || c.Priority() < m.capturers[next].Priority() {
+				next = i
+			}
+		}
+		if next < 0 {
+			break // the rest wait on each other
+		}
+
+		done[next] = true
+		ordered = append(ordered, m.capturers[next])
+		for _, i := range dependents[next] {
+			pending[i]--
+		}
+	}
+
+	// whatever is left is in a dependency cycle or waits on one
+	var cycle []string
+	for i, c := range m.capturers {
+		if !done[i] {
+			cycle = append(cycle, c.Name())
+		}
+	}
+	for i, c := range m.capturers {
+		if !done[i] {
+			broken[c.Name()] = fmt.Errorf("dependency cycle among %s", strings.Join(cycle, ", "))
+			ordered = append(ordered, c)
+		}
+	}
+
+	return ordered, broken
+}
+
+// checkdependencies reports unknown dependencies and cycles
+func (m *Manager) CheckDependencies() []error {
+	ordered, broken := m.order()
+
+	var errors []error
+	for _, c := range ordered {
+		if err, ok := broken[c.Name()]; ok {
+			errors = append(errors, fmt.Errorf("plugin %s: %w", c.Name(), err))
+		}
+	}
+	return errors
+}
+
+// dependencydata collects the output of a capturer's dependencies
+func dependencyData(c types.Capturer, pluginData map[string]interface{}) map[string]map[string]interface{} {
+	deps := dependencies(c)
+	data := make(map[string]map[string]interface{}, len(deps))
+
+	for name, value := range pluginData {
+		if !slices.Contains(deps, name) {
+			continue
+		}
+		if dataMap, ok := value.(map[string]interface{}); ok {
+			data[name] = dataMap
+		}
+	}
+	return data
+}
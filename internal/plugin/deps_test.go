@@ -0,0 +1,184 @@
+package plugin
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// dependentCapturer declares dependencies and records what it could read
+type dependentCapturer struct {
+	mockCapturer
+	deps []string
+	seen map[string]interface{}
+}
+
+func (d *dependentCapturer) DependsOn() []string {
+	return d.deps
+}
+
+func (d *dependentCapturer) Capture(ctx context.Context) (map[string]interface{}, error) {
+	for _, dep := range d.deps {
+		if data, ok := types.DependencyData(ctx, dep); ok {
+			d.seen[dep] = data["key"]
+		}
+	}
+	return d.captureData, d.captureError
+}
+
+func (d *dependentCapturer) Restore(ctx context.Context, data map[string]interface{}) error {
+	for _, dep := range d.deps {
+		if data, ok := types.DependencyData(ctx, dep); ok {
+			d.seen["restore:"+dep] = data["key"]
+		}
+	}
+	return nil
+}
+
+func newDependent(name string, priority int, deps ...string) *dependentCapturer {
+	return &dependentCapturer{
+		mockCapturer: mockCapturer{
+			name:        name,
+			priority:    priority,
+			captureData: map[string]interface{}{"key": name},
+			canRestore:  true,
+		},
+		deps: deps,
+		seen: make(map[string]interface{}),
+	}
+}
+
+func TestManagerDependencyOrder(t *testing.T) {
+	manager := NewManager()
+	manager.Register(newDependent("editor", 10, "git", "cloud"))
+	manager.Register(newDependent("cloud", 50))
+	manager.Register(newDependent("notes", 50))
+	manager.Register(newDependent("git", 20))
+
+	// editor waits for its dependencies despite the lowest priority,
+	// and cloud comes before notes because it registered first
+	got := strings.Join(manager.ListCapturers(), ",")
+	if got != "git,cloud,editor,notes" {
+		t.Errorf("Unexpected order: %s", got)
+	}
+
+	if errors := manager.CheckDependencies(); len(errors) != 0 {
+		t.Errorf("Expected no dependency errors, got %v", errors)
+	}
+}
+
+func TestManagerDependencyData(t *testing.T) {
+	editor := newDependent("editor", 10, "git", "cloud")
+
+	manager := NewManager()
+	manager.Register(editor)
+	manager.Register(newDependent("git", 20))
+	manager.Register(newDependent("cloud", 30))
+	manager.Disable("cloud")
+
+	data, _, err := manager.CaptureAll(t.Context())
+	if err != nil {
+		t.Fatalf("CaptureAll failed: %v", err)
+	}
+
+	if editor.seen["git"] != "git" {
+		t.Errorf("Expected editor to read git output, saw %v", editor.seen)
+	}
+	if _, ok := editor.seen["cloud"]; ok {
+		t.Error("Disabled dependency should not be visible")
+	}
+
+	manager.Enable("cloud")
+	data["cloud"] = map[string]interface{}{"key": "cloud"}
+	reports, err := manager.RestoreAll(t.Context(), data, Selection{})
+	if err != nil {
+		t.Fatalf("RestoreAll failed: %v", err)
+	}
+	for _, report := range reports {
+		if report.Status == types.RestoreFailed {
+			t.Fatalf("Restore of %s failed: %v", report.Name, report.Err)
+		}
+	}
+	if editor.seen["restore:git"] != "git" || editor.seen["restore:cloud"] != "cloud" {
+		t.Errorf("Expected editor to read saved dependency data on restore, saw %v", editor.seen)
+	}
+}
+
+func TestManagerDependencyErrors(t *testing.T) {
+	manager := NewManager()
+	manager.Register(newDependent("git", 10))
+	manager.Register(newDependent("jira", 20, "issues"))
+	manager.Register(newDependent("a", 30, "b"))
+	manager.Register(newDependent("b", 30, "a"))
+
+	errors := manager.CheckDependencies()
+	if len(errors) != 3 {
+		t.Fatalf("Expected 3 dependency errors, got %v", errors)
+	}
+	if !strings.Contains(errors[0].Error(), "missing dependency issues") {
+		t.Errorf("Expected missing dependency error, got %v", errors[0])
+	}
+	if !strings.Contains(errors[1].Error(), "dependency cycle among a, b") {
+		t.Errorf("Expected cycle error, got %v", errors[1])
+	}
+
+	data, reports, err := manager.CaptureAll(t.Context())
+	if err != nil {
+		t.Fatalf("CaptureAll failed: %v", err)
+	}
+	if len(data) != 1 || data["git"] == nil {
+		t.Errorf("Expected only git to be captured, got %v", data)
+	}
+
+	failed := 0
+	for _, report := range reports {
+		if report.Failed() {
+			failed++
+		}
+	}
+	if failed != 3 {
+		t.Errorf("Expected 3 failed reports, got %+v", reports)
+	}
+}
+
+func TestManagerCaptureFor(t *testing.T) {
+	manager := NewManager()
+	manager.Register(newDependent("git", 1))
+	manager.Register(newDependent("cloud", 2))
+	manager.Register(newDependent("review", 3, "git"))
+	manager.Disable("git")
+
+	// review needs git, which is captured even though it is disabled
+	data, reports, err := manager.CaptureFor(t.Context(), []string{"review"})
+	if err != nil {
+		t.Fatalf("CaptureFor failed: %v", err)
+	}
+	if len(data) != 2 || data["git"] == nil || data["review"] == nil {
+		t.Errorf("Expected git and review data, got %v", data)
+	}
+	if len(reports) != 2 {
+		t.Errorf("Expected 2 reports, got %+v", reports)
+	}
+}
+
+func TestManagerRestorable(t *testing.T) {
+	manager := NewManager()
+	manager.Register(newDependent("git", 1))
+	manager.Register(newDependent("cloud", 2))
+	manager.Register(&mockCapturer{name: "terminal", priority: 3})
+	manager.Register(newDependent("review", 4, "git"))
+
+	pluginData := map[string]interface{}{
+		"git":      map[string]interface{}{"key": "git"},
+		"cloud":    map[string]interface{}{"key": "cloud"},
+		"terminal": map[string]interface{}{"key": "terminal"},
+	}
+
+	// review has no data and terminal cannot restore
+	names := manager.Restorable(pluginData, Selection{Except: []string{"cloud"}})
+	if strings.Join(names, ",") != "git" {
+		t.Errorf("Expected only git to restore, got %v", names)
+	}
+}
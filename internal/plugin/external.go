@@ -0,0 +1,487 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+const (
+	// ExternalPrefix is the file name prefix of external plugin executables.
+	ExternalPrefix = "workshot-plugin-"
+
+	// ProtocolVersion is the version of the external plugin json protocol.
+	// It is sent with every request and must be echoed back in the response.
+	ProtocolVersion = 1
+
+	// externalPriority orders plugins that have not described themselves yet
+	externalPriority = 100
+)
+
+// actions understood by external plugins
+const (
+	actionDescribe   = "describe"
+	actionCapture    = "capture"
+	actionRestore    = "restore"
+	actionCanRestore = "can_restore"
+	actionMigrate    = "migrate"
+	actionPlan       = "plan"
+	actionPreview    = "preview"
+)
+
+// externalrequest is written to the plugin's stdin
+type externalRequest struct {
+	ProtocolVersion int                    `json:"protocol_version"`
+	Action          string                 `json:"action"`
+	WorkingDir      string                 `json:"working_dir"`
+	Data            map[string]interface{} `json:"data,omitempty"`
+	DataVersion     int                    `json:"data_version,omitempty"` // saved version, for migrate
+
+	// output of the plugins named in depends_on
+	Dependencies map[string]map[string]interface{} `json:"dependencies,omitempty"`
+}
+
+// externalresponse is read from the plugin's stdout
+type externalResponse struct {
+	ProtocolVersion int                    `json:"protocol_version"`
+	Name            string                 `json:"name,omitempty"`
+	Priority        int                    `json:"priority,omitempty"`
+	DependsOn       []string               `json:"depends_on,omitempty"`
+	DataVersion     int                    `json:"data_version,omitempty"`
+	Data            map[string]interface{} `json:"data,omitempty"`
+	CanRestore      bool                   `json:"can_restore,omitempty"`
+	Actions         []types.Action         `json:"actions,omitempty"`
+	Steps           []types.Step           `json:"steps,omitempty"`
+	Error           string                 `json:"error,omitempty"`
+}
+
+// externalcapturer wraps a plugin executable as a capturer
+type ExternalCapturer struct {
+	path string
+	name string
+
+	// from the describe response, filled in by describe
+	description
+	once        sync.Once
+	describeErr error
+}
+
+// description is what a plugin says about itself, cached between runs
+type description struct {
+	Name        string   `json:"name"`
+	Priority    int      `json:"priority,omitempty"`
+	DependsOn   []string `json:"depends_on,omitempty"`
+	DataVersion int      `json:"data_version,omitempty"`
+	described   bool
+}
+
+// newexternalcapturer asks the executable to describe itself
+func NewExternalCapturer(path string) (*ExternalCapturer, error) {
+	e := &ExternalCapturer{path: path}
+	if err := e.describe(); err != nil {
+		return nil, err
+	}
+	e.name = e.Name()
+	return e, nil
+}
+
+// newlazyexternal registers a plugin by its file name, it is only asked to
+// describe itself when it runs
+func newLazyExternal(path string) *ExternalCapturer {
+	return &ExternalCapturer{path: path, name: externalName(path)}
+}
+
+// describe runs the describe action once, unless the plugin was cached
+func (e *ExternalCapturer) describe() error {
+	e.once.Do(func() {
+		if e.described {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+		defer cancel()
+
+		resp, err := e.call(ctx, externalRequest{Action: actionDescribe})
+		if err != nil {
+			e.describeErr = err
+			return
+		}
+		if resp.Name == "" {
+			e.describeErr = fmt.Errorf("%s: describe returned no name", e.path)
+			return
+		}
+
+		e.description = description{
+			Name:        resp.Name,
+			Priority:    resp.Priority,
+			DependsOn:   resp.DependsOn,
+			DataVersion: resp.DataVersion,
+			described:   true,
+		}
+	})
+	return e.describeErr
+}
+
+func (e *ExternalCapturer) Name() string {
+	if e.name != "" {
+		return e.name
+	}
+	return e.description.Name
+}
+
+// priority returns the priority from the describe response, plugins that
+// have not run yet sort after the built-in ones
+func (e *ExternalCapturer) Priority() int {
+	if !e.described {
+		return externalPriority
+	}
+	return e.description.Priority
+}
+
+// dependson returns the plugins named in the describe response
+func (e *ExternalCapturer) DependsOn() []string {
+	return e.description.DependsOn
+}
+
+// dataversion returns the version from the describe response, default 1
+func (e *ExternalCapturer) DataVersion() int {
+	e.describe()
+	if e.description.DataVersion > 0 {
+		return e.description.DataVersion
+	}
+	return 1
+}
+
+// migratedata asks the plugin to upgrade data saved by an older version
+func (e *ExternalCapturer) MigrateData(from int, data map[string]interface{}) (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	resp, err := e.call(ctx, externalRequest{Action: actionMigrate, Data: data, DataVersion: from})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// path returns the plugin executable
+func (e *ExternalCapturer) Path() string {
+	return e.path
+}
+
+func (e *ExternalCapturer) Capture(ctx context.Context) (map[string]interface{}, error) {
+	resp, err := e.call(ctx, externalRequest{Action: actionCapture})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+func (e *ExternalCapturer) Restore(ctx context.Context, data map[string]interface{}) error {
+	_, err := e.call(ctx, externalRequest{Action: actionRestore, Data: data})
+	return err
+}
+
+func (e *ExternalCapturer) CanRestore(data map[string]interface{}) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	resp, err := e.call(ctx, externalRequest{Action: actionCanRestore, Data: data})
+	if err != nil {
+		return false
+	}
+	return resp.CanRestore
+}
+
+// restoreplan asks the plugin for shell actions
+// plugins that do not support the plan action have none
+func (e *ExternalCapturer) RestorePlan(data map[string]interface{}) []types.Action {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	resp, err := e.call(ctx, externalRequest{Action: actionPlan, Data: data})
+	if err != nil {
+		return nil
+	}
+	return resp.Actions
+}
+
+// preview asks the plugin what its restore would do
+// plugins that do not support the preview action get the generic step
+func (e *ExternalCapturer) Preview(ctx context.Context, data map[string]interface{}) []types.Step {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	resp, err := e.call(ctx, externalRequest{Action: actionPreview, Data: data})
+	if err != nil {
+		return genericPreview(e.name)
+	}
+	return resp.Steps
+}
+
+// run the plugin once for an action
+func (e *ExternalCapturer) call(ctx context.Context, request externalRequest) (*externalResponse, error) {
+	cwd, _ := os.Getwd()
+	action := request.Action
+
+	if action != actionDescribe {
+		if err := e.describe(); err != nil {
+			return nil, err
+		}
+	}
+
+	request.ProtocolVersion = ProtocolVersion
+	request.WorkingDir = cwd
+	for _, dep := range e.description.DependsOn {
+		if depData, ok := types.DependencyData(ctx, dep); ok {
+			if request.Dependencies == nil {
+				request.Dependencies = make(map[string]map[string]interface{})
+			}
+			request.Dependencies[dep] = depData
+		}
+	}
+
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.path)
+	cmd.Stdin = bytes.NewReader(encoded)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %s", action, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %w", action, err)
+	}
+
+	var resp externalResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("%s: invalid response: %w", action, err)
+	}
+
+	if resp.ProtocolVersion != ProtocolVersion {
+		return nil, fmt.Errorf("%s: unsupported protocol version %d (expected %d)",
+			action, resp.ProtocolVersion, ProtocolVersion)
+	}
+
+	if resp.Error != "" {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	return &resp, nil
+}
+
+// externalplugindirs returns ~/.workshot/plugins followed by PATH entries
+func ExternalPluginDirs() []string {
+	var dirs []string
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".workshot", "plugins"))
+	}
+	return append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+}
+
+// discoverexternal finds plugin executables in dirs
+// earlier dirs win when the same plugin file name appears twice
+func DiscoverExternal(dirs []string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasPrefix(name, ExternalPrefix) {
+				continue
+			}
+
+			// windows plugins may differ only by extension
+			base := name
+			if runtime.GOOS == "windows" {
+				base = strings.TrimSuffix(name, filepath.Ext(name))
+			}
+			if seen[base] {
+				continue
+			}
+
+			path := filepath.Join(dir, name)
+			if !isExecutable(path) {
+				continue
+			}
+
+			seen[base] = true
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// externalcachepath returns ~/.workshot/cache/plugins.json
+func ExternalCachePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".workshot", "cache", "plugins.json")
+}
+
+// loadexternal discovers and registers external plugins
+// describe responses are cached in cachePath, if set, until the executable
+// changes; plugins disabled before loading are not described at all
+// plugins that fail to load or clash with a registered name are reported
+func (m *Manager) LoadExternal(dirs []string, cachePath string) []error {
+	var errors []error
+	cache := loadDescribeCache(cachePath)
+
+	for _, path := range DiscoverExternal(dirs) {
+		capturer, err := cache.capturer(path)
+		if err == nil && capturer == nil {
+			if m.disabled[externalName(path)] {
+				m.Register(newLazyExternal(path))
+				continue
+			}
+
+			if capturer, err = NewExternalCapturer(path); err == nil {
+				cache.store(capturer)
+			}
+		}
+		if err != nil {
+			errors = append(errors, fmt.Errorf("plugin %s: %w", filepath.Base(path), err))
+			continue
+		}
+
+		if m.has(capturer.Name()) {
+			errors = append(errors, fmt.Errorf("plugin %s: name '%s' is already registered",
+				filepath.Base(path), capturer.Name()))
+			continue
+		}
+
+		m.Register(capturer)
+	}
+
+	cache.save()
+	return errors
+}
+
+// externalname is the plugin name in an executable's file name
+func externalName(path string) string {
+	name := strings.TrimPrefix(filepath.Base(path), ExternalPrefix)
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name
+}
+
+// describecache holds describe responses by executable path
+type describeCache struct {
+	path    string
+	entries map[string]cacheEntry
+	changed bool
+}
+
+// cacheentry is a describe response and the executable it came from
+type cacheEntry struct {
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"mod_time"`
+	Plugin  description `json:"plugin"`
+}
+
+// load the cache, a missing or broken cache is empty
+func loadDescribeCache(path string) *describeCache {
+	cache := &describeCache{path: path, entries: make(map[string]cacheEntry)}
+	if path == "" {
+		return cache
+	}
+
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &cache.entries)
+	}
+	return cache
+}
+
+// capturer returns the cached plugin of an executable, or nil if the
+// executable changed since it was cached
+func (c *describeCache) capturer(path string) (*ExternalCapturer, error) {
+	entry, ok := c.entries[path]
+	if !ok {
+		return nil, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() != entry.Size || !info.ModTime().Equal(entry.ModTime) || entry.Plugin.Name == "" {
+		return nil, nil
+	}
+
+	e := &ExternalCapturer{path: path, name: entry.Plugin.Name, description: entry.Plugin}
+	e.described = true
+	return e, nil
+}
+
+// store caches the describe response of a plugin
+func (c *describeCache) store(e *ExternalCapturer) {
+	info, err := os.Stat(e.path)
+	if err != nil {
+		return
+	}
+
+	c.entries[e.path] = cacheEntry{Size: info.Size(), ModTime: info.ModTime(), Plugin: e.description}
+	c.changed = true
+}
+
+// save writes the cache if plugins were described
+func (c *describeCache) save() {
+	if c.path == "" || !c.changed {
+		return
+	}
+
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err == nil {
+		os.WriteFile(c.path, data, 0600)
+	}
+}
+
+// check if a file can be executed
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".exe", ".bat", ".cmd":
+			return true
+		}
+		return false
+	}
+
+	return info.Mode()&0111 != 0
+}
@@ -0,0 +1,342 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// external plugin that echoes its request data back on restore
+const testPluginScript = `#!/bin/sh
+request=$(cat)
+case "$request" in
+  *'"action":"describe"'*)
+    echo '{"protocol_version":1,"name":"notes","priority":70}' ;;
+  *'"action":"capture"'*)
+    echo '{"protocol_version":1,"data":{"todo":"finish review"}}' ;;
+  *'"action":"can_restore"'*)
+    echo '{"protocol_version":1,"can_restore":true}' ;;
+  *'"action":"restore"'*)
+    echo "$request" > "$PLUGIN_RESTORE_LOG"
+    echo '{"protocol_version":1}' ;;
+esac
+`
+
+func writePlugin(t *testing.T, dir, name, script string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func skipWithoutShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test plugins are posix shell scripts")
+	}
+}
+
+func TestDiscoverExternal(t *testing.T) {
+	skipWithoutShell(t)
+
+	first := t.TempDir()
+	second := t.TempDir()
+
+	writePlugin(t, first, "workshot-plugin-notes", testPluginScript)
+	writePlugin(t, second, "workshot-plugin-notes", testPluginScript)
+	writePlugin(t, second, "workshot-plugin-jira", testPluginScript)
+	writePlugin(t, second, "unrelated-tool", testPluginScript)
+	os.WriteFile(filepath.Join(second, "workshot-plugin-readme"), []byte("not executable"), 0644)
+
+	paths := DiscoverExternal([]string{first, filepath.Join(first, "missing"), second})
+
+	if len(paths) != 2 {
+		t.Fatalf("Expected 2 plugins, got %v", paths)
+	}
+	if paths[0] != filepath.Join(first, "workshot-plugin-notes") {
+		t.Errorf("Expected earlier directory to win, got %s", paths[0])
+	}
+	if filepath.Base(paths[1]) != "workshot-plugin-jira" {
+		t.Errorf("Expected jira plugin, got %s", paths[1])
+	}
+}
+
+func TestExternalCapturer(t *testing.T) {
+	skipWithoutShell(t)
+
+	logPath := filepath.Join(t.TempDir(), "restore.json")
+	t.Setenv("PLUGIN_RESTORE_LOG", logPath)
+
+	path := writePlugin(t, t.TempDir(), "workshot-plugin-notes", testPluginScript)
+	capturer, err := NewExternalCapturer(path)
+	if err != nil {
+		t.Fatalf("Failed to load plugin: %v", err)
+	}
+
+	if capturer.Name() != "notes" || capturer.Priority() != 70 {
+		t.Errorf("Expected notes with priority 70, got %s with %d", capturer.Name(), capturer.Priority())
+	}
+
+	data, err := capturer.Capture(t.Context())
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+	if data["todo"] != "finish review" {
+		t.Errorf("Unexpected capture data: %v", data)
+	}
+
+	if !capturer.CanRestore(data) {
+		t.Error("Expected plugin to be able to restore")
+	}
+	if err := capturer.Restore(t.Context(), data); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	request, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Plugin did not receive restore request: %v", err)
+	}
+	if !strings.Contains(string(request), `"data":{"todo":"finish review"}`) ||
+		!strings.Contains(string(request), `"protocol_version":1`) {
+		t.Errorf("Unexpected restore request: %s", request)
+	}
+}
+
+func TestExternalCapturerErrors(t *testing.T) {
+	skipWithoutShell(t)
+
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"wrong version", `echo '{"protocol_version":2,"name":"x"}'`, "unsupported protocol version 2"},
+		{"plugin error", `echo '{"protocol_version":1,"error":"no token"}'`, "no token"},
+		{"bad json", `echo 'hello'`, "invalid response"},
+		{"crash", `echo 'boom' >&2; exit 3`, "boom"},
+		{"no name", `echo '{"protocol_version":1}'`, "no name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writePlugin(t, dir, "workshot-plugin-"+strings.ReplaceAll(tt.name, " ", "-"),
+				"#!/bin/sh\ncat > /dev/null\n"+tt.script+"\n")
+
+			_, err := NewExternalCapturer(path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestManagerLoadExternal(t *testing.T) {
+	skipWithoutShell(t)
+
+	dir := t.TempDir()
+	writePlugin(t, dir, "workshot-plugin-notes", testPluginScript)
+	writePlugin(t, dir, "workshot-plugin-git", "#!/bin/sh\ncat > /dev/null\necho '{\"protocol_version\":1,\"name\":\"git\"}'\n")
+
+	manager := NewManager()
+	manager.Register(&mockCapturer{name: "git", priority: 10})
+
+	errors := manager.LoadExternal([]string{dir}, "")
+	if len(errors) != 1 || !strings.Contains(errors[0].Error(), "already registered") {
+		t.Errorf("Expected one name clash error, got %v", errors)
+	}
+
+	names := manager.ListCapturers()
+	if len(names) != 2 || names[1] != "notes" {
+		t.Errorf("Expected git and notes registered, got %v", names)
+	}
+
+	data, _, err := manager.CaptureAll(t.Context())
+	if err != nil {
+		t.Fatalf("CaptureAll failed: %v", err)
+	}
+	if _, ok := data["notes"]; !ok {
+		t.Error("Missing external plugin data")
+	}
+}
+
+func TestExternalDependencies(t *testing.T) {
+	skipWithoutShell(t)
+
+	logPath := filepath.Join(t.TempDir(), "capture.json")
+	t.Setenv("PLUGIN_CAPTURE_LOG", logPath)
+
+	dir := t.TempDir()
+	writePlugin(t, dir, "workshot-plugin-review", `#!/bin/sh
+request=$(cat)
+case "$request" in
+  *'"action":"describe"'*)
+    echo '{"protocol_version":1,"name":"review","priority":5,"depends_on":["git"]}' ;;
+  *'"action":"capture"'*)
+    echo "$request" > "$PLUGIN_CAPTURE_LOG"
+    echo '{"protocol_version":1,"data":{"pr":42}}' ;;
+esac
+`)
+
+	manager := NewManager()
+	manager.Register(&mockCapturer{name: "git", priority: 10, captureData: map[string]interface{}{"branch": "main"}})
+	if errors := manager.LoadExternal([]string{dir}, ""); len(errors) != 0 {
+		t.Fatalf("LoadExternal failed: %v", errors)
+	}
+
+	if names := strings.Join(manager.ListCapturers(), ","); names != "git,review" {
+		t.Errorf("Expected review to run after git despite its priority, got %s", names)
+	}
+
+	if _, _, err := manager.CaptureAll(t.Context()); err != nil {
+		t.Fatalf("CaptureAll failed: %v", err)
+	}
+
+	request, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Plugin did not receive capture request: %v", err)
+	}
+	if !strings.Contains(string(request), `"dependencies":{"git":{"branch":"main"}}`) {
+		t.Errorf("Expected git output in capture request, got %s", request)
+	}
+}
+
+func TestExternalMigrate(t *testing.T) {
+	skipWithoutShell(t)
+
+	path := writePlugin(t, t.TempDir(), "workshot-plugin-notes", `#!/bin/sh
+request=$(cat)
+case "$request" in
+  *'"action":"describe"'*)
+    echo '{"protocol_version":1,"name":"notes","data_version":2}' ;;
+  *'"action":"migrate"'*'"data_version":1'*)
+    echo '{"protocol_version":1,"data":{"todos":["finish review"]}}' ;;
+  *)
+    echo '{"protocol_version":1,"error":"unexpected request"}' ;;
+esac
+`)
+
+	manager := NewManager()
+	if errors := manager.LoadExternal([]string{filepath.Dir(path)}, ""); len(errors) != 0 {
+		t.Fatalf("LoadExternal failed: %v", errors)
+	}
+
+	snap := types.NewSnapshot("old")
+	snap.PluginData["notes"] = map[string]interface{}{"todo": "finish review"}
+
+	if err := manager.MigratePluginData(snap); err != nil {
+		t.Fatalf("MigratePluginData failed: %v", err)
+	}
+
+	notes := snap.PluginData["notes"].(map[string]interface{})
+	if todos, ok := notes["todos"].([]interface{}); !ok || len(todos) != 1 {
+		t.Errorf("Expected migrated notes, got %v", notes)
+	}
+	if snap.PluginVersions["notes"] != 2 {
+		t.Errorf("Expected notes version 2, got %v", snap.PluginVersions)
+	}
+}
+
+func TestExternalPlan(t *testing.T) {
+	skipWithoutShell(t)
+
+	dir := t.TempDir()
+	writePlugin(t, dir, "workshot-plugin-notes", testPluginScript)
+	writePlugin(t, dir, "workshot-plugin-jira", `#!/bin/sh
+request=$(cat)
+case "$request" in
+  *'"action":"describe"'*)
+    echo '{"protocol_version":1,"name":"jira"}' ;;
+  *'"action":"plan"'*'"ticket":"WS-42"'*)
+    echo '{"protocol_version":1,"actions":[{"kind":"export","name":"JIRA_TICKET","value":"WS-42"}]}' ;;
+esac
+`)
+
+	manager := NewManager()
+	if errors := manager.LoadExternal([]string{dir}, ""); len(errors) != 0 {
+		t.Fatalf("LoadExternal failed: %v", errors)
+	}
+
+	// notes does not understand the plan action and contributes nothing
+	plan := manager.Plan(map[string]interface{}{
+		"jira":  map[string]interface{}{"ticket": "WS-42"},
+		"notes": map[string]interface{}{"todo": "finish review"},
+	}, Selection{})
+
+	if len(plan) != 1 || plan[0].Kind != types.ActionExport || plan[0].Name != "JIRA_TICKET" || plan[0].Value != "WS-42" {
+		t.Errorf("Unexpected plan: %v", plan)
+	}
+}
+
+func TestLoadExternalDescribesOnce(t *testing.T) {
+	skipWithoutShell(t)
+
+	logPath := filepath.Join(t.TempDir(), "describe.log")
+	t.Setenv("PLUGIN_DESCRIBE_LOG", logPath)
+
+	dir := t.TempDir()
+	script := `#!/bin/sh
+request=$(cat)
+case "$request" in
+  *'"action":"describe"'*)
+    echo describe >> "$PLUGIN_DESCRIBE_LOG"
+    echo '{"protocol_version":1,"name":"%s","priority":70}' ;;
+  *'"action":"capture"'*)
+    echo '{"protocol_version":1,"data":{"ok":true}}' ;;
+esac
+`
+	writePlugin(t, dir, "workshot-plugin-notes", strings.Replace(script, "%s", "notes", 1))
+	writePlugin(t, dir, "workshot-plugin-jira", strings.Replace(script, "%s", "jira", 1))
+	cachePath := filepath.Join(t.TempDir(), "cache", "plugins.json")
+
+	describes := func() int {
+		data, _ := os.ReadFile(logPath)
+		return strings.Count(string(data), "describe")
+	}
+
+	// jira is disabled in config, so only notes is described
+	manager := NewManager()
+	manager.Disable("jira")
+	if errors := manager.LoadExternal([]string{dir}, cachePath); len(errors) != 0 {
+		t.Fatalf("LoadExternal failed: %v", errors)
+	}
+	if describes() != 1 {
+		t.Fatalf("Expected 1 describe, got %d", describes())
+	}
+	if names := strings.Join(manager.ListCapturers(), ","); names != "notes,jira" {
+		t.Errorf("Expected notes and jira registered, got %s", names)
+	}
+
+	// the next run uses the cache
+	manager = NewManager()
+	if errors := manager.LoadExternal([]string{dir}, cachePath); len(errors) != 0 {
+		t.Fatalf("LoadExternal failed: %v", errors)
+	}
+	if describes() != 2 {
+		t.Errorf("Expected only jira to be described, got %d describes", describes())
+	}
+	if c, ok := manager.Get("notes"); !ok || c.Priority() != 70 {
+		t.Errorf("Expected cached notes with priority 70, got %v", c)
+	}
+
+	// a disabled plugin that is enabled again describes itself when it runs
+	manager = NewManager()
+	manager.Disable("jira")
+	os.Remove(cachePath)
+	manager.LoadExternal([]string{dir}, "")
+	manager.Enable("jira")
+
+	data, _, err := manager.CaptureAll(t.Context())
+	if err != nil {
+		t.Fatalf("CaptureAll failed: %v", err)
+	}
+	if _, ok := data["jira"]; !ok {
+		t.Errorf("Expected jira data, got %v", data)
+	}
+}
@@ -1,84 +1,381 @@
-﻿package plugin
+package plugin
 
 import (
-    "fmt"
-    "sort"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
 
-    "github.com/ansoncodes/workshot/pkg/types"
+	"github.com/ansoncodes/workshot/pkg/types"
 )
 
+// DefaultTimeout bounds each plugin's capture unless configured otherwise
+const DefaultTimeout = 30 * time.Second
+
 type Manager struct {
-    capturers []types.Capturer
+	capturers []types.Capturer
+	disabled  map[string]bool
+	timeouts  map[string]time.Duration
+	confirm   func(name string, steps []types.Step) bool
+}
+
+// captureresult is the outcome of one plugin's capture
+type captureResult struct {
+	data     map[string]interface{}
+	err      error
+	timedOut bool
+	duration time.Duration
 }
 
 func NewManager() *Manager {
-    return &Manager{
-        capturers: make([]types.Capturer, 0),
-    }
+	return &Manager{
+		capturers: make([]types.Capturer, 0),
+		disabled:  make(map[string]bool),
+		timeouts:  make(map[string]time.Duration),
+	}
 }
 
 func (m *Manager) Register(c types.Capturer) {
-    m.capturers = append(m.capturers, c)
+	m.capturers = append(m.capturers, c)
+}
+
+// captureall runs all enabled capturers concurrently
+// a plugin starts once its dependencies have finished
+// every enabled plugin gets a report, failed ones are left out of the data
+func (m *Manager) CaptureAll(ctx context.Context) (map[string]interface{}, []types.PluginReport, error) {
+	return m.captureWhere(ctx, func(name string) bool { return !m.disabled[name] })
+}
+
+// capturefor captures the named plugins and the plugins they depend on,
+// whether or not they are disabled
+func (m *Manager) CaptureFor(ctx context.Context, names []string) (map[string]interface{}, []types.PluginReport, error) {
+	included := make(map[string]bool)
+	for len(names) > 0 {
+		name := names[0]
+		names = names[1:]
+		if included[name] {
+			continue
+		}
+		included[name] = true
+
+		if capturer, ok := m.Get(name); ok {
+			names = append(names, dependencies(capturer)...)
+		}
+	}
+
+	return m.captureWhere(ctx, func(name string) bool { return included[name] })
+}
+
+// capturewhere runs the included capturers like captureall
+func (m *Manager) captureWhere(ctx context.Context, include func(name string) bool) (map[string]interface{}, []types.PluginReport, error) {
+	ordered, broken := m.order()
+
+	results := make([]captureResult, len(ordered))
+	finished := make(map[string]chan struct{})
+	position := make(map[string]int)
+	for i, capturer := range ordered {
+		position[capturer.Name()] = i
+		finished[capturer.Name()] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+
+	for i, capturer := range ordered {
+		name := capturer.Name()
+		if !include(name) {
+			close(finished[name])
+			continue
+		}
+		if err, ok := broken[name]; ok {
+			results[i] = captureResult{err: err}
+			close(finished[name])
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, capturer types.Capturer) {
+			defer wg.Done()
+			defer close(finished[capturer.Name()])
+
+			// wait for dependencies and hand their output on
+			depData := make(map[string]map[string]interface{})
+			for _, dep := range dependencies(capturer) {
+				select {
+				case <-finished[dep]:
+				case <-ctx.Done():
+					results[i] = captureResult{err: ctx.Err()}
+					return
+				}
+				if data := results[position[dep]].data; len(data) > 0 {
+					depData[dep] = data
+				}
+			}
+
+			results[i] = m.capture(types.WithDependencyData(ctx, depData), capturer)
+		}(i, capturer)
+	}
+	wg.Wait()
+
+	// the whole freeze was cancelled, not just one plugin
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	pluginData := make(map[string]interface{})
+	var reports []types.PluginReport
+	var errors []error
+
+	for i, capturer := range ordered {
+		if !include(capturer.Name()) {
+			continue
+		}
+
+		result := results[i]
+		report := types.PluginReport{
+			Name:     capturer.Name(),
+			Status:   types.PluginOK,
+			Duration: result.duration,
+		}
+
+		switch {
+		case result.err != nil:
+			report.Status = types.PluginFailed
+			if result.timedOut {
+				report.Status = types.PluginTimeout
+			}
+			report.Error = result.err.Error()
+			errors = append(errors, fmt.Errorf("%s: %w", capturer.Name(), result.err))
+
+		case len(result.data) == 0:
+			report.Status = types.PluginEmpty
+
+		default:
+			encoded, err := json.Marshal(result.data)
+			if err != nil {
+				report.Status = types.PluginFailed
+				report.Error = fmt.Sprintf("data is not json: %v", err)
+				errors = append(errors, fmt.Errorf("%s: %w", capturer.Name(), err))
+				break
+			}
+			report.DataSize = len(encoded)
+			pluginData[capturer.Name()] = result.data
+		}
+
+		reports = append(reports, report)
+	}
+
+	if len(errors) > 0 && len(pluginData) == 0 {
+		return nil, reports, fmt.Errorf("all capture plugins failed: %v", errors)
+	}
+
+	return pluginData, reports, nil
+}
+
+// capture runs one capturer under its timeout
+// a capturer that ignores its context is abandoned when the timeout expires
+func (m *Manager) capture(ctx context.Context, capturer types.Capturer) captureResult {
+	timeout := m.Timeout(capturer.Name())
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan captureResult, 1)
+	go func() {
+		data, err := capturer.Capture(ctx)
+		done <- captureResult{data: data, err: err}
+	}()
+
+	var result captureResult
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		result = captureResult{err: ctx.Err()}
+	}
+
+	if result.err != nil && ctx.Err() == context.DeadlineExceeded {
+		result = captureResult{
+			err:      fmt.Errorf("timed out after %s", timeout),
+			timedOut: true,
+		}
+	}
+
+	result.duration = time.Since(start)
+	return result
+}
+
+// restoreall restores the selected plugins one by one, dependencies first
+// restores are not timed out since they may prompt the user
+// every plugin with saved data gets a report, the error is set if the
+// restore was cancelled
+func (m *Manager) RestoreAll(ctx context.Context, pluginData map[string]interface{}, sel Selection) ([]types.RestoreReport, error) {
+	var reports []types.RestoreReport
+
+	ordered, broken := m.order()
+	for _, capturer := range ordered {
+		if err := ctx.Err(); err != nil {
+			return reports, err
+		}
+
+		data, exists := pluginData[capturer.Name()]
+		if !exists {
+			continue
+		}
+
+		report := types.RestoreReport{Name: capturer.Name(), Status: types.RestoreSkipped}
+		if report.Reason = m.skipReason(capturer, data, broken, sel); report.Reason != "" {
+			reports = append(reports, report)
+			continue
+		}
+
+		dataMap := data.(map[string]interface{})
+		depCtx := types.WithDependencyData(ctx, dependencyData(capturer, pluginData))
+
+		// ask before changing anything
+		if m.confirm != nil {
+			preview := types.PluginPreview{Steps: previewSteps(depCtx, capturer, dataMap)}
+			if preview.Changes() && !m.confirm(capturer.Name(), preview.Steps) {
+				report.Reason = types.SkipDeclined
+				reports = append(reports, report)
+				continue
+			}
+		}
+
+		// warnings are reported, but the plugin still counts as restored
+		report.Status = types.RestoreApplied
+		if err := capturer.Restore(depCtx, dataMap); err != nil {
+			report.Err = err
+			if !types.IsWarning(err) {
+				report.Status = types.RestoreFailed
+			}
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
 }
 
-func (m *Manager) CaptureAll() (map[string]interface{}, error) {
-    sort.Slice(m.capturers, func(i, j int) bool {
-        return m.capturers[i].Priority() < m.capturers[j].Priority()
-    })
+// restorable returns the plugins restoreall would apply, before anyone is
+// asked, in run order
+func (m *Manager) Restorable(pluginData map[string]interface{}, sel Selection) []string {
+	var names []string
 
-    pluginData := make(map[string]interface{})
-    var errors []error
+	ordered, broken := m.order()
+	for _, capturer := range ordered {
+		data, exists := pluginData[capturer.Name()]
+		if exists && m.skipReason(capturer, data, broken, sel) == "" {
+			names = append(names, capturer.Name())
+		}
+	}
+	return names
+}
+
+// canrollback reports whether a plugin can undo its restore
+func (m *Manager) CanRollback(name string) bool {
+	capturer, ok := m.Get(name)
+	if !ok {
+		return false
+	}
+	_, ok = asRollbacker(capturer)
+	return ok
+}
+
+// rollbackall undoes the restore of applied plugins in reverse run order
+// before is the plugin data captured just before the restore
+// it returns the plugins that rolled back, the others are left as they are
+func (m *Manager) RollbackAll(ctx context.Context, before, applied map[string]interface{}) ([]string, []error) {
+	var rolledBack []string
+	var errors []error
+
+	ordered, _ := m.order()
+	for i := len(ordered) - 1; i >= 0; i-- {
+		capturer := ordered[i]
+
+		appliedData, ok := applied[capturer.Name()].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		rollbacker, ok := asRollbacker(capturer)
+		if !ok {
+			continue
+		}
 
-    for _, capturer := range m.capturers {
-        data, err := capturer.Capture()
-        if err != nil {
-            errors = append(errors, fmt.Errorf("%s: %w", capturer.Name(), err))
-            continue
-        }
+		beforeData, _ := before[capturer.Name()].(map[string]interface{})
+		if err := rollbacker.Rollback(ctx, beforeData, appliedData); err != nil {
+			errors = append(errors, fmt.Errorf("%s: %w", capturer.Name(), err))
+			continue
+		}
+		rolledBack = append(rolledBack, capturer.Name())
+	}
 
-        if data != nil && len(data) > 0 {
-            pluginData[capturer.Name()] = data
-        }
-    }
+	return rolledBack, errors
+}
 
-    if len(errors) > 0 && len(pluginData) == 0 {
-        return nil, fmt.Errorf("all capture plugins failed: %v", errors)
-    }
+// asrollbacker returns the rollback capability of a capturer
+// typed adapters always have the method, so ask them about the inner capturer
+func asRollbacker(c types.Capturer) (types.Rollbacker, bool) {
+	if t, ok := c.(interface{ canRollback() bool }); ok && !t.canRollback() {
+		return nil, false
+	}
+	r, ok := c.(types.Rollbacker)
+	return r, ok
+}
 
-    return pluginData, nil
+// settimeout overrides the capture timeout of a plugin
+func (m *Manager) SetTimeout(name string, timeout time.Duration) {
+	m.timeouts[name] = timeout
 }
 
-func (m *Manager) RestoreAll(pluginData map[string]interface{}) []error {
-    var errors []error
+// timeout returns the capture timeout of a plugin
+func (m *Manager) Timeout(name string) time.Duration {
+	if timeout, ok := m.timeouts[name]; ok && timeout > 0 {
+		return timeout
+	}
+	return DefaultTimeout
+}
 
-    for _, capturer := range m.capturers {
-        data, exists := pluginData[capturer.Name()]
-        if !exists {
-            continue
-        }
+// disable turns off plugins so they are skipped on capture and restore
+func (m *Manager) Disable(names ...string) {
+	for _, name := range names {
+		m.disabled[name] = true
+	}
+}
 
-        dataMap, ok := data.(map[string]interface{})
-        if !ok {
-            errors = append(errors, fmt.Errorf("%s: invalid data format", capturer.Name()))
-            continue
-        }
+// enable turns plugins back on
+func (m *Manager) Enable(names ...string) {
+	for _, name := range names {
+		delete(m.disabled, name)
+	}
+}
 
-        if !capturer.CanRestore(dataMap) {
-            continue
-        }
+// isenabled reports whether a plugin will run
+func (m *Manager) IsEnabled(name string) bool {
+	return m.has(name) && !m.disabled[name]
+}
 
-        if err := capturer.Restore(dataMap); err != nil {
-            errors = append(errors, fmt.Errorf("%s: %w", capturer.Name(), err))
-        }
-    }
+// get returns a registered capturer by name
+func (m *Manager) Get(name string) (types.Capturer, bool) {
+	for _, c := range m.capturers {
+		if c.Name() == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
 
-    return errors
+// has checks if a capturer name is registered
+func (m *Manager) has(name string) bool {
+	_, ok := m.Get(name)
+	return ok
 }
 
+// listcapturers returns plugin names in the order they run
 func (m *Manager) ListCapturers() []string {
-    names := make([]string, len(m.capturers))
-    for i, c := range m.capturers {
-        names[i] = c.Name()
-    }
-    return names
+	ordered, _ := m.order()
+	names := make([]string, len(ordered))
+	for i, c := range ordered {
+		names[i] = c.Name()
+	}
+	return names
 }
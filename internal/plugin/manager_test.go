@@ -1,8 +1,12 @@
 package plugin
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
+
+	"github.com/ansoncodes/workshot/pkg/types"
 )
 
 // Mock capturer for testing - implements the Capturer interface
@@ -23,11 +27,11 @@ func (m *mockCapturer) Priority() int {
 	return m.priority
 }
 
-func (m *mockCapturer) Capture() (map[string]interface{}, error) {
+func (m *mockCapturer) Capture(ctx context.Context) (map[string]interface{}, error) {
 	return m.captureData, m.captureError
 }
 
-func (m *mockCapturer) Restore(data map[string]interface{}) error {
+func (m *mockCapturer) Restore(ctx context.Context, data map[string]interface{}) error {
 	return m.restoreError
 }
 
@@ -37,13 +41,13 @@ func (m *mockCapturer) CanRestore(data map[string]interface{}) bool {
 
 func TestManagerRegister(t *testing.T) {
 	manager := NewManager()
-	
+
 	mock1 := &mockCapturer{name: "mock1", priority: 10}
 	mock2 := &mockCapturer{name: "mock2", priority: 20}
-	
+
 	manager.Register(mock1)
 	manager.Register(mock2)
-	
+
 	names := manager.ListCapturers()
 	if len(names) != 2 {
 		t.Errorf("Expected 2 capturers, got %d", len(names))
@@ -52,7 +56,7 @@ func TestManagerRegister(t *testing.T) {
 
 func TestManagerCaptureAll(t *testing.T) {
 	manager := NewManager()
-	
+
 	mock1 := &mockCapturer{
 		name:     "mock1",
 		priority: 10,
@@ -60,7 +64,7 @@ func TestManagerCaptureAll(t *testing.T) {
 			"key1": "value1",
 		},
 	}
-	
+
 	mock2 := &mockCapturer{
 		name:     "mock2",
 		priority: 20,
@@ -68,23 +72,23 @@ func TestManagerCaptureAll(t *testing.T) {
 			"key2": "value2",
 		},
 	}
-	
+
 	manager.Register(mock1)
 	manager.Register(mock2)
-	
-	data, err := manager.CaptureAll()
+
+	data, _, err := manager.CaptureAll(t.Context())
 	if err != nil {
 		t.Fatalf("CaptureAll failed: %v", err)
 	}
-	
+
 	if len(data) != 2 {
 		t.Errorf("Expected 2 plugin data entries, got %d", len(data))
 	}
-	
+
 	if _, ok := data["mock1"]; !ok {
 		t.Error("Missing mock1 data")
 	}
-	
+
 	if _, ok := data["mock2"]; !ok {
 		t.Error("Missing mock2 data")
 	}
@@ -92,13 +96,13 @@ func TestManagerCaptureAll(t *testing.T) {
 
 func TestManagerCaptureAllWithError(t *testing.T) {
 	manager := NewManager()
-	
+
 	mock1 := &mockCapturer{
 		name:         "mock1",
 		priority:     10,
 		captureError: fmt.Errorf("capture failed"),
 	}
-	
+
 	mock2 := &mockCapturer{
 		name:     "mock2",
 		priority: 20,
@@ -106,47 +110,280 @@ func TestManagerCaptureAllWithError(t *testing.T) {
 			"key2": "value2",
 		},
 	}
-	
+
 	manager.Register(mock1)
 	manager.Register(mock2)
-	
-	data, err := manager.CaptureAll()
-	
+
+	data, reports, err := manager.CaptureAll(t.Context())
+
 	if len(data) != 1 {
 		t.Errorf("Expected 1 successful capture, got %d", len(data))
 	}
-	
+
 	if err != nil {
 		t.Errorf("Expected nil error for partial success, got: %v", err)
 	}
+
+	// the failure is kept in the report instead of being dropped
+	if len(reports) != 2 || !reports[0].Failed() || reports[0].Error != "capture failed" {
+		t.Errorf("Expected mock1 failure in report, got %+v", reports)
+	}
 }
 
 func TestManagerRestoreAll(t *testing.T) {
 	manager := NewManager()
-	
+
 	mock1 := &mockCapturer{
 		name:       "mock1",
 		priority:   10,
 		canRestore: true,
 	}
-	
+
 	mock2 := &mockCapturer{
 		name:       "mock2",
 		priority:   20,
 		canRestore: false,
 	}
-	
+
 	manager.Register(mock1)
 	manager.Register(mock2)
-	
+
 	pluginData := map[string]interface{}{
 		"mock1": map[string]interface{}{"key": "value"},
 		"mock2": map[string]interface{}{"key": "value"},
 	}
-	
-	errors := manager.RestoreAll(pluginData)
-	
+
+	reports, err := manager.RestoreAll(t.Context(), pluginData, Selection{})
+
+	if err != nil {
+		t.Fatalf("RestoreAll failed: %v", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("Expected 2 reports, got %+v", reports)
+	}
+	if reports[0].Name != "mock1" || reports[0].Status != types.RestoreApplied || reports[0].Err != nil {
+		t.Errorf("Expected mock1 to be applied, got %+v", reports[0])
+	}
+	if reports[1].Status != types.RestoreSkipped || reports[1].Reason != types.SkipCannotRestore {
+		t.Errorf("Expected mock2 to be skipped as not restorable, got %+v", reports[1])
+	}
+}
+
+func TestManagerDisabledPlugins(t *testing.T) {
+	manager := NewManager()
+
+	restored := false
+	mock1 := &mockCapturer{
+		name:        "mock1",
+		priority:    10,
+		captureData: map[string]interface{}{"key1": "value1"},
+		canRestore:  true,
+	}
+	mock2 := &restoreTracker{
+		mockCapturer: mockCapturer{
+			name:        "mock2",
+			priority:    20,
+			captureData: map[string]interface{}{"key2": "value2"},
+			canRestore:  true,
+		},
+		restored: &restored,
+	}
+
+	manager.Register(mock1)
+	manager.Register(mock2)
+	manager.Disable("mock2")
+
+	if manager.IsEnabled("mock2") || !manager.IsEnabled("mock1") {
+		t.Error("Expected only mock2 to be disabled")
+	}
+
+	data, _, err := manager.CaptureAll(t.Context())
+	if err != nil {
+		t.Fatalf("CaptureAll failed: %v", err)
+	}
+	if _, ok := data["mock2"]; ok || len(data) != 1 {
+		t.Errorf("Expected only mock1 data, got %v", data)
+	}
+
+	manager.RestoreAll(t.Context(), map[string]interface{}{
+		"mock2": map[string]interface{}{"key2": "value2"},
+	}, Selection{})
+	if restored {
+		t.Error("Disabled plugin should not be restored")
+	}
+
+	manager.Enable("mock2")
+	manager.RestoreAll(t.Context(), map[string]interface{}{
+		"mock2": map[string]interface{}{"key2": "value2"},
+	}, Selection{})
+	if !restored {
+		t.Error("Re-enabled plugin should be restored")
+	}
+}
+
+func TestManagerRollbackAll(t *testing.T) {
+	notes := &notesCapturer{}
+	manager := NewManager()
+	manager.Register(&mockCapturer{name: "git", priority: 1})
+	manager.Register(Typed[notesData](notes))
+	manager.Register(Typed[notesData](&plainCapturer{}))
+
+	before := map[string]interface{}{
+		"notes": map[string]interface{}{"todos": []interface{}{"old"}},
+	}
+	applied := map[string]interface{}{
+		"notes": map[string]interface{}{"todos": []interface{}{"new"}},
+		"plain": map[string]interface{}{"todos": []interface{}{"new"}},
+	}
+
+	// plain cannot roll back and is left out
+	rolledBack, errors := manager.RollbackAll(t.Context(), before, applied)
 	if len(errors) != 0 {
-		t.Errorf("Expected no errors, got %d: %v", len(errors), errors)
+		t.Fatalf("Unexpected errors: %v", errors)
+	}
+	if len(rolledBack) != 1 || rolledBack[0] != "notes" {
+		t.Errorf("Expected only notes to roll back, got %v", rolledBack)
+	}
+	if notes.rolledBack == nil || notes.rolledBack.Todos[0] != "old" {
+		t.Errorf("Expected notes to get the data from before, got %+v", notes.rolledBack)
+	}
+
+	// a plugin with nothing captured before gets nil
+	manager.RollbackAll(t.Context(), nil, applied)
+	if notes.rolledBack != nil {
+		t.Errorf("Expected nil before data, got %+v", notes.rolledBack)
+	}
+}
+
+func TestManagerRestoreWarnings(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&mockCapturer{name: "toolchain", canRestore: true, restoreError: types.Warning(fmt.Errorf("go version mismatch"))})
+	manager.Register(&mockCapturer{name: "docker", canRestore: true, restoreError: fmt.Errorf("daemon not running")})
+
+	reports, err := manager.RestoreAll(t.Context(), map[string]interface{}{
+		"toolchain": map[string]interface{}{},
+		"docker":    map[string]interface{}{},
+	}, Selection{})
+	if err != nil || len(reports) != 2 {
+		t.Fatalf("Expected 2 reports, got %+v, %v", reports, err)
+	}
+
+	// warnings still count as restored
+	for _, report := range reports {
+		switch report.Name {
+		case "toolchain":
+			if report.Status != types.RestoreApplied || !types.IsWarning(report.Err) {
+				t.Errorf("Expected toolchain to be applied with a warning, got %+v", report)
+			}
+		case "docker":
+			if report.Status != types.RestoreFailed || report.Err == nil {
+				t.Errorf("Expected docker to fail, got %+v", report)
+			}
+		}
 	}
-}
\ No newline at end of file
+}
+
+// restoreTracker records whether Restore was called
+type restoreTracker struct {
+	mockCapturer
+	restored *bool
+}
+
+func (r *restoreTracker) Restore(ctx context.Context, data map[string]interface{}) error {
+	*r.restored = true
+	return nil
+}
+
+// waitingCapturer signals ready and waits for another capturer or its context
+type waitingCapturer struct {
+	mockCapturer
+	ready chan struct{}
+	other chan struct{}
+}
+
+func (w *waitingCapturer) Capture(ctx context.Context) (map[string]interface{}, error) {
+	close(w.ready)
+	select {
+	case <-w.other:
+		return w.captureData, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestManagerCaptureConcurrent(t *testing.T) {
+	first := make(chan struct{})
+	second := make(chan struct{})
+
+	manager := NewManager()
+	manager.Register(&waitingCapturer{
+		mockCapturer: mockCapturer{name: "mock1", priority: 10, captureData: map[string]interface{}{"key": 1}},
+		ready:        first,
+		other:        second,
+	})
+	manager.Register(&waitingCapturer{
+		mockCapturer: mockCapturer{name: "mock2", priority: 20, captureData: map[string]interface{}{"key": 2}},
+		ready:        second,
+		other:        first,
+	})
+	manager.SetTimeout("mock1", time.Second)
+	manager.SetTimeout("mock2", time.Second)
+
+	// each capturer only finishes once the other one has started
+	data, reports, err := manager.CaptureAll(t.Context())
+	if err != nil {
+		t.Fatalf("CaptureAll failed: %v", err)
+	}
+	if len(data) != 2 {
+		t.Errorf("Expected both plugins to succeed, got %v with reports %v", data, reports)
+	}
+}
+
+func TestManagerCaptureTimeout(t *testing.T) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	manager := NewManager()
+	manager.Register(&mockCapturer{name: "fast", priority: 10, captureData: map[string]interface{}{"key": "value"}})
+	manager.Register(&waitingCapturer{
+		mockCapturer: mockCapturer{name: "slow", priority: 20},
+		ready:        make(chan struct{}),
+		other:        release,
+	})
+	manager.Register(&mockCapturer{name: "broken", priority: 30, captureError: fmt.Errorf("boom")})
+	manager.SetTimeout("slow", 50*time.Millisecond)
+
+	data, reports, err := manager.CaptureAll(t.Context())
+	if err != nil {
+		t.Fatalf("CaptureAll failed: %v", err)
+	}
+
+	if _, ok := data["fast"]; !ok || len(data) != 1 {
+		t.Errorf("Expected only fast plugin data, got %v", data)
+	}
+
+	if len(reports) != 3 {
+		t.Fatalf("Expected 3 reports, got %v", reports)
+	}
+	if reports[0].Name != "fast" || reports[0].Status != types.PluginOK || reports[0].DataSize != len(`{"key":"value"}`) {
+		t.Errorf("Expected fast plugin to succeed, got %+v", reports[0])
+	}
+	if reports[1].Name != "slow" || reports[1].Status != types.PluginTimeout || reports[1].Duration < 50*time.Millisecond {
+		t.Errorf("Expected slow plugin to time out, got %+v", reports[1])
+	}
+	if reports[2].Name != "broken" || reports[2].Status != types.PluginFailed || reports[2].Error != "boom" {
+		t.Errorf("Expected broken plugin to fail, got %+v", reports[2])
+	}
+}
+
+func TestManagerCaptureCancelled(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&mockCapturer{name: "mock1", priority: 10, captureData: map[string]interface{}{"key": "value"}})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	if _, _, err := manager.CaptureAll(ctx); err != context.Canceled {
+		t.Errorf("Expected cancellation error, got %v", err)
+	}
+}
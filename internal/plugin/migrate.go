@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// dataversions returns the current data version of each captured plugin
+func (m *Manager) DataVersions(pluginData map[string]interface{}) map[string]int {
+	versions := make(map[string]int, len(pluginData))
+	for name := range pluginData {
+		if capturer, ok := m.Get(name); ok {
+			versions[name] = types.DataVersion(capturer)
+		}
+	}
+	return versions
+}
+
+// migrateplugindata upgrades payloads saved by older plugin versions
+// data of plugins that are not registered is left untouched
+func (m *Manager) MigratePluginData(snap *types.Snapshot) error {
+	for name, raw := range snap.PluginData {
+		capturer, ok := m.Get(name)
+		if !ok {
+			continue
+		}
+
+		saved := 1
+		if v, ok := snap.PluginVersions[name]; ok {
+			saved = v
+		}
+
+		current := types.DataVersion(capturer)
+		if saved == current {
+			continue
+		}
+		if saved > current {
+			return fmt.Errorf("%s data is version %d, but this workshot only supports up to %d (upgrade workshot)",
+				name, saved, current)
+		}
+
+		migrator, ok := capturer.(types.Migrator)
+		if !ok {
+			return fmt.Errorf("%s cannot migrate data from version %d to %d", name, saved, current)
+		}
+
+		data, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: invalid data format", name)
+		}
+
+		migrated, err := migrator.MigrateData(saved, data)
+		if err != nil {
+			return fmt.Errorf("%s: migration from version %d failed: %w", name, saved, err)
+		}
+
+		snap.PluginData[name] = migrated
+		if snap.PluginVersions == nil {
+			snap.PluginVersions = make(map[string]int)
+		}
+		snap.PluginVersions[name] = current
+	}
+
+	return nil
+}
+
+// mappaths replaces the paths in the data of each plugin with f(path)
+// data of plugins that are not registered or have no paths is left as is
+func (m *Manager) MapPaths(pluginData map[string]interface{}, f func(path string) string) {
+	for name, raw := range pluginData {
+		capturer, ok := m.Get(name)
+		if !ok {
+			continue
+		}
+		mapper, ok := capturer.(types.PathMapper)
+		if !ok {
+			continue
+		}
+		if data, ok := raw.(map[string]interface{}); ok {
+			pluginData[name] = mapper.MapPaths(data, f)
+		}
+	}
+}
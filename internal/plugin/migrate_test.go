@@ -0,0 +1,113 @@
+package plugin
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// versionedCapturer is at data version 3
+// version 1 stored "todo" as a string, version 2 renamed it to "todos",
+// version 3 turned "todos" into a list
+type versionedCapturer struct {
+	mockCapturer
+}
+
+func (v *versionedCapturer) DataVersion() int {
+	return 3
+}
+
+func (v *versionedCapturer) MigrateData(from int, data map[string]interface{}) (map[string]interface{}, error) {
+	if from < 2 {
+		todo, ok := data["todo"].(string)
+		if !ok {
+			return nil, fmt.Errorf("missing todo")
+		}
+		data = map[string]interface{}{"todos": todo}
+	}
+	if from < 3 {
+		data = map[string]interface{}{"todos": []interface{}{data["todos"]}}
+	}
+	return data, nil
+}
+
+// unmigratableCapturer bumped its data version without a migration
+type unmigratableCapturer struct {
+	mockCapturer
+}
+
+func (u *unmigratableCapturer) DataVersion() int {
+	return 2
+}
+
+func TestManagerMigratePluginData(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&versionedCapturer{mockCapturer{name: "notes", priority: 10}})
+	manager.Register(&mockCapturer{name: "git", priority: 20})
+
+	snap := types.NewSnapshot("old")
+	snap.PluginData = map[string]interface{}{
+		"notes":   map[string]interface{}{"todo": "ship it"},
+		"git":     map[string]interface{}{"branch": "main"},
+		"unknown": map[string]interface{}{"key": "value"},
+	}
+
+	if err := manager.MigratePluginData(snap); err != nil {
+		t.Fatalf("MigratePluginData failed: %v", err)
+	}
+
+	notes := snap.PluginData["notes"].(map[string]interface{})
+	todos, ok := notes["todos"].([]interface{})
+	if !ok || len(todos) != 1 || todos[0] != "ship it" {
+		t.Errorf("Expected migrated todos list, got %v", notes)
+	}
+	if snap.PluginVersions["notes"] != 3 {
+		t.Errorf("Expected notes at version 3, got %v", snap.PluginVersions)
+	}
+	if _, ok := snap.PluginVersions["git"]; ok {
+		t.Error("Unversioned plugin data should not be touched")
+	}
+
+	// migrating again is a no-op
+	if err := manager.MigratePluginData(snap); err != nil {
+		t.Errorf("Second migration failed: %v", err)
+	}
+
+	versions := manager.DataVersions(snap.PluginData)
+	if versions["notes"] != 3 || versions["git"] != 1 || len(versions) != 2 {
+		t.Errorf("Unexpected data versions: %v", versions)
+	}
+}
+
+func TestManagerMigratePluginDataErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		capturer types.Capturer
+		version  int
+		want     string
+	}{
+		{"newer data", &versionedCapturer{mockCapturer{name: "notes"}}, 4, "only supports up to 3"},
+		{"no migrator", &unmigratableCapturer{mockCapturer{name: "notes"}}, 0, "cannot migrate data from version 1 to 2"},
+		{"migration error", &versionedCapturer{mockCapturer{name: "notes"}}, 1, "migration from version 1 failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager()
+			manager.Register(tt.capturer)
+
+			snap := types.NewSnapshot("old")
+			snap.PluginData = map[string]interface{}{"notes": map[string]interface{}{"other": 1}}
+			if tt.version > 0 {
+				snap.PluginVersions = map[string]int{"notes": tt.version}
+			}
+
+			err := manager.MigratePluginData(snap)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package plugin
+
+import "github.com/ansoncodes/workshot/pkg/types"
+
+// plan collects the restore plans of enabled, selected plugins in run order
+func (m *Manager) Plan(pluginData map[string]interface{}, sel Selection) []types.Action {
+	var plan []types.Action
+
+	for _, name := range m.ListCapturers() {
+		if m.unselected(name, sel) != "" {
+			continue
+		}
+
+		data, ok := pluginData[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		capturer, _ := m.Get(name)
+		if planner, ok := capturer.(types.Planner); ok {
+			plan = append(plan, planner.RestorePlan(data)...)
+		}
+	}
+
+	return plan
+}
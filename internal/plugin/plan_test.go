@@ -0,0 +1,40 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// planningCapturer restores an environment variable from its data
+type planningCapturer struct {
+	mockCapturer
+}
+
+func (p *planningCapturer) RestorePlan(data map[string]interface{}) []types.Action {
+	value, _ := data["value"].(string)
+	return []types.Action{types.Export(p.name, value)}
+}
+
+func TestManagerPlan(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&planningCapturer{mockCapturer{name: "SECOND", priority: 20}})
+	manager.Register(&planningCapturer{mockCapturer{name: "FIRST", priority: 10}})
+	manager.Register(&planningCapturer{mockCapturer{name: "OFF", priority: 5}})
+	manager.Register(&mockCapturer{name: "plain", priority: 1})
+	manager.Disable("OFF")
+
+	plan := manager.Plan(map[string]interface{}{
+		"FIRST":  map[string]interface{}{"value": "1"},
+		"SECOND": map[string]interface{}{"value": "2"},
+		"OFF":    map[string]interface{}{"value": "0"},
+		"plain":  map[string]interface{}{"value": "x"},
+	}, Selection{})
+
+	if len(plan) != 2 {
+		t.Fatalf("Expected 2 actions, got %v", plan)
+	}
+	if plan[0].Name != "FIRST" || plan[1].Name != "SECOND" || plan[1].Value != "2" {
+		t.Errorf("Expected actions in run order, got %v", plan)
+	}
+}
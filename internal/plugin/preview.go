@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// setconfirm makes restoreall ask before restoring a plugin whose preview
+// changes something, plugins that are declined are left alone
+func (m *Manager) SetConfirm(confirm func(name string, steps []types.Step) bool) {
+	m.confirm = confirm
+}
+
+// preview describes what restoreall would do, in the order it would do it
+// plugins without saved data are left out
+func (m *Manager) Preview(ctx context.Context, pluginData map[string]interface{}, sel Selection) []types.PluginPreview {
+	var previews []types.PluginPreview
+
+	ordered, broken := m.order()
+	for _, capturer := range ordered {
+		data, exists := pluginData[capturer.Name()]
+		if !exists {
+			continue
+		}
+
+		preview := types.PluginPreview{Name: capturer.Name()}
+		if preview.Skipped = m.skipReason(capturer, data, broken, sel); preview.Skipped == "" {
+			depCtx := types.WithDependencyData(ctx, dependencyData(capturer, pluginData))
+			preview.Steps = previewSteps(depCtx, capturer, data.(map[string]interface{}))
+		}
+
+		previews = append(previews, preview)
+	}
+
+	return previews
+}
+
+// previewsteps asks a capturer what its restore would do
+func previewSteps(ctx context.Context, capturer types.Capturer, data map[string]interface{}) []types.Step {
+	if p, ok := capturer.(types.Previewer); ok {
+		return p.Preview(ctx, data)
+	}
+	return genericPreview(capturer.Name())
+}
+
+// genericpreview is the single step shown for plugins that cannot preview
+func genericPreview(name string) []types.Step {
+	return []types.Step{{Description: "restore saved " + name + " data"}}
+}
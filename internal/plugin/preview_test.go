@@ -0,0 +1,89 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// branchCapturer previews a checkout from its current branch to the saved one
+type branchCapturer struct {
+	restoreTracker
+	current string
+}
+
+func (b *branchCapturer) Preview(ctx context.Context, data map[string]interface{}) []types.Step {
+	branch, _ := data["branch"].(string)
+	return []types.Step{{Description: "check out branch", Current: b.current, Target: branch}}
+}
+
+func TestManagerPreview(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&branchCapturer{restoreTracker: restoreTracker{mockCapturer: mockCapturer{name: "git", priority: 10, canRestore: true}}, current: "main"})
+	manager.Register(&mockCapturer{name: "editor", priority: 20, canRestore: true})
+	manager.Register(&mockCapturer{name: "docker", priority: 30})
+	manager.Register(&mockCapturer{name: "off", priority: 40, canRestore: true})
+	manager.Disable("off")
+
+	previews := manager.Preview(t.Context(), map[string]interface{}{
+		"git":    map[string]interface{}{"branch": "feature/x"},
+		"editor": map[string]interface{}{"files": 2},
+		"docker": map[string]interface{}{"project": "app"},
+		"off":    map[string]interface{}{},
+	}, Selection{})
+
+	if len(previews) != 4 {
+		t.Fatalf("Expected 4 previews, got %+v", previews)
+	}
+
+	git := previews[0]
+	if git.Name != "git" || len(git.Steps) != 1 || git.Steps[0].Current != "main" || git.Steps[0].Target != "feature/x" || !git.Changes() {
+		t.Errorf("Unexpected git preview: %+v", git)
+	}
+
+	// capturers without a previewer get one generic step
+	editor := previews[1]
+	if len(editor.Steps) != 1 || editor.Steps[0].Description != "restore saved editor data" || !editor.Changes() {
+		t.Errorf("Unexpected editor preview: %+v", editor)
+	}
+
+	if previews[2].Skipped != "cannot restore" || previews[3].Skipped != "disabled" {
+		t.Errorf("Expected docker and off to be skipped, got %+v", previews[2:])
+	}
+}
+
+func TestManagerRestoreConfirm(t *testing.T) {
+	var gitRestored, sameRestored bool
+	manager := NewManager()
+	manager.Register(&branchCapturer{restoreTracker: restoreTracker{mockCapturer: mockCapturer{name: "git", priority: 10, canRestore: true}, restored: &gitRestored}, current: "main"})
+	manager.Register(&branchCapturer{restoreTracker: restoreTracker{mockCapturer: mockCapturer{name: "same", priority: 20, canRestore: true}, restored: &sameRestored}, current: "main"})
+
+	var asked []string
+	manager.SetConfirm(func(name string, steps []types.Step) bool {
+		asked = append(asked, name)
+		return false
+	})
+
+	reports, err := manager.RestoreAll(t.Context(), map[string]interface{}{
+		"git":  map[string]interface{}{"branch": "feature/x"},
+		"same": map[string]interface{}{"branch": "main"},
+	}, Selection{})
+	if err != nil {
+		t.Fatalf("RestoreAll failed: %v", err)
+	}
+	if len(reports) != 2 || reports[0].Reason != types.SkipDeclined || reports[1].Status != types.RestoreApplied {
+		t.Errorf("Unexpected reports: %+v", reports)
+	}
+
+	// only changes are confirmed, a declined plugin is left alone
+	if len(asked) != 1 || asked[0] != "git" {
+		t.Errorf("Expected to be asked about git only, got %v", asked)
+	}
+	if gitRestored {
+		t.Error("Declined plugin should not be restored")
+	}
+	if !sameRestored {
+		t.Error("Plugin without changes should be restored without asking")
+	}
+}
@@ -0,0 +1,100 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// sections renders saved plugin data in run order
+// data of plugins that are no longer registered is listed generically at the end
+func (m *Manager) Sections(pluginData map[string]interface{}) []types.Section {
+	var sections []types.Section
+	seen := make(map[string]bool)
+
+	add := func(name string, section *types.Section) {
+		if section == nil {
+			return
+		}
+		section.Plugin = name
+		sections = append(sections, *section)
+	}
+
+	for _, name := range m.ListCapturers() {
+		seen[name] = true
+		data, ok := pluginData[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		capturer, _ := m.Get(name)
+		add(name, Render(capturer, data))
+	}
+
+	var unknown []string
+	for name := range pluginData {
+		if !seen[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+
+	for _, name := range unknown {
+		if data, ok := pluginData[name].(map[string]interface{}); ok {
+			add(name, genericSection(name, data))
+		}
+	}
+
+	return sections
+}
+
+// render returns the section a capturer contributes for its data
+func Render(c types.Capturer, data map[string]interface{}) *types.Section {
+	if r, ok := c.(types.Renderer); ok {
+		return r.Render(data)
+	}
+	return genericSection(c.Name(), data)
+}
+
+// list top-level values of plugin data without knowing its format
+func genericSection(name string, data map[string]interface{}) *types.Section {
+	if len(data) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	section := &types.Section{Title: name}
+	for _, key := range keys {
+		section.Fields = append(section.Fields, types.Field{Label: key, Value: genericValue(data[key])})
+	}
+	return section
+}
+
+// format a json value for display, summarizing nested data
+func genericValue(value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				return fmt.Sprintf("%d entries", len(v))
+			}
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, ", ")
+	case map[string]interface{}:
+		return fmt.Sprintf("%d entries", len(v))
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,93 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// renderingCapturer renders its data as one line
+type renderingCapturer struct {
+	mockCapturer
+}
+
+func (r *renderingCapturer) Render(data map[string]interface{}) *types.Section {
+	if data["skip"] == true {
+		return nil
+	}
+	return &types.Section{Title: "Rendered", Lines: []string{"custom"}}
+}
+
+func TestManagerSections(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&mockCapturer{name: "plain", priority: 20})
+	manager.Register(&renderingCapturer{mockCapturer{name: "custom", priority: 10}})
+	manager.Register(&renderingCapturer{mockCapturer{name: "hidden", priority: 30}})
+
+	pluginData := map[string]interface{}{
+		"plain": map[string]interface{}{
+			"branch": "main",
+			"count":  float64(3),
+			"tags":   []interface{}{"a", "b"},
+			"nested": []interface{}{map[string]interface{}{"x": 1}},
+		},
+		"custom":  map[string]interface{}{"key": "value"},
+		"hidden":  map[string]interface{}{"skip": true},
+		"removed": map[string]interface{}{"left": "behind"},
+	}
+
+	sections := manager.Sections(pluginData)
+
+	var names []string
+	for _, s := range sections {
+		names = append(names, s.Plugin)
+	}
+	if len(names) != 3 || names[0] != "custom" || names[1] != "plain" || names[2] != "removed" {
+		t.Fatalf("Expected sections custom, plain, removed, got %v", names)
+	}
+
+	if sections[0].Title != "Rendered" || len(sections[0].Lines) != 1 {
+		t.Errorf("Expected the plugin's own section, got %+v", sections[0])
+	}
+
+	// plugins without a renderer list their top-level fields
+	want := []types.Field{
+		{Label: "branch", Value: "main"},
+		{Label: "count", Value: "3"},
+		{Label: "nested", Value: "1 entries"},
+		{Label: "tags", Value: "a, b"},
+	}
+	plain := sections[1]
+	if plain.Title != "plain" || len(plain.Fields) != len(want) {
+		t.Fatalf("Unexpected generic section: %+v", plain)
+	}
+	for i, f := range want {
+		if plain.Fields[i] != f {
+			t.Errorf("Field %d = %+v, want %+v", i, plain.Fields[i], f)
+		}
+	}
+
+	if sections[2].Title != "removed" || sections[2].Fields[0].Value != "behind" {
+		t.Errorf("Expected unregistered plugin data to be listed, got %+v", sections[2])
+	}
+}
+
+func (n *notesCapturer) RenderData(data *notesData) *types.Section {
+	return &types.Section{Title: "Notes", Lines: data.Todos}
+}
+
+func TestTypedRender(t *testing.T) {
+	notes := Typed[notesData](&notesCapturer{})
+	data := map[string]interface{}{"todos": []interface{}{"ship it"}}
+
+	section := Render(notes, data)
+	if section == nil || section.Title != "Notes" || section.Lines[0] != "ship it" {
+		t.Errorf("Expected typed section, got %+v", section)
+	}
+
+	// without a typed renderer the data is listed generically
+	section = Render(Typed[notesData](&plainCapturer{}), data)
+	if section == nil || section.Title != "plain" || section.Fields[0].Value != "ship it" {
+		t.Errorf("Expected generic section, got %+v", section)
+	}
+}
@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"slices"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// selection picks the plugins a restore applies to
+// the zero selection includes every plugin
+type Selection struct {
+	Only   []string // only these plugins, all when empty
+	Except []string // never these plugins
+}
+
+// includes reports whether a plugin is selected
+func (s Selection) Includes(name string) bool {
+	if slices.Contains(s.Except, name) {
+		return false
+	}
+	return len(s.Only) == 0 || slices.Contains(s.Only, name)
+}
+
+// unselected says why a plugin is left out of a restore, if it is
+// plugins named in only override config
+func (m *Manager) unselected(name string, sel Selection) string {
+	if !sel.Includes(name) {
+		return types.SkipExcluded
+	}
+	if m.disabled[name] && !slices.Contains(sel.Only, name) {
+		return types.SkipDisabled
+	}
+	return ""
+}
+
+// skipreason says why a plugin will not restore its saved data
+// an empty reason means it will
+func (m *Manager) skipReason(capturer types.Capturer, data interface{}, broken map[string]error, sel Selection) string {
+	name := capturer.Name()
+
+	if reason := m.unselected(name, sel); reason != "" {
+		return reason
+	}
+	if err, ok := broken[name]; ok {
+		return err.Error()
+	}
+
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		return types.SkipInvalidData
+	}
+
+	if !capturer.CanRestore(dataMap) {
+		// shell-level plugins are restored by restore -c instead
+		if planner, ok := capturer.(types.Planner); ok && len(planner.RestorePlan(dataMap)) > 0 {
+			return types.SkipShell
+		}
+		return types.SkipCannotRestore
+	}
+
+	return ""
+}
@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+func TestSelectionIncludes(t *testing.T) {
+	tests := []struct {
+		sel  Selection
+		name string
+		want bool
+	}{
+		{Selection{}, "git", true},
+		{Selection{Only: []string{"git", "cloud"}}, "git", true},
+		{Selection{Only: []string{"git", "cloud"}}, "terminal", false},
+		{Selection{Except: []string{"terminal"}}, "terminal", false},
+		{Selection{Except: []string{"terminal"}}, "git", true},
+		{Selection{Only: []string{"git"}, Except: []string{"git"}}, "git", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.sel.Includes(tt.name); got != tt.want {
+			t.Errorf("%+v.Includes(%q) = %v, want %v", tt.sel, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestManagerRestoreSelection(t *testing.T) {
+	var gitRestored, editorRestored bool
+	manager := NewManager()
+	manager.Register(&restoreTracker{mockCapturer: mockCapturer{name: "git", priority: 10, canRestore: true}, restored: &gitRestored})
+	manager.Register(&planningCapturer{mockCapturer{name: "cloud", priority: 15}})
+	manager.Register(&restoreTracker{mockCapturer: mockCapturer{name: "editor", priority: 20, canRestore: true}, restored: &editorRestored})
+	manager.Register(&mockCapturer{name: "docker", priority: 30})
+	manager.Disable("editor")
+
+	pluginData := map[string]interface{}{
+		"git":    map[string]interface{}{},
+		"cloud":  map[string]interface{}{"value": "prod"},
+		"editor": map[string]interface{}{},
+		"docker": map[string]interface{}{},
+	}
+
+	reports, err := manager.RestoreAll(t.Context(), pluginData, Selection{Except: []string{"git"}})
+	if err != nil {
+		t.Fatalf("RestoreAll failed: %v", err)
+	}
+
+	reasons := make(map[string]string)
+	for _, report := range reports {
+		reasons[report.Name] = report.Reason
+	}
+	want := map[string]string{
+		"git":    types.SkipExcluded,
+		"cloud":  types.SkipShell,
+		"editor": types.SkipDisabled,
+		"docker": types.SkipCannotRestore,
+	}
+	for name, reason := range want {
+		if reasons[name] != reason {
+			t.Errorf("Expected %s to be skipped as %q, got %q", name, reason, reasons[name])
+		}
+	}
+	if gitRestored || editorRestored {
+		t.Error("Excluded and disabled plugins should not be restored")
+	}
+
+	// naming a disabled plugin in only overrides config
+	sel := Selection{Only: []string{"editor", "cloud"}}
+	if _, err := manager.RestoreAll(t.Context(), pluginData, sel); err != nil {
+		t.Fatalf("RestoreAll failed: %v", err)
+	}
+	if gitRestored || !editorRestored {
+		t.Errorf("Expected only editor to be restored, git %v, editor %v", gitRestored, editorRestored)
+	}
+
+	if plan := manager.Plan(pluginData, Selection{Only: []string{"git"}}); len(plan) != 0 {
+		t.Errorf("Expected no actions without cloud, got %v", plan)
+	}
+	if plan := manager.Plan(pluginData, sel); len(plan) != 1 || plan[0].Value != "prod" {
+		t.Errorf("Expected the cloud action, got %v", plan)
+	}
+
+	previews := manager.Preview(t.Context(), pluginData, Selection{Only: []string{"docker"}})
+	if len(previews) != 4 || previews[0].Skipped != types.SkipExcluded || previews[3].Skipped != types.SkipCannotRestore {
+		t.Errorf("Unexpected previews: %+v", previews)
+	}
+}
@@ -0,0 +1,194 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// typedcapturer adapts a typed capturer to the map based capturer interface
+// optional interfaces of the wrapped capturer are forwarded
+type typedCapturer[T any] struct {
+	inner types.TypedCapturer[T]
+}
+
+// typedmigrator is a typedcapturer whose capturer can migrate its data
+// it is separate so only capturers with a migration are migrators
+type typedMigrator[T any] struct {
+	*typedCapturer[T]
+	migrator types.Migrator
+}
+
+// typed wraps a typed capturer so it can be registered
+func Typed[T any](c types.TypedCapturer[T]) types.Capturer {
+	adapter := &typedCapturer[T]{inner: c}
+	if m, ok := c.(types.Migrator); ok {
+		return &typedMigrator[T]{typedCapturer: adapter, migrator: m}
+	}
+	return adapter
+}
+
+func (t *typedCapturer[T]) Name() string {
+	return t.inner.Name()
+}
+
+func (t *typedCapturer[T]) Priority() int {
+	return t.inner.Priority()
+}
+
+func (t *typedCapturer[T]) Capture(ctx context.Context) (map[string]interface{}, error) {
+	data, err := t.inner.CaptureData(ctx)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return Encode(data)
+}
+
+func (t *typedCapturer[T]) Restore(ctx context.Context, data map[string]interface{}) error {
+	typed, err := Decode[T](data)
+	if err != nil {
+		return fmt.Errorf("invalid %s data: %w", t.inner.Name(), err)
+	}
+	return t.inner.RestoreData(ctx, typed)
+}
+
+func (t *typedCapturer[T]) CanRestore(data map[string]interface{}) bool {
+	typed, err := Decode[T](data)
+	if err != nil {
+		return false
+	}
+	return t.inner.CanRestoreData(typed)
+}
+
+func (t *typedCapturer[T]) Render(data map[string]interface{}) *types.Section {
+	r, ok := t.inner.(types.TypedRenderer[T])
+	if !ok {
+		return genericSection(t.inner.Name(), data)
+	}
+
+	typed, err := Decode[T](data)
+	if err != nil {
+		return genericSection(t.inner.Name(), data)
+	}
+	return r.RenderData(typed)
+}
+
+func (t *typedCapturer[T]) RestorePlan(data map[string]interface{}) []types.Action {
+	p, ok := t.inner.(types.TypedPlanner[T])
+	if !ok {
+		return nil
+	}
+
+	typed, err := Decode[T](data)
+	if err != nil {
+		return nil
+	}
+	return p.RestorePlanData(typed)
+}
+
+func (t *typedCapturer[T]) Preview(ctx context.Context, data map[string]interface{}) []types.Step {
+	p, ok := t.inner.(types.TypedPreviewer[T])
+	if !ok {
+		return genericPreview(t.inner.Name())
+	}
+
+	typed, err := Decode[T](data)
+	if err != nil {
+		return genericPreview(t.inner.Name())
+	}
+	return p.PreviewData(ctx, typed)
+}
+
+// typed capturers without rollbackdata have nothing to roll back
+func (t *typedCapturer[T]) Rollback(ctx context.Context, before, applied map[string]interface{}) error {
+	r, ok := t.inner.(types.TypedRollbacker[T])
+	if !ok {
+		return nil
+	}
+
+	appliedData, err := Decode[T](applied)
+	if err != nil {
+		return fmt.Errorf("invalid %s data: %w", t.inner.Name(), err)
+	}
+
+	var beforeData *T
+	if before != nil {
+		if beforeData, err = Decode[T](before); err != nil {
+			return fmt.Errorf("invalid %s data: %w", t.inner.Name(), err)
+		}
+	}
+	return r.RollbackData(ctx, beforeData, appliedData)
+}
+
+func (t *typedCapturer[T]) canRollback() bool {
+	_, ok := t.inner.(types.TypedRollbacker[T])
+	return ok
+}
+
+// typed capturers without mappathsdata have no paths
+func (t *typedCapturer[T]) MapPaths(data map[string]interface{}, f func(path string) string) map[string]interface{} {
+	m, ok := t.inner.(types.TypedPathMapper[T])
+	if !ok {
+		return data
+	}
+
+	typed, err := Decode[T](data)
+	if err != nil {
+		return data
+	}
+	m.MapPathsData(typed, f)
+
+	mapped, err := Encode(typed)
+	if err != nil {
+		return data
+	}
+	return mapped
+}
+
+func (t *typedCapturer[T]) DependsOn() []string {
+	if d, ok := t.inner.(types.Dependent); ok {
+		return d.DependsOn()
+	}
+	return nil
+}
+
+func (t *typedCapturer[T]) DataVersion() int {
+	if v, ok := t.inner.(types.Versioned); ok {
+		return v.DataVersion()
+	}
+	return 1
+}
+
+func (t *typedMigrator[T]) MigrateData(from int, data map[string]interface{}) (map[string]interface{}, error) {
+	return t.migrator.MigrateData(from, data)
+}
+
+// encode converts a payload struct to snapshot plugin data
+func Encode(v interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// decode converts snapshot plugin data to a payload struct
+func Decode[T any](data interface{}) (*T, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	typed := new(T)
+	if err := json.Unmarshal(encoded, typed); err != nil {
+		return nil, err
+	}
+	return typed, nil
+}
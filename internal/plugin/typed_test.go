@@ -0,0 +1,214 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// notesData is the payload of the typed test capturer
+type notesData struct {
+	Todos []string `json:"todos"`
+	Count int      `json:"count,omitempty"`
+}
+
+// notesCapturer is a typed capturer at data version 2 that depends on git
+type notesCapturer struct {
+	captured   *notesData
+	restored   *notesData
+	rolledBack *notesData
+}
+
+func (n *notesCapturer) Name() string {
+	return "notes"
+}
+
+func (n *notesCapturer) Priority() int {
+	return 10
+}
+
+func (n *notesCapturer) CaptureData(ctx context.Context) (*notesData, error) {
+	return n.captured, nil
+}
+
+func (n *notesCapturer) RestoreData(ctx context.Context, data *notesData) error {
+	n.restored = data
+	return nil
+}
+
+func (n *notesCapturer) CanRestoreData(data *notesData) bool {
+	return len(data.Todos) > 0
+}
+
+func (n *notesCapturer) RollbackData(ctx context.Context, before, applied *notesData) error {
+	n.rolledBack = before
+	return nil
+}
+
+func (n *notesCapturer) DependsOn() []string {
+	return []string{"git"}
+}
+
+func (n *notesCapturer) DataVersion() int {
+	return 2
+}
+
+func (n *notesCapturer) MigrateData(from int, data map[string]interface{}) (map[string]interface{}, error) {
+	todo, ok := data["todo"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing todo")
+	}
+	return map[string]interface{}{"todos": []interface{}{todo}}, nil
+}
+
+// plainCapturer is a typed capturer without optional interfaces
+type plainCapturer struct{}
+
+func (p *plainCapturer) Name() string {
+	return "plain"
+}
+
+func (p *plainCapturer) Priority() int {
+	return 20
+}
+
+func (p *plainCapturer) CaptureData(ctx context.Context) (*notesData, error) {
+	return nil, nil
+}
+
+func (p *plainCapturer) RestoreData(ctx context.Context, data *notesData) error {
+	return nil
+}
+
+func (p *plainCapturer) CanRestoreData(data *notesData) bool {
+	return false
+}
+
+func TestTypedRoundTrip(t *testing.T) {
+	inner := &notesCapturer{captured: &notesData{Todos: []string{"ship it"}, Count: 3}}
+	capturer := Typed[notesData](inner)
+
+	data, err := capturer.Capture(t.Context())
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+
+	// the on-disk format is defined by the json tags
+	encoded, _ := json.Marshal(data)
+	if string(encoded) != `{"count":3,"todos":["ship it"]}` {
+		t.Errorf("Unexpected encoding: %s", encoded)
+	}
+
+	// data read back from disk holds float64 numbers and []interface{}
+	var loaded map[string]interface{}
+	if err := json.Unmarshal(encoded, &loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !capturer.CanRestore(loaded) {
+		t.Fatal("Expected loaded data to be restorable")
+	}
+	if err := capturer.Restore(t.Context(), loaded); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if inner.restored == nil || inner.restored.Count != 3 || inner.restored.Todos[0] != "ship it" {
+		t.Errorf("Unexpected restored data: %+v", inner.restored)
+	}
+}
+
+func TestTypedNothingCaptured(t *testing.T) {
+	data, err := Typed[notesData](&notesCapturer{}).Capture(t.Context())
+	if err != nil || data != nil {
+		t.Errorf("Expected no data, got %v, %v", data, err)
+	}
+}
+
+func TestTypedInvalidData(t *testing.T) {
+	capturer := Typed[notesData](&notesCapturer{})
+	data := map[string]interface{}{"todos": "not a list"}
+
+	if capturer.CanRestore(data) {
+		t.Error("Invalid data should not be restorable")
+	}
+
+	err := capturer.Restore(t.Context(), data)
+	if err == nil {
+		t.Fatal("Expected error for invalid data")
+	}
+	if !strings.HasPrefix(err.Error(), "invalid notes data") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestTypedForwardsOptionalInterfaces(t *testing.T) {
+	notes := Typed[notesData](&notesCapturer{})
+	plain := Typed[notesData](&plainCapturer{})
+
+	if deps := dependencies(notes); len(deps) != 1 || deps[0] != "git" {
+		t.Errorf("Expected notes to depend on git, got %v", deps)
+	}
+	if deps := dependencies(plain); len(deps) != 0 {
+		t.Errorf("Expected no dependencies, got %v", deps)
+	}
+
+	if v := types.DataVersion(notes); v != 2 {
+		t.Errorf("Expected data version 2, got %d", v)
+	}
+	if v := types.DataVersion(plain); v != 1 {
+		t.Errorf("Expected data version 1, got %d", v)
+	}
+
+	manager := NewManager()
+	manager.Register(notes)
+	manager.Register(plain)
+
+	snap := types.NewSnapshot("old")
+	snap.PluginData = map[string]interface{}{
+		"notes": map[string]interface{}{"todo": "ship it"},
+	}
+
+	if err := manager.MigratePluginData(snap); err != nil {
+		t.Fatalf("MigratePluginData failed: %v", err)
+	}
+
+	migrated, err := Decode[notesData](snap.PluginData["notes"])
+	if err != nil || len(migrated.Todos) != 1 || migrated.Todos[0] != "ship it" {
+		t.Errorf("Unexpected migrated data: %+v, %v", migrated, err)
+	}
+}
+
+// unmigratableTyped is at data version 3 but has no migration
+type unmigratableTyped struct {
+	plainCapturer
+}
+
+func (u *unmigratableTyped) DataVersion() int {
+	return 3
+}
+
+func TestTypedWithoutMigration(t *testing.T) {
+	unmigratable := Typed[notesData](&unmigratableTyped{})
+	if _, ok := unmigratable.(types.Migrator); ok {
+		t.Fatal("Expected a capturer without MigrateData not to be a migrator")
+	}
+	if _, ok := Typed[notesData](&notesCapturer{}).(types.Migrator); !ok {
+		t.Fatal("Expected notes to be a migrator")
+	}
+
+	manager := NewManager()
+	manager.Register(unmigratable)
+
+	snap := types.NewSnapshot("old")
+	snap.PluginData = map[string]interface{}{
+		"plain": map[string]interface{}{"todos": []interface{}{"ship it"}},
+	}
+
+	err := manager.MigratePluginData(snap)
+	if err == nil || !strings.Contains(err.Error(), "plain cannot migrate data from version 1 to 3") {
+		t.Errorf("Expected a cannot migrate error, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// supported target shells
+const (
+	Posix      = "posix" // bash, zsh and sh
+	Fish       = "fish"
+	PowerShell = "powershell"
+)
+
+// shell names accepted by parse, mapped to their dialect
+var dialects = map[string]string{
+	"sh":         Posix,
+	"bash":       Posix,
+	"zsh":        Posix,
+	"posix":      Posix,
+	"fish":       Fish,
+	"powershell": PowerShell,
+	"pwsh":       PowerShell,
+}
+
+// parse maps a shell name like "zsh" to its dialect
+func Parse(name string) (string, error) {
+	name = strings.TrimSuffix(strings.ToLower(filepath.Base(name)), ".exe")
+	if dialect, ok := dialects[name]; ok {
+		return dialect, nil
+	}
+	return "", fmt.Errorf("unsupported shell '%s' (use bash, zsh, fish or powershell)", name)
+}
+
+// detect guesses the user's shell from $SHELL, defaulting to powershell on windows
+func Detect() string {
+	if dialect, err := Parse(os.Getenv("SHELL")); err == nil {
+		return dialect
+	}
+	if runtime.GOOS == "windows" {
+		return PowerShell
+	}
+	return Posix
+}
+
+// serialize turns a restore plan into lines of shell code
+// actions of unknown kinds are skipped
+func Serialize(dialect string, actions []types.Action) []string {
+	lines := make([]string, 0, len(actions))
+	for _, action := range actions {
+		if line := format(dialect, action); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// format one action for a dialect
+func format(dialect string, action types.Action) string {
+	switch dialect {
+	case Fish:
+		return formatFish(action)
+	case PowerShell:
+		return formatPowerShell(action)
+	default:
+		return formatPosix(action)
+	}
+}
+
+func formatPosix(action types.Action) string {
+	switch action.Kind {
+	case types.ActionCd:
+		return "cd " + Quote(action.Path)
+	case types.ActionExport:
+		return "export " + action.Name + "=" + Quote(action.Value)
+	case types.ActionActivate:
+		return "source " + Quote(venvScript(action.Path, "activate"))
+	case types.ActionRun:
+		return joinArgs(action.Args, Quote)
+	}
+	return ""
+}
+
+func formatFish(action types.Action) string {
+	switch action.Kind {
+	case types.ActionCd:
+		return "cd " + quoteFish(action.Path)
+	case types.ActionExport:
+		return "set -gx " + action.Name + " " + quoteFish(action.Value)
+	case types.ActionActivate:
+		return "source " + quoteFish(venvScript(action.Path, "activate.fish"))
+	case types.ActionRun:
+		return joinArgs(action.Args, quoteFish)
+	}
+	return ""
+}
+
+func formatPowerShell(action types.Action) string {
+	switch action.Kind {
+	case types.ActionCd:
+		return "Set-Location -LiteralPath " + quotePowerShell(action.Path)
+	case types.ActionExport:
+		return "$env:" + action.Name + " = " + quotePowerShell(action.Value)
+	case types.ActionActivate:
+		return "& " + quotePowerShell(venvScript(action.Path, "Activate.ps1"))
+	case types.ActionRun:
+		if len(action.Args) == 0 {
+			return ""
+		}
+		return "& " + joinArgs(action.Args, quotePowerShell)
+	}
+	return ""
+}
+
+// path of an activation script inside a virtualenv
+func venvScript(venv, script string) string {
+	dir := "bin"
+	if runtime.GOOS == "windows" {
+		dir = "Scripts"
+	}
+	return filepath.Join(venv, dir, script)
+}
+
+// quote each argument and join them with spaces
+func joinArgs(args []string, quote func(string) string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_./:=@%+,-]+$`)
+
+// quote a value for posix shells when needed
+func Quote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fish allows \\ and \' escapes inside single quotes
+func quoteFish(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// powershell single quotes are escaped by doubling them
+func quotePowerShell(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,108 @@
+package shell
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]string{
+		"bash":           Posix,
+		"/usr/bin/zsh":   Posix,
+		"fish":           Fish,
+		"pwsh":           PowerShell,
+		"PowerShell":     PowerShell,
+		"/bin/sh":        Posix,
+		"powershell.exe": PowerShell,
+	}
+
+	for name, want := range tests {
+		got, err := Parse(name)
+		if err != nil || got != want {
+			t.Errorf("Parse(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+
+	if _, err := Parse("tcsh"); err == nil {
+		t.Error("Expected error for unsupported shell")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	t.Setenv("SHELL", "/usr/local/bin/fish")
+	if got := Detect(); got != Fish {
+		t.Errorf("Detect() = %q, want fish", got)
+	}
+
+	t.Setenv("SHELL", "")
+	want := Posix
+	if runtime.GOOS == "windows" {
+		want = PowerShell
+	}
+	if got := Detect(); got != want {
+		t.Errorf("Detect() without $SHELL = %q, want %q", got, want)
+	}
+}
+
+func TestSerialize(t *testing.T) {
+	venv := filepath.Join("/work", "my app", ".venv")
+	plan := []types.Action{
+		types.Cd("/work/my app"),
+		types.Export("AWS_PROFILE", "it's"),
+		types.Activate(venv),
+		types.Run("kubectl", "config", "use-context", "prod"),
+		{Kind: "teleport", Path: "/mars"},
+	}
+
+	bin := "bin"
+	if runtime.GOOS == "windows" {
+		bin = "Scripts"
+	}
+
+	tests := map[string][]string{
+		Posix: {
+			"cd '/work/my app'",
+			`export AWS_PROFILE='it'\''s'`,
+			"source '" + filepath.Join(venv, bin, "activate") + "'",
+			"kubectl config use-context prod",
+		},
+		Fish: {
+			"cd '/work/my app'",
+			`set -gx AWS_PROFILE 'it\'s'`,
+			"source '" + filepath.Join(venv, bin, "activate.fish") + "'",
+			"kubectl config use-context prod",
+		},
+		PowerShell: {
+			"Set-Location -LiteralPath '/work/my app'",
+			"$env:AWS_PROFILE = 'it''s'",
+			"& '" + filepath.Join(venv, bin, "Activate.ps1") + "'",
+			"& 'kubectl' 'config' 'use-context' 'prod'",
+		},
+	}
+
+	for dialect, want := range tests {
+		got := Serialize(dialect, plan)
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", dialect, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := map[string]string{
+		"prod":        "prod",
+		"my context":  "'my context'",
+		"it's":        `'it'\''s'`,
+		"/tmp/a-b_c1": "/tmp/a-b_c1",
+	}
+
+	for input, want := range tests {
+		if got := Quote(input); got != want {
+			t.Errorf("Quote(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
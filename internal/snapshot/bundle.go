@@ -0,0 +1,144 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/ansoncodes/workshot/internal/bundle"
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/internal/storage"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// conflict says what import does with a snapshot whose name is taken
+type Conflict string
+
+const (
+	ConflictRename    Conflict = "rename"    // import under a free name
+	ConflictSkip      Conflict = "skip"      // keep the existing snapshot
+	ConflictOverwrite Conflict = "overwrite" // replace the existing snapshot
+)
+
+// imported records what import did with one snapshot of a bundle
+type Imported struct {
+	Name string
+
+	// As is the name the snapshot was saved under, empty if it was skipped
+	As string
+}
+
+// export writes the named snapshots to a bundle
+// the bundle is plain JSON, so with encryption on it needs plaintext set
+func Export(names []string, manager *plugin.Manager, w io.Writer, plaintext bool) error {
+	if err := checkPlaintext(plaintext); err != nil {
+		return err
+	}
+
+	snaps := make([]*types.Snapshot, 0, len(names))
+	for _, name := range names {
+		snap, err := load(name, manager)
+		if err != nil {
+			return err
+		}
+		snaps = append(snaps, snap)
+	}
+
+	return bundle.Write(w, snaps)
+}
+
+// import saves the snapshots of a bundle in the global store
+// the bundle is validated and resolve is asked what to do with each
+// snapshot whose name already exists before anything is saved, and if a
+// save still fails the snapshots saved before it are taken back
+func Import(r io.Reader, manager *plugin.Manager, resolve func(name string) Conflict) ([]Imported, error) {
+	_, snaps, err := bundle.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, snap := range snaps {
+		if snap.SchemaVersion != types.SchemaVersion {
+			return nil, fmt.Errorf("snapshot '%s' has schema version %d, expected %d",
+				snap.Name, snap.SchemaVersion, types.SchemaVersion)
+		}
+	}
+
+	store, err := openStore(manager)
+	if err != nil {
+		return nil, err
+	}
+
+	// decide every name first, keeping what is overwritten to put it back
+	taken := make(map[string]bool)
+	exists := func(name string) bool { return taken[name] || store.Exists(name) }
+	previous := make(map[string]*types.Snapshot)
+	imported := make([]Imported, 0, len(snaps))
+	for _, snap := range snaps {
+		name := snap.Name
+		if exists(name) {
+			switch resolve(name) {
+			case ConflictSkip:
+				imported = append(imported, Imported{Name: name})
+				continue
+			case ConflictRename:
+				snap.Name = freeName(name, exists)
+			case ConflictOverwrite:
+				if taken[name] {
+					return nil, fmt.Errorf("snapshot '%s' is in the bundle twice", name)
+				}
+				old, err := store.Load(name)
+				if err != nil {
+					return nil, fmt.Errorf("failed to load snapshot '%s' to overwrite: %w", name, err)
+				}
+				previous[name] = old
+			}
+		}
+		taken[snap.Name] = true
+		imported = append(imported, Imported{Name: name, As: snap.Name})
+	}
+
+	var saved []string
+	for i, snap := range snaps {
+		if imported[i].As == "" {
+			continue
+		}
+		if err := store.Save(snap); err != nil {
+			if undoErr := undoImport(store, saved, previous); undoErr != nil {
+				return nil, fmt.Errorf("failed to save snapshot '%s': %w (and failed to undo the import: %v)", snap.Name, err, undoErr)
+			}
+			return nil, fmt.Errorf("failed to save snapshot '%s', nothing was imported: %w", snap.Name, err)
+		}
+		saved = append(saved, snap.Name)
+	}
+
+	return imported, nil
+}
+
+// undoimport deletes the snapshots an import saved, putting back the ones
+// it overwrote
+func undoImport(store *storage.Storage, saved []string, previous map[string]*types.Snapshot) error {
+	var errs []error
+	for _, name := range saved {
+		var err error
+		if old, ok := previous[name]; ok {
+			err = store.Save(old)
+		} else {
+			err = store.Delete(name)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("'%s': %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// the first of name-2, name-3, ... that is not taken
+func freeName(name string, taken func(string) bool) string {
+	for i := 2; ; i++ {
+		candidate := name + "-" + strconv.Itoa(i)
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
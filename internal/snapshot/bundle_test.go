@@ -0,0 +1,143 @@
+package snapshot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ansoncodes/workshot/internal/config"
+	"github.com/ansoncodes/workshot/internal/crypt"
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/internal/storage"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+func TestExportImport(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Chdir(t.TempDir())
+
+	store, err := storage.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"api", "web", "docs"} {
+		snap := types.NewSnapshot(name)
+		snap.WorkingDir = "/code/" + name
+		if err := store.Save(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manager := plugin.NewManager()
+	var buf bytes.Buffer
+	if err := Export([]string{"api", "web", "docs"}, manager, &buf, false); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if err := Export([]string{"missing"}, manager, &bytes.Buffer{}, false); err == nil {
+		t.Error("Expected exporting a missing snapshot to fail")
+	}
+
+	// every name is taken, so each conflict is resolved differently
+	store.Save(&types.Snapshot{SchemaVersion: types.SchemaVersion, Name: "api-2"})
+	choices := map[string]Conflict{"api": ConflictRename, "web": ConflictSkip, "docs": ConflictOverwrite}
+	imported, err := Import(&buf, manager, func(name string) Conflict { return choices[name] })
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	want := []Imported{{Name: "api", As: "api-3"}, {Name: "web"}, {Name: "docs", As: "docs"}}
+	if len(imported) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, imported)
+	}
+	for i := range want {
+		if imported[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want[i], imported[i])
+		}
+	}
+
+	// the renamed copy is indexed
+	list, err := store.List()
+	if err != nil || len(list) != 5 {
+		t.Errorf("Expected 5 snapshots after import, got %v (%v)", list, err)
+	}
+	if snap, err := store.Load("api-3"); err != nil || snap.WorkingDir != "/code/api" {
+		t.Errorf("Expected the renamed copy of api, got %v (%v)", snap, err)
+	}
+}
+
+func TestImportFailureSavesNothing(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Chdir(t.TempDir())
+
+	store, err := storage.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"api", "web", "docs"} {
+		snap := types.NewSnapshot(name)
+		snap.WorkingDir = "/code/" + name
+		if err := store.Save(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manager := plugin.NewManager()
+	var buf bytes.Buffer
+	if err := Export([]string{"api", "web", "docs"}, manager, &buf, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// api is overwritten and web renamed before docs cannot be written
+	changed := types.NewSnapshot("api")
+	changed.WorkingDir = "/code/changed"
+	store.Save(changed)
+	store.Delete("docs")
+	if err := os.Mkdir(filepath.Join(home, ".workshot", "shots", "docs.json.tmp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	choices := map[string]Conflict{"api": ConflictOverwrite, "web": ConflictRename}
+	if _, err := Import(&buf, manager, func(name string) Conflict { return choices[name] }); err == nil {
+		t.Fatal("Expected the import to fail")
+	}
+
+	if snap, err := store.Load("api"); err != nil || snap.WorkingDir != "/code/changed" {
+		t.Errorf("Expected the overwritten snapshot back, got %v (%v)", snap, err)
+	}
+	if store.Exists("web-2") || store.Exists("docs") {
+		t.Error("Expected the snapshots saved before the failure to be removed")
+	}
+}
+
+func TestExportEncrypted(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv(crypt.PassphraseEnv, "passphrase")
+	t.Chdir(t.TempDir())
+
+	cfg := &config.Config{Encryption: &config.EncryptionConfig{Enabled: true}}
+	if err := cfg.Save(filepath.Join(home, ".workshot", "config.json")); err != nil {
+		t.Fatal(err)
+	}
+	store, err := storage.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(types.NewSnapshot("api")); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := plugin.NewManager()
+	if err := Export([]string{"api"}, manager, &bytes.Buffer{}, false); err == nil {
+		t.Error("Expected exporting encrypted snapshots in plain JSON to be refused")
+	}
+	if err := Export([]string{"api"}, manager, &bytes.Buffer{}, true); err != nil {
+		t.Errorf("Expected --plaintext to export, got %v", err)
+	}
+}
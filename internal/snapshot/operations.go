@@ -1,32 +1,67 @@
 package snapshot
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/ansoncodes/workshot/internal/capture"
+	"github.com/ansoncodes/workshot/internal/config"
 	"github.com/ansoncodes/workshot/internal/plugin"
 	"github.com/ansoncodes/workshot/internal/storage"
 	"github.com/ansoncodes/workshot/pkg/types"
 )
 
+// PreRestoreName is the hidden snapshot holding the state before the last
+// restore, used by restore --undo
+const PreRestoreName = ".pre-restore"
+
+// restoreoptions controls how a snapshot is restored
+type RestoreOptions struct {
+	// only restore these plugins
+	Selection plugin.Selection
+
+	// asked before cloning the snapshot's repository when its working
+	// directory is gone, returns where to clone it or false to skip
+	Clone func(remote, dir string) (string, bool)
+
+	// restore the snapshot's repository into this directory instead
+	To string
+
+	// saved directories mapped to where they are on this machine, used
+	// when the saved working directory does not exist
+	Paths map[string]string
+
+	// directories searched for a clone of the snapshot's repository when
+	// no mapping applies
+	Roots []string
+}
+
 // freeze saves the current work context
-func Freeze(name string, manager *plugin.Manager) error {
+// the returned snapshot carries the capture report for warnings
+// scope picks the global store or the store of the current repository
+func Freeze(ctx context.Context, name string, manager *plugin.Manager, scope storage.Scope) (*types.Snapshot, error) {
 	// create a new snapshot
 	snap := types.NewSnapshot(name)
 
 	// get current working directory
 	cwd, err := os.Getwd()
 	if err != nil {
-		return fmt.Errorf("failed to get working directory: %w", err)
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
 	}
 	snap.WorkingDir = cwd
 
 	// run all capture plugins
-	pluginData, err := manager.CaptureAll()
+	pluginData, reports, err := manager.CaptureAll(ctx)
 	if err != nil {
-		return fmt.Errorf("capture failed: %w", err)
+		return nil, fmt.Errorf("capture failed: %w", err)
 	}
 	snap.PluginData = pluginData
+	snap.PluginVersions = manager.DataVersions(pluginData)
+	snap.CaptureReport = reports
 
 	// copy git data to top level fields
 	if gitData, ok := pluginData["git"].(map[string]interface{}); ok {
@@ -42,50 +77,342 @@ func Freeze(name string, manager *plugin.Manager) error {
 	}
 
 	// create storage handler
-	store, err := storage.New()
+	var store *storage.Storage
+	if scope == storage.ScopeRepo {
+		root := storage.RepoRoot(cwd)
+		if root == "" {
+			return nil, fmt.Errorf("%s is not in a git repository", cwd)
+		}
+		store, err = storage.NewRepo(root)
+	} else {
+		store, err = storage.New()
+	}
 	if err != nil {
-		return fmt.Errorf("failed to initialize storage: %w", err)
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
 	}
+	store.SetPaths(manager)
 
 	// check if snapshot name already exists
 	if store.Exists(name) {
-		return fmt.Errorf("workshot '%s' already exists (use 'workshot delete %s' first)", name, name)
+		return nil, fmt.Errorf("workshot '%s' already exists (use 'workshot delete %s' first)", name, name)
 	}
 
 	// save snapshot to disk
 	if err := store.Save(snap); err != nil {
-		return fmt.Errorf("failed to save snapshot: %w", err)
+		return nil, fmt.Errorf("failed to save snapshot: %w", err)
 	}
 
-	return nil
+	return snap, nil
 }
 
-// restore loads a saved snapshot and applies it
-func Restore(name string, manager *plugin.Manager) (*types.Snapshot, []error) {
-	// create storage handler
-	store, err := storage.New()
+// restore loads a saved snapshot and applies the selected plugins
+// the state of the plugins about to restore is saved as a hidden snapshot
+// so the restore can be undone, and if a plugin fails the ones that applied
+// are rolled back; if that state cannot be saved nothing is restored
+// plugin errors are in the reports, the errors are about the restore itself
+func Restore(ctx context.Context, name string, manager *plugin.Manager, opts RestoreOptions) (*types.Snapshot, []types.RestoreReport, []error) {
+	snap, err := load(name, manager)
 	if err != nil {
-		return nil, []error{fmt.Errorf("failed to initialize storage: %w", err)}
+		return nil, nil, []error{err}
 	}
 
-	// load snapshot from disk
-	snap, err := store.Load(name)
+	var errors []error
+	cwd, _ := os.Getwd()
+
+	// find the directory on this machine
+	if note, err := relocate(ctx, snap, manager, opts); err != nil {
+		return nil, nil, []error{err}
+	} else if note != "" {
+		errors = append(errors, fmt.Errorf("%s", note))
+	}
+
+	// bring back a deleted checkout
+	if err := cloneMissing(ctx, snap, manager, opts.Clone); err != nil {
+		errors = append(errors, err)
+	}
+
+	// move to saved working directory
+	if err := enter(snap); err != nil {
+		errors = append(errors, err)
+	}
+
+	// freeze the current state of what is about to change, plugins that
+	// only have shell commands leave nothing to roll back
+	dir, _ := os.Getwd()
+	var before *types.Snapshot
+	if restorable := manager.Restorable(snap.PluginData, opts.Selection); len(restorable) > 0 {
+		if before, err = capturePreRestore(ctx, cwd, manager, restorable); err != nil {
+			return snap, nil, append(errors, fmt.Errorf("nothing was restored, the current state could not be saved to roll back to: %w", err))
+		}
+	}
+
+	// run restore on the selected plugins
+	reports, err := manager.RestoreAll(ctx, snap.PluginData, opts.Selection)
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	if before == nil {
+		return snap, reports, errors
+	}
+
+	appliedData := make(map[string]interface{})
+	for _, report := range reports {
+		if report.Status == types.RestoreApplied {
+			appliedData[report.Name] = snap.PluginData[report.Name]
+		}
+	}
+
+	// undo the plugins that applied, even if the restore was cancelled
+	if err != nil || failed(reports) {
+		rolledBack, rollbackErrors := manager.RollbackAll(context.WithoutCancel(ctx), before.PluginData, appliedData)
+		if len(rolledBack) > 0 {
+			errors = append(errors, fmt.Errorf("restore failed, rolled back %s", strings.Join(rolledBack, ", ")))
+		}
+		return snap, reports, append(errors, rollbackErrors...)
+	}
+
+	before.Rollback = &types.RollbackInfo{
+		Snapshot: name,
+		Dir:      dir,
+		Applied:  appliedData,
+	}
+
+	// the pre-restore snapshot is never shared with the repository
+	store, err := openStore(manager)
+	if err == nil {
+		err = store.Save(before)
+	}
+	if err != nil {
+		errors = append(errors, fmt.Errorf("restore cannot be undone: %w", err))
+	}
+
+	return snap, reports, errors
+}
+
+// undo rolls back the last restore using the pre-restore snapshot
+// the returned snapshot's working dir is where the user was before restoring
+func Undo(ctx context.Context, manager *plugin.Manager) (*types.Snapshot, []string, []error) {
+	store, err := openStore(manager)
+	if err != nil {
+		return nil, nil, []error{err}
+	}
+
+	if !store.Exists(PreRestoreName) {
+		return nil, nil, []error{fmt.Errorf("no restore to undo")}
+	}
+
+	before, err := store.Load(PreRestoreName)
+	if err != nil {
+		return nil, nil, []error{err}
+	}
+	if before.Rollback == nil {
+		return nil, nil, []error{fmt.Errorf("no restore to undo")}
+	}
+
+	var errors []error
+
+	// plugins roll back where they were restored
+	if err := os.Chdir(before.Rollback.Dir); err != nil {
+		errors = append(errors, fmt.Errorf("failed to change directory: %w", err))
+	}
+
+	rolledBack, rollbackErrors := manager.RollbackAll(ctx, before.PluginData, before.Rollback.Applied)
+	errors = append(errors, rollbackErrors...)
+
+	// a restore is undone only once
+	if err := store.Delete(PreRestoreName); err != nil {
+		errors = append(errors, err)
+	}
+
+	return before, rolledBack, errors
+}
+
+// preview loads a saved snapshot and describes what restoring it would do
+// plugins compare against the saved working directory, so the process
+// still moves there, but nothing is restored
+func Preview(ctx context.Context, name string, manager *plugin.Manager, opts RestoreOptions) (*types.Snapshot, []types.PluginPreview, []error) {
+	snap, err := load(name, manager)
+	if err != nil {
+		return nil, nil, []error{err}
+	}
+
+	var errors []error
+	if note, err := relocate(ctx, snap, manager, opts); err != nil {
+		return nil, nil, []error{err}
+	} else if note != "" {
+		errors = append(errors, fmt.Errorf("%s", note))
+	}
+
+	if remote, dir := missingRepo(snap); remote != "" {
+		errors = append(errors, fmt.Errorf("%s is missing, restore will offer to clone %s", dir, remote))
+	} else if err := enter(snap); err != nil {
+		errors = append(errors, err)
+	}
+
+	return snap, manager.Preview(ctx, snap.PluginData, opts.Selection), errors
+}
+
+// plan loads a saved snapshot and finds its directory on this machine, for
+// printing the restore commands; it changes nothing, not even the process's
+// directory, and clones nothing
+func Plan(ctx context.Context, name string, manager *plugin.Manager, opts RestoreOptions) (*types.Snapshot, []error) {
+	snap, err := load(name, manager)
 	if err != nil {
 		return nil, []error{err}
 	}
 
 	var errors []error
+	if note, err := relocate(ctx, snap, manager, opts); err != nil {
+		return nil, []error{err}
+	} else if note != "" {
+		errors = append(errors, fmt.Errorf("%s", note))
+	}
 
-	// move to saved working directory
-	if snap.WorkingDir != "" {
-		if err := os.Chdir(snap.WorkingDir); err != nil {
-			errors = append(errors, fmt.Errorf("failed to change directory: %w", err))
+	if remote, dir := missingRepo(snap); remote != "" {
+		errors = append(errors, fmt.Errorf("%s is missing, restore without -c to clone %s", dir, remote))
+	}
+
+	return snap, errors
+}
+
+// load a snapshot, migrating plugin data through the manager
+// the store of the current repository is searched before the global one
+func load(name string, manager *plugin.Manager) (*types.Snapshot, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	store, err := storage.Open(cwd, name)
+	if err != nil {
+		return nil, err
+	}
+	store.SetMigrator(manager)
+	store.SetPaths(manager)
+
+	// load snapshot from disk
+	return store.Load(name)
+}
+
+// checkplaintext refuses to write snapshots out in plain JSON while they
+// are encrypted at rest, unless plaintext says it is meant
+func checkPlaintext(plaintext bool) error {
+	if plaintext {
+		return nil
+	}
+	cfg, err := config.LoadGlobal()
+	if err != nil {
+		return fmt.Errorf("failed to read encryption settings: %w", err)
+	}
+	if cfg.Encryption != nil && cfg.Encryption.Enabled {
+		return fmt.Errorf("snapshots are encrypted, but this writes them in plain JSON; pass --plaintext to do it anyway")
+	}
+	return nil
+}
+
+// open global storage with plugin data migration
+func openStore(manager *plugin.Manager) (*storage.Storage, error) {
+	store, err := storage.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	store.SetMigrator(manager)
+	return store, nil
+}
+
+// capture the state a restore of the named plugins is about to change
+// cwd is where the user was, the plugins capture the current directory
+// a plugin that can roll back must capture, or it would roll back to nothing
+func capturePreRestore(ctx context.Context, cwd string, manager *plugin.Manager, names []string) (*types.Snapshot, error) {
+	pluginData, reports, err := manager.CaptureFor(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, report := range reports {
+		if report.Error != "" && slices.Contains(names, report.Name) && manager.CanRollback(report.Name) {
+			return nil, fmt.Errorf("%s: %s", report.Name, report.Error)
 		}
 	}
 
-	// run restore on all plugins
-	restoreErrors := manager.RestoreAll(snap.PluginData)
-	errors = append(errors, restoreErrors...)
+	snap := types.NewSnapshot(PreRestoreName)
+	snap.WorkingDir = cwd
+	snap.PluginData = pluginData
+	snap.PluginVersions = manager.DataVersions(pluginData)
+	snap.CaptureReport = reports
+	return snap, nil
+}
 
-	return snap, errors
+// missingrepo returns the remote and root of the snapshot's repository if
+// the repository is gone, or empty strings if there is nothing to clone
+func missingRepo(snap *types.Snapshot) (string, string) {
+	if snap.WorkingDir == "" || exists(snap.WorkingDir) {
+		return "", ""
+	}
+
+	// only the subdirectory is gone if the repository is still there
+	remote, root := repoRemote(snap), repoRoot(snap)
+	if remote == "" || exists(root) {
+		return "", ""
+	}
+
+	return remote, root
+}
+
+// clone the snapshot's repository if it is gone
+// the working directory moves along if it is cloned somewhere else
+func cloneMissing(ctx context.Context, snap *types.Snapshot, manager *plugin.Manager, clone func(remote, dir string) (string, bool)) error {
+	remote, root := missingRepo(snap)
+	if remote == "" || clone == nil {
+		return nil
+	}
+
+	dir, ok := clone(remote, root)
+	if !ok {
+		return nil
+	}
+	if dir, err := filepath.Abs(dir); err == nil {
+		move(snap, manager, root, dir)
+		root = dir
+	}
+
+	gitData, err := plugin.Decode[capture.GitData](snap.PluginData["git"])
+	if err != nil {
+		gitData = &capture.GitData{}
+	}
+	gitData.Remote = remote
+	if err := capture.CloneRepo(ctx, gitData, root); err != nil {
+		return err
+	}
+
+	// the working directory may be untracked, so not part of the clone
+	return os.MkdirAll(snap.WorkingDir, 0755)
+}
+
+// exists reports whether a path exists
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// failed reports whether any plugin failed to restore
+func failed(reports []types.RestoreReport) bool {
+	for _, report := range reports {
+		if report.Status == types.RestoreFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// enter the snapshot's working directory
+func enter(snap *types.Snapshot) error {
+	if snap.WorkingDir == "" {
+		return nil
+	}
+	if err := os.Chdir(snap.WorkingDir); err != nil {
+		return fmt.Errorf("failed to change directory: %w", err)
+	}
+	return nil
 }
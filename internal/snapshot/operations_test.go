@@ -0,0 +1,169 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/internal/storage"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// recordingCapturer counts captures and restores
+type recordingCapturer struct {
+	name       string
+	captures   int
+	restores   int
+	captureErr error
+}
+
+func (r *recordingCapturer) Name() string {
+	return r.name
+}
+
+func (r *recordingCapturer) Priority() int {
+	return 10
+}
+
+func (r *recordingCapturer) Capture(ctx context.Context) (map[string]interface{}, error) {
+	r.captures++
+	return map[string]interface{}{"value": "now"}, r.captureErr
+}
+
+func (r *recordingCapturer) Restore(ctx context.Context, data map[string]interface{}) error {
+	r.restores++
+	return nil
+}
+
+func (r *recordingCapturer) CanRestore(data map[string]interface{}) bool {
+	return true
+}
+
+func (r *recordingCapturer) RestorePlan(data map[string]interface{}) []types.Action {
+	return []types.Action{types.Run("echo", r.name)}
+}
+
+// rollbackCapturer is a recordingCapturer that can undo its restore
+type rollbackCapturer struct {
+	recordingCapturer
+}
+
+func (r *rollbackCapturer) Rollback(ctx context.Context, before, applied map[string]interface{}) error {
+	return nil
+}
+
+// save a snapshot of dir with data for the given plugins
+func saveSnapshot(t *testing.T, name, dir string, plugins ...string) {
+	t.Helper()
+
+	store, err := storage.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap := types.NewSnapshot(name)
+	snap.WorkingDir = dir
+	for _, plugin := range plugins {
+		snap.PluginData[plugin] = map[string]interface{}{"value": "saved"}
+	}
+	if err := store.Save(snap); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanChangesNothing(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	cwd := t.TempDir()
+	t.Chdir(cwd)
+
+	dir := t.TempDir()
+	saveSnapshot(t, "api", dir, "notes")
+
+	notes := &recordingCapturer{name: "notes"}
+	manager := plugin.NewManager()
+	manager.Register(notes)
+
+	snap, errors := Plan(t.Context(), "api", manager, RestoreOptions{})
+	if snap == nil || len(errors) != 0 {
+		t.Fatalf("Plan failed: %v", errors)
+	}
+	if plan := manager.Plan(snap.PluginData, plugin.Selection{}); len(plan) != 1 {
+		t.Errorf("Expected the notes plan, got %v", plan)
+	}
+
+	if notes.captures != 0 || notes.restores != 0 {
+		t.Errorf("Expected no capture or restore, got %d and %d", notes.captures, notes.restores)
+	}
+	if wd, _ := os.Getwd(); wd != cwd {
+		t.Errorf("Expected to stay in %s, moved to %s", cwd, wd)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".workshot", "shots", PreRestoreName+".json")); err == nil {
+		t.Error("Expected no pre-restore snapshot")
+	}
+}
+
+func TestRestoreCapturesSelectedPlugins(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Chdir(t.TempDir())
+
+	dir := t.TempDir()
+	saveSnapshot(t, "api", dir, "notes", "jira")
+
+	notes := &rollbackCapturer{recordingCapturer{name: "notes"}}
+	jira := &recordingCapturer{name: "jira"}
+	manager := plugin.NewManager()
+	manager.Register(notes)
+	manager.Register(jira)
+
+	_, reports, errs := Restore(t.Context(), "api", manager, RestoreOptions{Selection: plugin.Selection{Only: []string{"notes"}}})
+	if len(errs) != 0 {
+		t.Fatalf("Restore failed: %v", errs)
+	}
+	if len(reports) != 2 || notes.restores != 1 || jira.restores != 0 {
+		t.Errorf("Expected only notes restored, got %+v", reports)
+	}
+
+	// only the plugin that restores is frozen first
+	if notes.captures != 1 || jira.captures != 0 {
+		t.Errorf("Expected only notes captured, got notes %d, jira %d", notes.captures, jira.captures)
+	}
+
+	store, _ := storage.New()
+	before, err := store.Load(PreRestoreName)
+	if err != nil {
+		t.Fatalf("Expected a pre-restore snapshot: %v", err)
+	}
+	if len(before.PluginData) != 1 || before.PluginData["notes"] == nil {
+		t.Errorf("Expected only notes in the pre-restore snapshot, got %v", before.PluginData)
+	}
+}
+
+func TestRestoreWithoutRollback(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Chdir(t.TempDir())
+
+	dir := t.TempDir()
+	saveSnapshot(t, "api", dir, "notes")
+
+	// notes could not roll back without its current state
+	notes := &rollbackCapturer{recordingCapturer{name: "notes", captureErr: errors.New("locked")}}
+	manager := plugin.NewManager()
+	manager.Register(notes)
+
+	_, reports, errs := Restore(t.Context(), "api", manager, RestoreOptions{})
+	if len(errs) != 1 || len(reports) != 0 {
+		t.Fatalf("Expected the restore to fail, got %+v, %v", reports, errs)
+	}
+	if notes.restores != 0 {
+		t.Error("Expected nothing to be restored")
+	}
+}
@@ -0,0 +1,144 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/ansoncodes/workshot/internal/capture"
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// how deep under a root repositories are searched for
+const maxSearchDepth = 4
+
+// relocate moves a snapshot to where its directory is on this machine
+// an explicit target wins, then the path mappings, then a clone of the
+// repository found under the search roots
+// it returns a note about the move, empty if the snapshot was not moved
+// or was moved where the user asked
+func relocate(ctx context.Context, snap *types.Snapshot, manager *plugin.Manager, opts RestoreOptions) (string, error) {
+	if snap.WorkingDir == "" {
+		return "", nil
+	}
+
+	if opts.To != "" {
+		to, err := filepath.Abs(opts.To)
+		if err != nil {
+			return "", fmt.Errorf("invalid target directory: %w", err)
+		}
+		move(snap, manager, repoRoot(snap), to)
+		return "", nil
+	}
+
+	if exists(snap.WorkingDir) {
+		return "", nil
+	}
+	saved := snap.WorkingDir
+
+	if from, to, ok := matchRule(snap.WorkingDir, opts.Paths); ok {
+		move(snap, manager, from, to)
+		return fmt.Sprintf("%s is mapped to %s", saved, snap.WorkingDir), nil
+	}
+
+	remote := repoRemote(snap)
+	if remote == "" {
+		return "", nil
+	}
+	if dir := findRepo(ctx, opts.Roots, remote); dir != "" {
+		move(snap, manager, repoRoot(snap), dir)
+		return fmt.Sprintf("%s does not exist, using the clone of %s in %s", saved, remote, dir), nil
+	}
+
+	return "", nil
+}
+
+// find the longest mapped directory containing path
+func matchRule(path string, rules map[string]string) (string, string, bool) {
+	var from, to string
+	for ruleFrom, ruleTo := range rules {
+		if capture.IsWithin(path, ruleFrom) && len(ruleFrom) > len(from) {
+			from, to = ruleFrom, ruleTo
+		}
+	}
+	return from, to, from != ""
+}
+
+// search the roots for a repository with the given remote
+func findRepo(ctx context.Context, roots []string, remote string) string {
+	for _, root := range roots {
+		var found string
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+
+			if exists(filepath.Join(path, ".git")) {
+				if capture.SameRemote(capture.RepoRemote(ctx, path), remote) {
+					found = path
+					return filepath.SkipAll
+				}
+				return filepath.SkipDir
+			}
+
+			rel, _ := filepath.Rel(root, path)
+			if strings.Count(rel, string(filepath.Separator)) >= maxSearchDepth-1 {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+
+		if found != "" {
+			return found
+		}
+	}
+	return ""
+}
+
+// the top level of the snapshot's repository, or its working directory
+func repoRoot(snap *types.Snapshot) string {
+	gitData, err := plugin.Decode[capture.GitData](snap.PluginData["git"])
+	if err == nil && gitData.Root != "" && capture.IsWithin(snap.WorkingDir, gitData.Root) {
+		return gitData.Root
+	}
+	return snap.WorkingDir
+}
+
+// the remote of the snapshot's repository
+func repoRemote(snap *types.Snapshot) string {
+	gitData, err := plugin.Decode[capture.GitData](snap.PluginData["git"])
+	if err == nil && gitData.Remote != "" {
+		return gitData.Remote
+	}
+	return snap.GitRemote
+}
+
+// move the working directory and the paths the plugins declare in their
+// data from under one directory to another
+func move(snap *types.Snapshot, manager *plugin.Manager, from, to string) {
+	from, to = filepath.Clean(from), filepath.Clean(to)
+	if from == to {
+		return
+	}
+
+	snap.WorkingDir = rebase(snap.WorkingDir, from, to)
+	manager.MapPaths(snap.PluginData, func(path string) string {
+		return rebase(path, from, to)
+	})
+}
+
+// move path from under one directory to another
+// paths outside from are returned as they are
+func rebase(path, from, to string) string {
+	if !capture.IsWithin(path, from) {
+		return path
+	}
+	rel, _ := filepath.Rel(from, path)
+	return filepath.Join(to, rel)
+}
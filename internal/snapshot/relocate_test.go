@@ -0,0 +1,140 @@
+package snapshot
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/ansoncodes/workshot/internal/capture"
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+// newSnapshot returns a snapshot of a repository saved on another machine
+func newSnapshot() *types.Snapshot {
+	snap := types.NewSnapshot("api")
+	snap.WorkingDir = "/home/alice/src/api/cmd"
+	snap.PluginData["git"] = map[string]interface{}{
+		"branch": "main",
+		"remote": "git@github.com:me/api.git",
+		"root":   "/home/alice/src/api",
+	}
+	snap.PluginData["editor"] = map[string]interface{}{
+		"open_files": []interface{}{
+			map[string]interface{}{"path": "/home/alice/src/api/main.go"},
+			map[string]interface{}{"path": "/etc/hosts"},
+			map[string]interface{}{"path": "README.md"},
+		},
+	}
+	snap.PluginData["terminal"] = map[string]interface{}{
+		"recent_commands": []interface{}{"vim /home/alice/src/api/main.go"},
+	}
+	return snap
+}
+
+// newManager has the git and editor plugins, with paths, and the terminal one
+func newManager() *plugin.Manager {
+	manager := plugin.NewManager()
+	manager.Register(capture.NewGitCapturer())
+	manager.Register(capture.NewEditorCapturer())
+	manager.Register(capture.NewTerminalCapturer())
+	return manager
+}
+
+func TestRelocateMapping(t *testing.T) {
+	code := t.TempDir()
+	snap := newSnapshot()
+
+	note, err := relocate(t.Context(), snap, newManager(), RestoreOptions{Paths: map[string]string{
+		"/home/alice":     "/nowhere",
+		"/home/alice/src": code,
+	}})
+	if err != nil {
+		t.Fatalf("relocate failed: %v", err)
+	}
+	if note == "" {
+		t.Error("Expected a note about the mapping")
+	}
+
+	// the longest mapping wins
+	if snap.WorkingDir != filepath.Join(code, "api", "cmd") {
+		t.Errorf("Unexpected working directory: %s", snap.WorkingDir)
+	}
+
+	// paths the plugins declare move along, everything else is left alone
+	git := snap.PluginData["git"].(map[string]interface{})
+	files := snap.PluginData["editor"].(map[string]interface{})["open_files"].([]interface{})
+	if git["root"] != filepath.Join(code, "api") || git["remote"] != "git@github.com:me/api.git" {
+		t.Errorf("Unexpected git data: %v", git)
+	}
+	var paths []interface{}
+	for _, file := range files {
+		paths = append(paths, file.(map[string]interface{})["path"])
+	}
+	if paths[0] != filepath.Join(code, "api", "main.go") || paths[1] != "/etc/hosts" || paths[2] != "README.md" {
+		t.Errorf("Unexpected editor files: %v", paths)
+	}
+	history := snap.PluginData["terminal"].(map[string]interface{})["recent_commands"].([]interface{})
+	if history[0] != "vim /home/alice/src/api/main.go" {
+		t.Errorf("Expected the terminal history untouched, got %v", history)
+	}
+}
+
+func TestRelocateTo(t *testing.T) {
+	to := t.TempDir()
+	snap := newSnapshot()
+
+	// the repository moves, not just the working directory
+	if _, err := relocate(t.Context(), snap, newManager(), RestoreOptions{To: to, Paths: map[string]string{"/home/alice": "/nowhere"}}); err != nil {
+		t.Fatalf("relocate failed: %v", err)
+	}
+	if snap.WorkingDir != filepath.Join(to, "cmd") {
+		t.Errorf("Expected --to to win over mappings, got %s", snap.WorkingDir)
+	}
+}
+
+func TestRelocateExisting(t *testing.T) {
+	dir := t.TempDir()
+	snap := newSnapshot()
+	snap.WorkingDir = dir
+
+	note, err := relocate(t.Context(), snap, newManager(), RestoreOptions{Paths: map[string]string{dir: "/nowhere"}})
+	if err != nil || note != "" || snap.WorkingDir != dir {
+		t.Errorf("Expected an existing directory to stay, got %s (%q, %v)", snap.WorkingDir, note, err)
+	}
+}
+
+func TestRelocateSearch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	// a clone with the same remote in another layout, next to a decoy
+	root := t.TempDir()
+	for dir, remote := range map[string]string{
+		"github/me/web": "git@github.com:me/web.git",
+		"github/me/api": "https://github.com/me/api",
+	} {
+		path := filepath.Join(root, dir)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, args := range [][]string{{"init", "-q"}, {"remote", "add", "origin", remote}} {
+			if output, err := exec.Command("git", append([]string{"-C", path}, args...)...).CombinedOutput(); err != nil {
+				t.Fatalf("git %v failed: %s", args, output)
+			}
+		}
+	}
+
+	snap := newSnapshot()
+	note, err := relocate(t.Context(), snap, newManager(), RestoreOptions{Roots: []string{filepath.Join(root, "missing"), root}})
+	if err != nil {
+		t.Fatalf("relocate failed: %v", err)
+	}
+
+	want := filepath.Join(root, "github", "me", "api", "cmd")
+	if snap.WorkingDir != want || note == "" {
+		t.Errorf("Expected %s, got %s (%q)", want, snap.WorkingDir, note)
+	}
+}
@@ -0,0 +1,225 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ansoncodes/workshot/internal/capture"
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/internal/storage"
+	"github.com/ansoncodes/workshot/pkg/types"
+)
+
+const (
+	// shared snapshots are commits under this namespace, one ref each
+	refPrefix = "refs/workshot/"
+
+	// the file holding the snapshot in a shared commit
+	sharedFile = "snapshot.json"
+)
+
+// push shares a snapshot of the current repository through its remote
+// the snapshot is committed with paths relative to the repository root
+// under refs/workshot/<name>, on top of the last version pushed from here
+// it returns the pushed commit, and with encryption on it needs plaintext set
+func Push(ctx context.Context, name string, manager *plugin.Manager, remote string, force, plaintext bool) (string, error) {
+	if err := checkPlaintext(plaintext); err != nil {
+		return "", err
+	}
+
+	root, err := currentRepo()
+	if err != nil {
+		return "", err
+	}
+	ref := refPrefix + name
+	if err := checkSharedName(ctx, root, name); err != nil {
+		return "", err
+	}
+
+	snap, err := load(name, manager)
+	if err != nil {
+		return "", err
+	}
+	if !capture.IsWithin(snap.WorkingDir, root) {
+		return "", fmt.Errorf("workshot '%s' was not taken in this repository (%s)", name, snap.WorkingDir)
+	}
+
+	storage.Relativize(snap, root, manager)
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	commit, err := commitSnapshot(ctx, root, ref, name, data)
+	if err != nil {
+		return "", err
+	}
+
+	refspec := ref + ":" + ref
+	if force {
+		refspec = "+" + refspec
+	}
+	if _, err := git(ctx, root, nil, "push", "--quiet", remote, refspec); err != nil {
+		return "", fmt.Errorf("failed to push %s to %s: %w", name, remote, err)
+	}
+
+	return commit, nil
+}
+
+// fetch downloads shared snapshots from the remote into the repository's
+// store, all of them if no names are given
+// snapshots that exist locally are skipped unless force is set
+// it returns the names of the fetched and the skipped snapshots
+func Fetch(ctx context.Context, remote string, names []string, force bool) ([]string, []string, error) {
+	root, err := currentRepo()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the remote is the source of truth for shared refs
+	refspecs := []string{"+" + refPrefix + "*:" + refPrefix + "*"}
+	if len(names) > 0 {
+		refspecs = refspecs[:0]
+		for _, name := range names {
+			if err := checkSharedName(ctx, root, name); err != nil {
+				return nil, nil, err
+			}
+			refspecs = append(refspecs, "+"+refPrefix+name+":"+refPrefix+name)
+		}
+	}
+	args := append([]string{"fetch", "--quiet", remote}, refspecs...)
+	if _, err := git(ctx, root, nil, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch from %s: %w", remote, err)
+	}
+
+	if len(names) == 0 {
+		output, err := git(ctx, root, nil, "for-each-ref", "--format=%(refname)", refPrefix)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, ref := range strings.Fields(output) {
+			names = append(names, strings.TrimPrefix(ref, refPrefix))
+		}
+	}
+
+	store, err := storage.NewRepo(root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	// refs pushed by hand can have names no snapshot can take
+	var fetched, skipped, invalid []string
+	for _, name := range names {
+		if storage.CheckName(name) != nil {
+			invalid = append(invalid, name)
+			continue
+		}
+		if store.Exists(name) && !force {
+			skipped = append(skipped, name)
+			continue
+		}
+
+		data, err := git(ctx, root, nil, "show", refPrefix+name+":"+sharedFile)
+		if err != nil {
+			return fetched, skipped, fmt.Errorf("failed to read shared snapshot '%s': %w", name, err)
+		}
+
+		var snap types.Snapshot
+		if err := json.Unmarshal([]byte(data), &snap); err != nil {
+			return fetched, skipped, fmt.Errorf("invalid shared snapshot '%s': %w", name, err)
+		}
+		// it is saved relative to the repository, just as the store keeps it
+		snap.Name = name
+
+		if err := store.Save(&snap); err != nil {
+			return fetched, skipped, fmt.Errorf("failed to save snapshot '%s': %w", name, err)
+		}
+		fetched = append(fetched, name)
+	}
+
+	if len(invalid) > 0 {
+		return fetched, skipped, fmt.Errorf("ignored shared refs that are not snapshot names: %s", strings.Join(invalid, ", "))
+	}
+	return fetched, skipped, nil
+}
+
+// a name is shared only if it is a snapshot name and a ref name
+func checkSharedName(ctx context.Context, root, name string) error {
+	if err := storage.CheckName(name); err != nil {
+		return err
+	}
+	if _, err := git(ctx, root, nil, "check-ref-format", refPrefix+name); err != nil {
+		return fmt.Errorf("'%s' cannot be shared, it is not a valid git ref name", name)
+	}
+	return nil
+}
+
+// commit the snapshot json on top of the ref and move the ref to it
+func commitSnapshot(ctx context.Context, root, ref, name string, data []byte) (string, error) {
+	blob, err := git(ctx, root, data, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return "", err
+	}
+
+	tree, err := git(ctx, root, []byte("100644 blob "+blob+"\t"+sharedFile+"\n"), "mktree")
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"commit-tree", tree, "-m", "workshot " + name}
+	parent, _ := git(ctx, root, nil, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if parent != "" {
+		// nothing changed since the last push
+		if parentTree, _ := git(ctx, root, nil, "rev-parse", parent+"^{tree}"); parentTree == tree {
+			return parent, nil
+		}
+		args = append(args, "-p", parent)
+	}
+	commit, err := git(ctx, root, nil, args...)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := git(ctx, root, nil, "update-ref", ref, commit); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+// the root of the git repository of the working directory
+func currentRepo() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	root := storage.RepoRoot(cwd)
+	if root == "" {
+		return "", fmt.Errorf("%s is not in a git repository", cwd)
+	}
+	return root, nil
+}
+
+// run git in the repository with optional input on stdin
+// it returns the trimmed output, errors carry git's message
+func git(ctx context.Context, root string, input []byte, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", root}, args...)...)
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], message)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,110 @@
+package snapshot
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ansoncodes/workshot/internal/plugin"
+	"github.com/ansoncodes/workshot/internal/storage"
+)
+
+func TestPushFetch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	for _, key := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(key, "test")
+	}
+	for _, key := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(key, "test@example.com")
+	}
+
+	git := func(dir string, args ...string) string {
+		output, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %s", args, output)
+		}
+		return string(output)
+	}
+
+	// alice and bob share a bare remote
+	base := t.TempDir()
+	remote := filepath.Join(base, "remote.git")
+	alice := filepath.Join(base, "alice")
+	bob := filepath.Join(base, "bob")
+	git(base, "init", "-q", "--bare", remote)
+	git(base, "clone", "-q", remote, alice)
+	git(alice, "commit", "-q", "--allow-empty", "-m", "init")
+	git(alice, "push", "-q", "origin", "HEAD")
+	git(base, "clone", "-q", remote, bob)
+
+	manager := plugin.NewManager()
+	os.MkdirAll(filepath.Join(alice, "api"), 0755)
+	t.Chdir(filepath.Join(alice, "api"))
+	if _, err := Freeze(t.Context(), "handoff", manager, storage.ScopeRepo); err != nil {
+		t.Fatalf("Freeze failed: %v", err)
+	}
+
+	commit, err := Push(t.Context(), "handoff", manager, "origin", false, false)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if again, err := Push(t.Context(), "handoff", manager, "origin", false, false); err != nil || again != commit {
+		t.Errorf("Expected an unchanged snapshot to keep commit %s, got %s (%v)", commit, again, err)
+	}
+	if _, err := Push(t.Context(), "not shared", manager, "origin", false, false); err == nil {
+		t.Error("Expected an invalid ref name to be refused")
+	}
+
+	t.Chdir(bob)
+	fetched, skipped, err := Fetch(t.Context(), "origin", nil, false)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if len(fetched) != 1 || fetched[0] != "handoff" || len(skipped) != 0 {
+		t.Errorf("Expected handoff to be fetched, got %v and %v", fetched, skipped)
+	}
+
+	// the snapshot points into bob's checkout
+	store, err := storage.NewRepo(bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap, err := store.Load("handoff")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if snap.WorkingDir != filepath.Join(bob, "api") {
+		t.Errorf("Expected the working directory in bob's checkout, got %s", snap.WorkingDir)
+	}
+
+	// snapshots that exist are kept unless forced
+	if _, skipped, err := Fetch(t.Context(), "origin", []string{"handoff"}, false); err != nil || len(skipped) != 1 {
+		t.Errorf("Expected handoff to be skipped, got %v (%v)", skipped, err)
+	}
+	if fetched, _, err := Fetch(t.Context(), "origin", []string{"handoff"}, true); err != nil || len(fetched) != 1 {
+		t.Errorf("Expected handoff to be replaced, got %v (%v)", fetched, err)
+	}
+
+	// a ref pushed by hand that is no snapshot name is reported, not saved
+	git(alice, "push", "-q", "origin", commit+":refs/workshot/nested/name")
+	fetched, skipped, err = Fetch(t.Context(), "origin", nil, false)
+	if err == nil || !strings.Contains(err.Error(), "nested/name") {
+		t.Errorf("Expected the nested ref to be reported, got %v", err)
+	}
+	if len(fetched) != 0 || len(skipped) != 1 || store.Exists("nested/name") {
+		t.Errorf("Expected only handoff, skipped, got %v and %v", fetched, skipped)
+	}
+	for _, name := range []string{"nested/name", ".hidden", "a*"} {
+		if _, _, err := Fetch(t.Context(), "origin", []string{name}, true); err == nil {
+			t.Errorf("Expected fetching %q to be refused", name)
+		}
+	}
+}
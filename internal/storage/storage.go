@@ -6,8 +6,11 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/ansoncodes/workshot/internal/config"
+	"github.com/ansoncodes/workshot/internal/crypt"
 	"github.com/ansoncodes/workshot/pkg/types"
 )
 
@@ -15,6 +18,18 @@ const (
 	defaultDirName = ".workshot"
 	shotsSubdir    = "shots"
 	indexFile      = "index.json"
+
+	// repoPrefix stands for the repository root in paths saved by a repo
+	// store, so the snapshot works wherever the repository is checked out
+	repoPrefix = "${repo}"
+)
+
+// scope says where a store keeps its snapshots
+type Scope string
+
+const (
+	ScopeGlobal Scope = "global" // ~/.workshot
+	ScopeRepo   Scope = "repo"   // .workshot at the root of a git repository
 )
 
 // metadata stores small snapshot info for fast listing
@@ -23,6 +38,12 @@ type Metadata struct {
 	CreatedAt  time.Time `json:"created_at"`
 	WorkingDir string    `json:"working_dir"`
 	GitBranch  string    `json:"git_branch,omitempty"`
+
+	// Encrypted snapshots only keep their name and time in the index.
+	Encrypted bool `json:"encrypted,omitempty"`
+
+	// Scope is the store the snapshot was listed from.
+	Scope Scope `json:"-"`
 }
 
 // index stores all snapshot metadata for fast access
@@ -31,33 +52,183 @@ type Index struct {
 	Snapshots map[string]Metadata `json:"snapshots"`
 }
 
+// pluginmigrator upgrades plugin data saved by older plugin versions
+type PluginMigrator interface {
+	MigratePluginData(snap *types.Snapshot) error
+}
+
+// pluginpaths maps the paths in plugin data, leaving other strings alone
+type PluginPaths interface {
+	MapPaths(pluginData map[string]interface{}, f func(path string) string)
+}
+
 // storage handles saving and loading snapshots
 type Storage struct {
 	basePath  string
 	indexPath string
+	migrator  PluginMigrator
+	paths     PluginPaths
+	scope     Scope
+
+	// repository root of a repo store, paths under it are saved relative
+	root string
+
+	// key decrypts encrypted snapshots, and encrypts saved ones if encrypt
+	// is set, only the global store is encrypted
+	key     *crypt.Key
+	encrypt bool
 }
 
-// new creates and initializes storage
+// new creates and initializes the global storage
+// snapshots are encrypted when the global config turns it on
 func New() (*Storage, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get home directory: %w", err)
 	}
 
-	basePath := filepath.Join(home, defaultDirName, shotsSubdir)
-	indexPath := filepath.Join(home, defaultDirName, indexFile)
+	store, err := open(filepath.Join(home, defaultDirName), ScopeGlobal, "")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.LoadGlobal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption settings: %w", err)
+	}
+
+	var keyfile string
+	if cfg.Encryption != nil {
+		store.encrypt = cfg.Encryption.Enabled
+		if cfg.Encryption.Keyfile != "" {
+			keyfile = config.ExpandHome(cfg.Encryption.Keyfile)
+		}
+	}
+
+	// the key is loaded even if encryption is off, for older snapshots
+	if store.key, err = crypt.Load(keyfile); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// newrepo creates and initializes the store of the repository at root
+// the index is a local cache, so it is kept out of git
+func NewRepo(root string) (*Storage, error) {
+	dir := filepath.Join(root, defaultDirName)
+	store, err := open(dir, ScopeRepo, filepath.Clean(root))
+	if err != nil {
+		return nil, err
+	}
+
+	ignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		if err := os.WriteFile(ignore, []byte(indexFile+"\n"), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", ignore, err)
+		}
+	}
+
+	return store, nil
+}
+
+// open the store in dir, creating its directory
+func open(dir string, scope Scope, root string) (*Storage, error) {
+	basePath := filepath.Join(dir, shotsSubdir)
 
 	// create storage directory if missing
-	if err := os.MkdirAll(basePath, 0755); err != nil {
+	if err := os.MkdirAll(basePath, 0700); err != nil {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
 	return &Storage{
 		basePath:  basePath,
-		indexPath: indexPath,
+		indexPath: filepath.Join(dir, indexFile),
+		scope:     scope,
+		root:      root,
 	}, nil
 }
 
+// reporoot returns the root of the git repository containing dir, or an
+// empty string if dir is not in one
+func RepoRoot(dir string) string {
+	for {
+		// .git is a file in worktrees and submodules
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// hasrepo reports whether the repository at root has a store
+func HasRepo(root string) bool {
+	info, err := os.Stat(filepath.Join(root, defaultDirName, shotsSubdir))
+	return err == nil && info.IsDir()
+}
+
+// stores returns the store of the repository containing dir, if it has
+// one, followed by the global store
+func Stores(dir string) ([]*Storage, error) {
+	var stores []*Storage
+	if root := RepoRoot(dir); root != "" && HasRepo(root) {
+		store, err := NewRepo(root)
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, store)
+	}
+
+	global, err := New()
+	if err != nil {
+		return nil, err
+	}
+	return append(stores, global), nil
+}
+
+// open returns the store holding name, the repository's store shadows the
+// global one, and the global store if neither has it
+func Open(dir, name string) (*Storage, error) {
+	stores, err := Stores(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, store := range stores {
+		if store.Exists(name) {
+			return store, nil
+		}
+	}
+	return stores[len(stores)-1], nil
+}
+
+// encrypted reports whether the store encrypts the snapshots it saves
+func (s *Storage) Encrypted() bool {
+	return s.encrypt
+}
+
+// scope returns where the store keeps its snapshots
+func (s *Storage) Scope() Scope {
+	return s.scope
+}
+
+// setmigrator sets the hook that upgrades plugin data on load
+func (s *Storage) SetMigrator(m PluginMigrator) {
+	s.migrator = m
+}
+
+// setpaths sets the hook that finds the paths in plugin data, so a repo
+// store saves them relative to the repository; without it only the
+// working directory is
+func (s *Storage) SetPaths(p PluginPaths) {
+	s.paths = p
+}
+
 // save writes a snapshot to disk and updates index
 func (s *Storage) Save(snap *types.Snapshot) error {
 	// check snapshot schema version
@@ -74,15 +245,29 @@ func (s *Storage) Save(snap *types.Snapshot) error {
 		return fmt.Errorf("failed to marshal snapshot: %w", err)
 	}
 
-	// write file safely using temp file
-	tempPath := filePath + ".tmp"
-	if err := os.WriteFile(tempPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write snapshot file: %w", err)
+	// save paths relative to the repository
+	if s.root != "" {
+		relative := new(types.Snapshot)
+		if err := json.Unmarshal(data, relative); err != nil {
+			return fmt.Errorf("failed to copy snapshot: %w", err)
+		}
+		Relativize(relative, s.root, s.paths)
+
+		if data, err = json.MarshalIndent(relative, "", "  "); err != nil {
+			return fmt.Errorf("failed to marshal snapshot: %w", err)
+		}
 	}
 
-	if err := os.Rename(tempPath, filePath); err != nil {
-		os.Remove(tempPath)
-		return fmt.Errorf("failed to finalize snapshot file: %w", err)
+	// the name is authenticated, so files cannot be swapped
+	if s.encrypt {
+		if data, err = s.key.Encrypt(data, []byte(snap.Name)); err != nil {
+			return fmt.Errorf("failed to encrypt snapshot: %w", err)
+		}
+	}
+
+	// write file safely using temp file
+	if err := writeFile(filePath, data); err != nil {
+		return fmt.Errorf("failed to write snapshot file: %w", err)
 	}
 
 	// update index file
@@ -106,11 +291,16 @@ func (s *Storage) Load(name string) (*types.Snapshot, error) {
 		return nil, fmt.Errorf("failed to read snapshot file: %w", err)
 	}
 
+	if crypt.IsEncrypted(data) {
+		if data, err = s.key.Decrypt(data, []byte(name)); err != nil {
+			return nil, fmt.Errorf("failed to decrypt workshot '%s': %w", name, err)
+		}
+	}
+
 	var snap types.Snapshot
 	if err := json.Unmarshal(data, &snap); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
 	}
-
 	// migrate snapshot if version is old
 	if snap.SchemaVersion < types.SchemaVersion {
 		if err := s.migrateSnapshot(&snap); err != nil {
@@ -118,6 +308,18 @@ func (s *Storage) Load(name string) (*types.Snapshot, error) {
 		}
 	}
 
+	// upgrade plugin data from older plugin versions
+	if s.migrator != nil {
+		if err := s.migrator.MigratePluginData(&snap); err != nil {
+			return nil, fmt.Errorf("failed to migrate plugin data: %w", err)
+		}
+	}
+
+	// paths are mapped in the current plugin data format
+	if s.root != "" {
+		Absolutize(&snap, s.root, s.paths)
+	}
+
 	return &snap, nil
 }
 
@@ -132,6 +334,8 @@ func (s *Storage) List() ([]Metadata, error) {
 	// convert map to slice
 	metadataList := make([]Metadata, 0, len(index.Snapshots))
 	for _, meta := range index.Snapshots {
+		meta.WorkingDir = absolutePath(s.root, meta.WorkingDir)
+		meta.Scope = s.scope
 		metadataList = append(metadataList, meta)
 	}
 
@@ -173,6 +377,10 @@ func (s *Storage) Exists(name string) bool {
 
 // updateindex adds snapshot metadata to index
 func (s *Storage) updateIndex(snap *types.Snapshot) error {
+	if isHidden(snap.Name) {
+		return nil
+	}
+
 	index, err := s.loadIndex()
 	if err != nil {
 		index = &Index{
@@ -181,14 +389,23 @@ func (s *Storage) updateIndex(snap *types.Snapshot) error {
 		}
 	}
 
-	index.Snapshots[snap.Name] = Metadata{
+	index.Snapshots[snap.Name] = s.metadata(snap, s.encrypt)
+	return s.saveIndex(index)
+}
+
+// metadata is the index entry of a snapshot
+// the index is never encrypted, so it has no paths of encrypted snapshots
+func (s *Storage) metadata(snap *types.Snapshot, encrypted bool) Metadata {
+	if encrypted {
+		return Metadata{Name: snap.Name, CreatedAt: snap.CreatedAt, Encrypted: true}
+	}
+
+	return Metadata{
 		Name:       snap.Name,
 		CreatedAt:  snap.CreatedAt,
-		WorkingDir: snap.WorkingDir,
+		WorkingDir: relativePath(s.root, snap.WorkingDir),
 		GitBranch:  snap.GitBranch,
 	}
-
-	return s.saveIndex(index)
 }
 
 // loadindex reads index file from disk
@@ -213,7 +430,22 @@ func (s *Storage) saveIndex(index *Index) error {
 		return err
 	}
 
-	return os.WriteFile(s.indexPath, data, 0644)
+	return writeFile(s.indexPath, data)
+}
+
+// write a file readable only by the user through a temp file, so it is
+// never left half written
+func writeFile(path string, data []byte) error {
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0600); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
 }
 
 // rebuildindex recreates index from snapshot files
@@ -236,22 +468,29 @@ func (s *Storage) rebuildIndex() ([]Metadata, error) {
 		}
 
 		name := entry.Name()[:len(entry.Name())-5]
+		if isHidden(name) {
+			continue
+		}
 
 		// load snapshot file
+		encrypted := s.isEncrypted(name)
 		snap, err := s.Load(name)
-		if err != nil {
+		if err != nil && encrypted {
+			// list it without the key, by the time of its file
+			snap = &types.Snapshot{Name: name}
+			if info, err := entry.Info(); err == nil {
+				snap.CreatedAt = info.ModTime()
+			}
+		} else if err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: skipping corrupted snapshot '%s': %v\n", name, err)
 			continue
 		}
 
-		meta := Metadata{
-			Name:       snap.Name,
-			CreatedAt:  snap.CreatedAt,
-			WorkingDir: snap.WorkingDir,
-			GitBranch:  snap.GitBranch,
-		}
-
+		meta := s.metadata(snap, encrypted)
 		index.Snapshots[name] = meta
+
+		meta.WorkingDir = absolutePath(s.root, meta.WorkingDir)
+		meta.Scope = s.scope
 		metadataList = append(metadataList, meta)
 	}
 
@@ -268,6 +507,166 @@ func (s *Storage) rebuildIndex() ([]Metadata, error) {
 	return metadataList, nil
 }
 
+// isencrypted reports whether a snapshot file is encrypted
+func (s *Storage) isEncrypted(name string) bool {
+	data, err := os.ReadFile(filepath.Join(s.basePath, name+".json"))
+	return err == nil && crypt.IsEncrypted(data)
+}
+
+// encryptall rewrites the plain snapshots of the store encrypted, and the
+// index without their paths
+// it returns the number of snapshots it encrypted
+func (s *Storage) EncryptAll() (int, error) {
+	if !s.encrypt {
+		return 0, fmt.Errorf("encryption is not enabled")
+	}
+
+	entries, err := os.ReadDir(s.basePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read shots directory: %w", err)
+	}
+
+	count := 0
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if s.isEncrypted(name) {
+			continue
+		}
+
+		snap, err := s.Load(name)
+		if err != nil {
+			return count, err
+		}
+		if err := s.Save(snap); err != nil {
+			return count, fmt.Errorf("failed to encrypt workshot '%s': %w", name, err)
+		}
+		count++
+	}
+
+	// drop paths of snapshots that were already encrypted, too
+	os.Remove(s.indexPath)
+	if _, err := s.rebuildIndex(); err != nil {
+		return count, err
+	}
+
+	return count, os.Chmod(filepath.Dir(s.indexPath), 0700)
+}
+
+// decryptall rewrites the encrypted snapshots of the store as plain JSON,
+// and the index with their paths again
+// the store stops encrypting, and it returns the number it decrypted
+func (s *Storage) DecryptAll() (int, error) {
+	if s.key == nil {
+		return 0, crypt.ErrNoKey
+	}
+	s.encrypt = false
+
+	entries, err := os.ReadDir(s.basePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read shots directory: %w", err)
+	}
+
+	count := 0
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if !s.isEncrypted(name) {
+			continue
+		}
+
+		snap, err := s.Load(name)
+		if err != nil {
+			return count, err
+		}
+		if err := s.Save(snap); err != nil {
+			return count, fmt.Errorf("failed to decrypt workshot '%s': %w", name, err)
+		}
+		count++
+	}
+
+	os.Remove(s.indexPath)
+	if _, err := s.rebuildIndex(); err != nil {
+		return count, err
+	}
+	return count, nil
+}
+
+// checkname rejects names that cannot be a snapshot file of their own,
+// for snapshots that come from elsewhere, like bundles and shared refs
+func CheckName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid snapshot name '%s'", name)
+	}
+	return nil
+}
+
+// hidden snapshots, like the pre-restore one, start with a dot
+// they can be loaded by name but are left out of the index and list
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// relativize replaces the repository root in the paths of the snapshot,
+// so it can be restored from another checkout of the repository
+// plugin paths are found through paths, if set
+func Relativize(snap *types.Snapshot, root string, paths PluginPaths) {
+	snap.WorkingDir = relativePath(root, snap.WorkingDir)
+	if paths != nil {
+		paths.MapPaths(snap.PluginData, func(path string) string {
+			return relativePath(root, path)
+		})
+	}
+}
+
+// absolutize resolves the paths of a relativized snapshot in the checkout
+// at root
+func Absolutize(snap *types.Snapshot, root string, paths PluginPaths) {
+	snap.WorkingDir = absolutePath(root, snap.WorkingDir)
+	if paths != nil {
+		paths.MapPaths(snap.PluginData, func(path string) string {
+			return absolutePath(root, path)
+		})
+	}
+}
+
+// ${repo} or ${repo}/rel/path for paths in the repository
+// the rest of the path is kept as it is, so absolutepath gives it back
+func relativePath(root, path string) string {
+	if root == "" {
+		return path
+	}
+	if path == root {
+		return repoPrefix
+	}
+
+	rel, ok := strings.CutPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+	if !ok {
+		return path
+	}
+	return repoPrefix + "/" + filepath.ToSlash(rel)
+}
+
+// the path on disk of a path saved by relativepath
+func absolutePath(root, path string) string {
+	if root == "" {
+		return path
+	}
+	if path == repoPrefix {
+		return root
+	}
+	if rel, ok := strings.CutPrefix(path, repoPrefix+"/"); ok {
+		return strings.TrimSuffix(root, string(filepath.Separator)) + string(filepath.Separator) + filepath.FromSlash(rel)
+	}
+	return path
+}
+
 // migratesnapshot updates snapshot version
 func (s *Storage) migrateSnapshot(snap *types.Snapshot) error {
 	// future migrations go here
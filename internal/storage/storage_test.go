@@ -1,20 +1,25 @@
 package storage
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ansoncodes/workshot/internal/capture"
+	"github.com/ansoncodes/workshot/internal/config"
+	"github.com/ansoncodes/workshot/internal/crypt"
+	"github.com/ansoncodes/workshot/internal/plugin"
 	"github.com/ansoncodes/workshot/pkg/types"
 )
 
 func TestStorageSaveLoad(t *testing.T) {
 	tempDir := t.TempDir()
-	
-	oldHome := os.Getenv("USERPROFILE")
-	os.Setenv("USERPROFILE", tempDir)
-	defer os.Setenv("USERPROFILE", oldHome)
+
+	t.Setenv("HOME", tempDir)
+	t.Setenv("USERPROFILE", tempDir)
 
 	store, err := New()
 	if err != nil {
@@ -53,10 +58,9 @@ func TestStorageSaveLoad(t *testing.T) {
 
 func TestStorageList(t *testing.T) {
 	tempDir := t.TempDir()
-	
-	oldHome := os.Getenv("USERPROFILE")
-	os.Setenv("USERPROFILE", tempDir)
-	defer os.Setenv("USERPROFILE", oldHome)
+
+	t.Setenv("HOME", tempDir)
+	t.Setenv("USERPROFILE", tempDir)
 
 	store, err := New()
 	if err != nil {
@@ -73,6 +77,11 @@ func TestStorageList(t *testing.T) {
 		time.Sleep(10 * time.Millisecond)
 	}
 
+	// hidden snapshots are not listed
+	if err := store.Save(types.NewSnapshot(".pre-restore")); err != nil {
+		t.Fatalf("Failed to save hidden snapshot: %v", err)
+	}
+
 	metadataList, err := store.List()
 	if err != nil {
 		t.Fatalf("Failed to list snapshots: %v", err)
@@ -82,6 +91,11 @@ func TestStorageList(t *testing.T) {
 		t.Errorf("Expected %d snapshots, got %d", len(names), len(metadataList))
 	}
 
+	os.Remove(store.indexPath)
+	if rebuilt, err := store.List(); err != nil || len(rebuilt) != len(names) {
+		t.Errorf("Expected %d snapshots after rebuilding the index, got %d: %v", len(names), len(rebuilt), err)
+	}
+
 	for i := 0; i < len(metadataList)-1; i++ {
 		if metadataList[i].CreatedAt.Before(metadataList[i+1].CreatedAt) {
 			t.Error("Snapshots are not sorted by creation time (newest first)")
@@ -92,10 +106,9 @@ func TestStorageList(t *testing.T) {
 
 func TestStorageDelete(t *testing.T) {
 	tempDir := t.TempDir()
-	
-	oldHome := os.Getenv("USERPROFILE")
-	os.Setenv("USERPROFILE", tempDir)
-	defer os.Setenv("USERPROFILE", oldHome)
+
+	t.Setenv("HOME", tempDir)
+	t.Setenv("USERPROFILE", tempDir)
 
 	store, err := New()
 	if err != nil {
@@ -123,10 +136,9 @@ func TestStorageDelete(t *testing.T) {
 
 func TestStorageSchemaVersion(t *testing.T) {
 	tempDir := t.TempDir()
-	
-	oldHome := os.Getenv("USERPROFILE")
-	os.Setenv("USERPROFILE", tempDir)
-	defer os.Setenv("USERPROFILE", oldHome)
+
+	t.Setenv("HOME", tempDir)
+	t.Setenv("USERPROFILE", tempDir)
 
 	store, err := New()
 	if err != nil {
@@ -149,4 +161,414 @@ func TestStorageSchemaVersion(t *testing.T) {
 		t.Errorf("Schema version mismatch: got %d, want %d",
 			loaded.SchemaVersion, types.SchemaVersion)
 	}
-}
\ No newline at end of file
+}
+
+// notesCapturer mimics a plugin whose data format changed twice:
+// version 2 renamed "todo" to "todos", version 3 made it a list
+type notesCapturer struct{}
+
+func (n *notesCapturer) Name() string {
+	return "notes"
+}
+
+func (n *notesCapturer) Priority() int {
+	return 70
+}
+
+func (n *notesCapturer) DataVersion() int {
+	return 3
+}
+
+func (n *notesCapturer) Capture(ctx context.Context) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (n *notesCapturer) Restore(ctx context.Context, data map[string]interface{}) error {
+	return nil
+}
+
+func (n *notesCapturer) CanRestore(data map[string]interface{}) bool {
+	return false
+}
+
+func (n *notesCapturer) MigrateData(from int, data map[string]interface{}) (map[string]interface{}, error) {
+	if from < 2 {
+		data = map[string]interface{}{"todos": data["todo"]}
+	}
+	if from < 3 {
+		data = map[string]interface{}{"todos": []interface{}{data["todos"]}}
+	}
+	return data, nil
+}
+
+// load every snapshot in testdata through the real migration path
+func TestLoadFixtures(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("HOME", tempDir)
+	t.Setenv("USERPROFILE", tempDir)
+
+	store, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil || len(fixtures) == 0 {
+		t.Fatalf("No fixtures found: %v", err)
+	}
+	for _, fixture := range fixtures {
+		data, err := os.ReadFile(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(store.basePath, filepath.Base(fixture)), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manager := plugin.NewManager()
+	manager.Register(capture.NewGitCapturer())
+	manager.Register(capture.NewTerminalCapturer())
+	manager.Register(capture.NewToolchainCapturer())
+	manager.Register(&notesCapturer{})
+	store.SetMigrator(manager)
+
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".json")
+
+		t.Run(name, func(t *testing.T) {
+			snap, err := store.Load(name)
+			if err != nil {
+				t.Fatalf("Failed to load fixture: %v", err)
+			}
+
+			if snap.Name != name || snap.SchemaVersion != types.SchemaVersion {
+				t.Errorf("Unexpected snapshot header: %s version %d", snap.Name, snap.SchemaVersion)
+			}
+
+			if notes, ok := snap.PluginData["notes"].(map[string]interface{}); ok {
+				todos, ok := notes["todos"].([]interface{})
+				if !ok || len(todos) != 1 || todos[0] != "finish review" {
+					t.Errorf("Notes data not migrated to version 3: %v", notes)
+				}
+				if snap.PluginVersions["notes"] != 3 {
+					t.Errorf("Expected notes version 3, got %v", snap.PluginVersions)
+				}
+			}
+
+			if git, ok := snap.PluginData["git"].(map[string]interface{}); ok {
+				if git["branch"] != snap.GitBranch {
+					t.Errorf("Git data changed by migration: %v", git)
+				}
+			}
+		})
+	}
+
+	// the capture report survives loading
+	snap, err := store.Load("capture-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.CaptureReport) != 3 || snap.CaptureReport[1].Status != types.PluginTimeout {
+		t.Errorf("Unexpected capture report: %+v", snap.CaptureReport)
+	}
+}
+
+// knownPaths maps the git root and the editor files, as the plugins would
+type knownPaths struct{}
+
+func (knownPaths) MapPaths(pluginData map[string]interface{}, f func(path string) string) {
+	if git, ok := pluginData["git"].(map[string]interface{}); ok {
+		git["root"] = f(git["root"].(string))
+	}
+	if editor, ok := pluginData["editor"].(map[string]interface{}); ok {
+		for i, file := range editor["files"].([]interface{}) {
+			editor["files"].([]interface{})[i] = f(file.(string))
+		}
+	}
+}
+
+func TestRepoStorage(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	repo := filepath.Join(t.TempDir(), "api")
+	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+	os.MkdirAll(filepath.Join(repo, "cmd"), 0755)
+
+	if RepoRoot(filepath.Join(repo, "cmd")) != repo {
+		t.Fatalf("Expected %s to be the repository root", repo)
+	}
+
+	store, err := NewRepo(repo)
+	if err != nil {
+		t.Fatalf("Failed to create repo storage: %v", err)
+	}
+	store.SetPaths(knownPaths{})
+
+	snap := types.NewSnapshot("feature")
+	snap.WorkingDir = filepath.Join(repo, "cmd")
+	snap.PluginData["git"] = map[string]interface{}{"branch": "feature/x", "root": repo}
+	snap.PluginData["editor"] = map[string]interface{}{
+		"files": []interface{}{filepath.Join(repo, "cmd", "main.go"), filepath.Join(home, "notes.md")},
+	}
+	command := "cd " + repo + " && make"
+	snap.PluginData["terminal"] = map[string]interface{}{"history": []interface{}{command}}
+	if err := store.Save(snap); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	// the file holds no paths of this checkout
+	data, err := os.ReadFile(filepath.Join(repo, ".workshot", "shots", "feature.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), repo) != 1 || !strings.Contains(string(data), `"${repo}/cmd"`) {
+		t.Errorf("Expected paths relative to the repository, got %s", data)
+	}
+	if snap.WorkingDir != filepath.Join(repo, "cmd") {
+		t.Errorf("Save changed the snapshot: %s", snap.WorkingDir)
+	}
+
+	// another checkout of the repository resolves the paths to itself
+	clone := filepath.Join(t.TempDir(), "api-clone")
+	os.MkdirAll(filepath.Join(clone, ".workshot", "shots"), 0755)
+	os.WriteFile(filepath.Join(clone, ".workshot", "shots", "feature.json"), data, 0644)
+
+	cloneStore, err := NewRepo(clone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cloneStore.SetPaths(knownPaths{})
+	loaded, err := cloneStore.Load("feature")
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+
+	git := loaded.PluginData["git"].(map[string]interface{})
+	files := loaded.PluginData["editor"].(map[string]interface{})["files"].([]interface{})
+	if loaded.WorkingDir != filepath.Join(clone, "cmd") || git["root"] != clone {
+		t.Errorf("Expected paths in the clone, got %s and %v", loaded.WorkingDir, git)
+	}
+	if files[0] != filepath.Join(clone, "cmd", "main.go") || files[1] != filepath.Join(home, "notes.md") {
+		t.Errorf("Unexpected editor files: %v", files)
+	}
+
+	// data no plugin declares as a path is left as it was saved
+	history := loaded.PluginData["terminal"].(map[string]interface{})["history"].([]interface{})
+	if history[0] != command {
+		t.Errorf("Expected the terminal command untouched, got %v", history)
+	}
+
+	// so does a copy of the index
+	index, err := os.ReadFile(filepath.Join(repo, ".workshot", "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(clone, ".workshot", "index.json"), index, 0644)
+
+	list, err := cloneStore.List()
+	if err != nil || len(list) != 1 || list[0].WorkingDir != filepath.Join(clone, "cmd") {
+		t.Errorf("Expected the listed directory in the clone, got %+v (%v)", list, err)
+	}
+
+	// the index is a local cache and stays out of git
+	ignore, err := os.ReadFile(filepath.Join(clone, ".workshot", ".gitignore"))
+	if err != nil || strings.TrimSpace(string(ignore)) != "index.json" {
+		t.Errorf("Expected the index to be ignored, got %q (%v)", ignore, err)
+	}
+}
+
+func TestRepoPathsRoundTrip(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator)+"src", "api")
+	for _, path := range []string{
+		root,
+		root + string(filepath.Separator),
+		filepath.Join(root, "cmd", "main.go"),
+		root + string(filepath.Separator) + "cmd" + string(filepath.Separator) + ".." + string(filepath.Separator) + "x",
+		root + "-other",
+		filepath.Join(string(filepath.Separator)+"tmp", "notes.md"),
+		"relative/path",
+		"",
+	} {
+		if got := absolutePath(root, relativePath(root, path)); got != path {
+			t.Errorf("Round trip of %q gave %q", path, got)
+		}
+	}
+}
+
+func TestOpenStores(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	repo := t.TempDir()
+	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+
+	// a repository without a store only has the global one
+	stores, err := Stores(repo)
+	if err != nil || len(stores) != 1 || stores[0].Scope() != ScopeGlobal {
+		t.Fatalf("Expected only the global store, got %v (%v)", stores, err)
+	}
+
+	global := stores[0]
+	repoStore, err := NewRepo(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, store := range []*Storage{global, repoStore} {
+		for _, name := range []string{"shared", string(store.Scope())} {
+			snap := types.NewSnapshot(name)
+			snap.WorkingDir = repo
+			if err := store.Save(snap); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// the repository shadows the global store
+	for name, scope := range map[string]Scope{"shared": ScopeRepo, "repo": ScopeRepo, "global": ScopeGlobal, "missing": ScopeGlobal} {
+		store, err := Open(repo, name)
+		if err != nil || store.Scope() != scope {
+			t.Errorf("Expected %s in the %s store, got %v", name, scope, err)
+		}
+	}
+
+	stores, err = Stores(repo)
+	if err != nil || len(stores) != 2 {
+		t.Fatalf("Expected the repo and global stores, got %v (%v)", stores, err)
+	}
+	list, err := stores[0].List()
+	if err != nil || len(list) != 2 || list[0].Scope != ScopeRepo {
+		t.Errorf("Expected repo snapshots in the repo list, got %+v (%v)", list, err)
+	}
+}
+
+func TestEncryptedStorage(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv(crypt.PassphraseEnv, "")
+
+	// a snapshot saved before encryption was turned on
+	plain, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	old := types.NewSnapshot("old")
+	old.WorkingDir = "/home/alice/old"
+	if err := plain.Save(old); err != nil {
+		t.Fatal(err)
+	}
+
+	keyfile := filepath.Join(home, ".workshot", "key")
+	if err := crypt.GenerateKeyfile(keyfile); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Encryption: &config.EncryptionConfig{Enabled: true, Keyfile: keyfile}}
+	if err := cfg.Save(filepath.Join(home, ".workshot", "config.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if !store.Encrypted() {
+		t.Fatal("Expected the store to encrypt")
+	}
+
+	snap := types.NewSnapshot("secret")
+	snap.WorkingDir = "/home/alice/secret-project"
+	snap.GitBranch = "feature/acquisition"
+	if err := store.Save(snap); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	path := filepath.Join(home, ".workshot", "shots", "secret.json")
+	data, _ := os.ReadFile(path)
+	if !crypt.IsEncrypted(data) || strings.Contains(string(data), "secret-project") {
+		t.Errorf("Expected an encrypted snapshot file, got %s", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	loaded, err := store.Load("secret")
+	if err != nil || loaded.WorkingDir != snap.WorkingDir {
+		t.Fatalf("Failed to load encrypted snapshot: %v", err)
+	}
+
+	// the index keeps only the name and time
+	index, _ := os.ReadFile(store.indexPath)
+	if strings.Contains(string(index), "secret-project") || strings.Contains(string(index), "acquisition") {
+		t.Errorf("Index leaks encrypted metadata: %s", index)
+	}
+
+	// migrate the older snapshot
+	count, err := store.EncryptAll()
+	if err != nil || count != 1 {
+		t.Fatalf("Expected 1 snapshot encrypted, got %d, %v", count, err)
+	}
+	data, _ = os.ReadFile(filepath.Join(home, ".workshot", "shots", "old.json"))
+	if !crypt.IsEncrypted(data) {
+		t.Error("Expected EncryptAll to encrypt the older snapshot")
+	}
+	index, _ = os.ReadFile(store.indexPath)
+	if strings.Contains(string(index), "/home/alice") {
+		t.Errorf("Index still has paths after EncryptAll: %s", index)
+	}
+
+	// decrypting needs the key and gives the paths back to the index
+	keyed, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyed.key = nil
+	if _, err := keyed.DecryptAll(); err == nil {
+		t.Error("Expected decrypting without the key to fail")
+	}
+
+	saved := make(map[string][]byte)
+	for _, name := range []string{"old", "secret"} {
+		saved[name], _ = os.ReadFile(filepath.Join(home, ".workshot", "shots", name+".json"))
+	}
+	count, err = store.DecryptAll()
+	if err != nil || count != 2 || store.Encrypted() {
+		t.Fatalf("Expected 2 snapshots decrypted, got %d, %v", count, err)
+	}
+	data, _ = os.ReadFile(path)
+	if crypt.IsEncrypted(data) || !strings.Contains(string(data), "secret-project") {
+		t.Errorf("Expected a plain snapshot file, got %s", data)
+	}
+	index, _ = os.ReadFile(store.indexPath)
+	if !strings.Contains(string(index), "secret-project") {
+		t.Errorf("Expected the index to have paths again: %s", index)
+	}
+	for name, data := range saved {
+		os.WriteFile(filepath.Join(home, ".workshot", "shots", name+".json"), data, 0600)
+	}
+
+	// without the key the snapshots are listed but cannot be loaded
+	os.Remove(keyfile)
+	cfg.Encryption.Keyfile = ""
+	cfg.Save(filepath.Join(home, ".workshot", "config.json"))
+	os.Remove(store.indexPath)
+
+	locked, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := locked.List()
+	if err != nil || len(list) != 2 || !list[0].Encrypted {
+		t.Errorf("Expected 2 encrypted snapshots listed, got %+v, %v", list, err)
+	}
+	if _, err := locked.Load("secret"); err == nil {
+		t.Error("Expected loading without the key to fail")
+	}
+	if err := locked.Save(types.NewSnapshot("new")); err == nil {
+		t.Error("Expected saving without the key to fail")
+	}
+}
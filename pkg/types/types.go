@@ -1,6 +1,10 @@
 package types
 
-import "time"
+import (
+	"context"
+	"errors"
+	"time"
+)
 
 const (
 	// SchemaVersion represents the current snapshot format version.
@@ -27,6 +31,86 @@ type Snapshot struct {
 	// The key is the plugin name, and the value is plugin-specific data.
 	// This allows the snapshot format to remain flexible and extensible.
 	PluginData map[string]interface{} `json:"plugin_data,omitempty"`
+
+	// PluginVersions records the data version of each plugin's payload.
+	// A missing entry means version 1, which predates versioning.
+	PluginVersions map[string]int `json:"plugin_versions,omitempty"`
+
+	// CaptureReport records how each plugin's capture went, in run order.
+	// Plugins that failed or timed out have no entry in PluginData.
+	CaptureReport []PluginReport `json:"capture_report,omitempty"`
+
+	// Rollback is only set on the hidden pre-restore snapshot, which holds
+	// the state from before a restore so the restore can be undone.
+	Rollback *RollbackInfo `json:"rollback,omitempty"`
+}
+
+// RollbackInfo records the restore a pre-restore snapshot can undo.
+type RollbackInfo struct {
+	// Snapshot is the name of the restored snapshot.
+	Snapshot string `json:"snapshot"`
+
+	// Dir is where the plugins were restored. The pre-restore plugin data
+	// was captured there, while WorkingDir is where the user came from.
+	Dir string `json:"dir"`
+
+	// Applied holds the data of each plugin that restored, by name.
+	Applied map[string]interface{} `json:"applied"`
+}
+
+// PluginStatus describes how a plugin's capture ended.
+type PluginStatus string
+
+const (
+	PluginOK      PluginStatus = "ok"
+	PluginEmpty   PluginStatus = "empty" // ran but found nothing to capture
+	PluginFailed  PluginStatus = "failed"
+	PluginTimeout PluginStatus = "timeout"
+)
+
+// PluginReport records the outcome of one plugin's capture.
+type PluginReport struct {
+	Name     string        `json:"name"`
+	Status   PluginStatus  `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+	DataSize int           `json:"data_size"` // bytes of captured json
+}
+
+// Failed reports whether the plugin's data is missing from the snapshot.
+func (r PluginReport) Failed() bool {
+	return r.Status == PluginFailed || r.Status == PluginTimeout
+}
+
+// RestoreStatus describes how a plugin's restore ended.
+type RestoreStatus string
+
+const (
+	RestoreApplied RestoreStatus = "applied"
+	RestoreFailed  RestoreStatus = "failed"
+	RestoreSkipped RestoreStatus = "skipped"
+)
+
+// Reasons a plugin with saved data is not restored.
+const (
+	SkipExcluded      = "excluded"       // left out by --only or --except
+	SkipDisabled      = "disabled"       // turned off in config
+	SkipCannotRestore = "cannot restore" // CanRestore returned false
+	SkipShell         = "restored by shell commands"
+	SkipDeclined      = "declined"
+	SkipInvalidData   = "invalid data format"
+)
+
+// RestoreReport records the outcome of one plugin's restore.
+type RestoreReport struct {
+	Name   string
+	Status RestoreStatus
+
+	// Reason says why a skipped plugin was not restored.
+	Reason string
+
+	// Err is why the restore failed, or a warning for an applied plugin.
+	Err error
 }
 
 // Capturer defines the contract that all capture plugins must follow.
@@ -36,16 +120,17 @@ type Capturer interface {
 	// This is used as the key inside Snapshot.PluginData.
 	Name() string
 
-	// Priority determines execution order.
-	// Lower values run earlier, allowing dependency-based ordering.
+	// Priority orders plugins that do not depend on each other.
+	// Lower values run earlier. Use Dependent to require another plugin.
 	Priority() int
 
 	// Capture collects relevant data from the current environment.
 	// It should return (nil, nil) if there is nothing to capture.
-	Capture() (map[string]interface{}, error)
+	// The context is cancelled when the plugin's timeout expires.
+	Capture(ctx context.Context) (map[string]interface{}, error)
 
 	// Restore applies previously captured data back to the environment.
-	Restore(data map[string]interface{}) error
+	Restore(ctx context.Context, data map[string]interface{}) error
 
 	// CanRestore determines whether the capturer can safely restore
 	// the provided data. This enables graceful handling of
@@ -53,6 +138,268 @@ type Capturer interface {
 	CanRestore(data map[string]interface{}) bool
 }
 
+// TypedCapturer is a capturer whose data is a struct of type T instead of a
+// generic map. The struct's json tags define the on-disk format. Register
+// it through plugin.Typed, which converts between T and snapshot data.
+type TypedCapturer[T any] interface {
+	Name() string
+	Priority() int
+
+	// CaptureData collects data, returning (nil, nil) if there is nothing
+	// to capture.
+	CaptureData(ctx context.Context) (*T, error)
+
+	// RestoreData applies previously captured data.
+	RestoreData(ctx context.Context, data *T) error
+
+	// CanRestoreData reports whether the data can be restored.
+	CanRestoreData(data *T) bool
+}
+
+// Section is the part of show and restore output a plugin contributes.
+type Section struct {
+	// Plugin is the name of the plugin the section belongs to.
+	Plugin string
+
+	// Title heads the section, e.g. "Git State".
+	Title string
+
+	// Important sections are shown first by restore, marked as a warning.
+	Important bool
+
+	// Fields are labelled values, shown before Lines.
+	Fields []Field
+
+	// Lines are free-form text lines.
+	Lines []string
+
+	// Hints are shown by restore when the plugin did not restore its data
+	// itself, e.g. how to enable it.
+	Hints []string
+}
+
+// Field is one labelled value of a section.
+type Field struct {
+	Label string
+	Value string
+}
+
+// Renderer is implemented by capturers that describe their saved data.
+// Capturers without it get a generic listing of their top-level fields.
+type Renderer interface {
+	// Render returns the section for the data, or nil to show nothing.
+	Render(data map[string]interface{}) *Section
+}
+
+// TypedRenderer is the Renderer of a TypedCapturer.
+type TypedRenderer[T any] interface {
+	RenderData(data *T) *Section
+}
+
+// ActionKind is the kind of a restore plan action.
+type ActionKind string
+
+const (
+	// ActionCd changes directory to Path.
+	ActionCd ActionKind = "cd"
+
+	// ActionExport sets environment variable Name to Value.
+	ActionExport ActionKind = "export"
+
+	// ActionActivate activates the python virtualenv at Path.
+	ActionActivate ActionKind = "activate"
+
+	// ActionRun runs Args as a command.
+	ActionRun ActionKind = "run"
+)
+
+// Action is one shell-level step of a restore plan. Restore plans run in
+// the user's shell through eval, so they can change its directory and
+// environment, which an in-process Restore cannot.
+type Action struct {
+	Kind  ActionKind `json:"kind"`
+	Path  string     `json:"path,omitempty"`
+	Name  string     `json:"name,omitempty"`
+	Value string     `json:"value,omitempty"`
+	Args  []string   `json:"args,omitempty"`
+}
+
+// Cd returns an action that changes directory.
+func Cd(path string) Action {
+	return Action{Kind: ActionCd, Path: path}
+}
+
+// Export returns an action that sets an environment variable.
+func Export(name, value string) Action {
+	return Action{Kind: ActionExport, Name: name, Value: value}
+}
+
+// Activate returns an action that activates a python virtualenv.
+func Activate(venv string) Action {
+	return Action{Kind: ActionActivate, Path: venv}
+}
+
+// Run returns an action that runs a command.
+func Run(args ...string) Action {
+	return Action{Kind: ActionRun, Args: args}
+}
+
+// Planner is implemented by capturers whose data is restored in the
+// user's shell. Restore serializes the plan for the target shell.
+type Planner interface {
+	// RestorePlan returns the shell actions that restore the data.
+	RestorePlan(data map[string]interface{}) []Action
+}
+
+// TypedPlanner is the Planner of a TypedCapturer.
+type TypedPlanner[T any] interface {
+	RestorePlanData(data *T) []Action
+}
+
+// Step is one described effect of restoring a plugin's data, e.g. checking
+// out a branch. Current and Target show the state before and after, and
+// are empty when the step has no comparable state.
+type Step struct {
+	Description string `json:"description"`
+	Current     string `json:"current,omitempty"`
+	Target      string `json:"target,omitempty"`
+}
+
+// Noop reports whether the step would leave things as they are.
+func (s Step) Noop() bool {
+	return s.Current != "" && s.Current == s.Target
+}
+
+// Previewer is implemented by capturers that can describe what Restore will
+// do without doing it. Capturers without it are shown as a single step.
+type Previewer interface {
+	// Preview returns the steps Restore would take, compared against the
+	// current state. An empty preview means Restore changes nothing.
+	Preview(ctx context.Context, data map[string]interface{}) []Step
+}
+
+// TypedPreviewer is the Previewer of a TypedCapturer.
+type TypedPreviewer[T any] interface {
+	PreviewData(ctx context.Context, data *T) []Step
+}
+
+// PluginPreview describes what restoring one plugin would do.
+type PluginPreview struct {
+	Name  string
+	Steps []Step
+
+	// Skipped is why the plugin will not restore, empty if it will.
+	Skipped string
+}
+
+// Changes reports whether any step would change something.
+func (p PluginPreview) Changes() bool {
+	for _, step := range p.Steps {
+		if !step.Noop() {
+			return true
+		}
+	}
+	return false
+}
+
+// Rollbacker is implemented by capturers that can undo their Restore.
+type Rollbacker interface {
+	// Rollback undoes the restore of applied. Before is the plugin's
+	// capture from just before the restore, nil if it captured nothing.
+	Rollback(ctx context.Context, before, applied map[string]interface{}) error
+}
+
+// TypedRollbacker is the Rollbacker of a TypedCapturer.
+type TypedRollbacker[T any] interface {
+	RollbackData(ctx context.Context, before, applied *T) error
+}
+
+// PathMapper is implemented by capturers whose data holds file system
+// paths, so snapshots in a repository can save them relative to its root.
+// Only paths are mapped; commands and other strings are left as they are.
+type PathMapper interface {
+	// MapPaths returns data with every path replaced by f(path).
+	MapPaths(data map[string]interface{}, f func(path string) string) map[string]interface{}
+}
+
+// TypedPathMapper is the PathMapper of a TypedCapturer. MapPathsData
+// replaces the paths of data in place.
+type TypedPathMapper[T any] interface {
+	MapPathsData(data *T, f func(path string) string)
+}
+
+// warning is a restore error that does not make the restore fail
+type warning struct {
+	err error
+}
+
+func (w warning) Error() string { return w.err.Error() }
+func (w warning) Unwrap() error { return w.err }
+
+// Warning marks a Restore error as a warning, e.g. a version mismatch the
+// user should know about. Warnings are reported but do not count as a
+// failed restore, so they do not trigger a rollback.
+func Warning(err error) error {
+	if err == nil {
+		return nil
+	}
+	return warning{err: err}
+}
+
+// IsWarning reports whether err, or any error it wraps, is a warning.
+func IsWarning(err error) bool {
+	var w warning
+	return errors.As(err, &w)
+}
+
+// Versioned is implemented by capturers whose data format has changed.
+// Capturers without it produce version 1 data.
+type Versioned interface {
+	// DataVersion returns the version of the data Capture produces.
+	DataVersion() int
+}
+
+// Migrator is implemented by versioned capturers that can upgrade data
+// saved by older versions. MigrateData receives the saved version and
+// must return data in the current DataVersion format.
+type Migrator interface {
+	MigrateData(from int, data map[string]interface{}) (map[string]interface{}, error)
+}
+
+// DataVersion returns the data version of a capturer.
+func DataVersion(c Capturer) int {
+	if v, ok := c.(Versioned); ok && v.DataVersion() > 0 {
+		return v.DataVersion()
+	}
+	return 1
+}
+
+// Dependent is implemented by capturers that need other plugins to run first.
+// Dependencies are captured and restored before the dependent plugin, and
+// their output is available through DependencyData. Priority only breaks
+// ties between plugins that do not depend on each other.
+type Dependent interface {
+	// DependsOn returns the names of the plugins this capturer needs.
+	DependsOn() []string
+}
+
+type dependencyDataKey struct{}
+
+// WithDependencyData returns a context carrying the output of dependencies,
+// keyed by plugin name.
+func WithDependencyData(ctx context.Context, data map[string]map[string]interface{}) context.Context {
+	return context.WithValue(ctx, dependencyDataKey{}, data)
+}
+
+// DependencyData returns the output of a declared dependency during Capture
+// or Restore. It reports false if the dependency is disabled, failed, or had
+// nothing to capture.
+func DependencyData(ctx context.Context, name string) (map[string]interface{}, bool) {
+	all, _ := ctx.Value(dependencyDataKey{}).(map[string]map[string]interface{})
+	data, ok := all[name]
+	return data, ok
+}
+
 // NewSnapshot creates a new Snapshot instance with sensible defaults.
 // PluginData is always initialized to avoid nil map checks later.
 func NewSnapshot(name string) *Snapshot {